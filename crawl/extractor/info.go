@@ -56,6 +56,8 @@ var CtimeUnits *C.char = C.CString("time#units")
 var CncDimTimeValues *C.char = C.CString("NETCDF_DIM_time_VALUES")
 var CncDimLevelValues *C.char = C.CString("NETCDF_DIM_lev_VALUES")
 var CncVarname *C.char = C.CString("NETCDF_VARNAME")
+var CpixelType *C.char = C.CString("PIXELTYPE")
+var CimageStructure *C.char = C.CString("IMAGE_STRUCTURE")
 
 func ExtractGDALInfo(path string, concLimit int, approx bool) (*GeoFile, error) {
 	cPath := C.CString(path)
@@ -270,7 +272,7 @@ func getDataSetInfo(filename string, dsName *C.char, driverName string, approx b
 	return &GeoMetaData{
 		DataSetName:  datasetName,
 		NameSpace:    nameSpace,
-		Type:         C.GoString(C.GDALGetDataTypeName(C.GDALGetRasterDataType(hBand))),
+		Type:         getRasterType(hBand),
 		RasterCount:  int32(C.GDALGetRasterCount(hSubdataset)),
 		TimeStamps:   times,
 		Heights:      ncLevels,
@@ -426,3 +428,16 @@ func getNCLevels(sdsName string, hSubdataset C.GDALDatasetH) ([]float64, error)
 	}
 	return levels, fmt.Errorf("Dataset %s doesn't contain levels", sdsName)
 }
+
+// getRasterType returns the data type of a band, reporting
+// signed bytes as Int8
+func getRasterType(hBand C.GDALRasterBandH) string {
+	dType := C.GDALGetRasterDataType(hBand)
+	if dType == C.GDT_Byte {
+		pixelType := C.GDALGetMetadataItem(C.GDALMajorObjectH(hBand), CpixelType, CimageStructure)
+		if pixelType != nil && C.GoString(pixelType) == "SIGNEDBYTE" {
+			return "Int8"
+		}
+	}
+	return C.GoString(C.GDALGetDataTypeName(dType))
+}
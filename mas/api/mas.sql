@@ -453,12 +453,18 @@ create or replace function mas_intersects(
               geo->>'polygon',
               'overviews',
               geo->'overviews',
+              'x_size',
+              geo->'x_size',
               'means',
               geo->'means',
               'sample_counts',
               geo->'sample_counts',
               'nodata',
-              geo->'nodata'
+              geo->'nodata',
+              'geotransform',
+              geo->'geotransform',
+              'proj_wkt',
+              geo->>'proj_wkt'
             )
               as dataset
 
@@ -576,3 +582,100 @@ create or replace function mas_timestamps(
 
   end
 $$;
+
+-- Aggregate the band statistics recorded by the crawler for each
+-- dataset over a whole collection, filtered by namespace. The
+-- statistics of the datasets that failed are nodata and skipped.
+-- The standard deviation is pooled from the per-dataset means and
+-- standard deviations.
+
+create or replace function mas_statistics(
+  gpath      text,  -- file path to search
+  namespace  text[] -- the variable name
+)
+  returns jsonb language plpgsql as $$
+  declare
+    result     jsonb;
+    shard      text;
+  begin
+
+    if gpath is null then
+      raise exception 'invalid search path';
+    end if;
+
+    perform mas_reset();
+    shard := mas_view(gpath);
+
+    if shard = '' then
+      return jsonb_build_object('statistics', '[]'::jsonb);
+    end if;
+
+    result := jsonb_build_object('statistics', coalesce((
+
+      with bands as (
+        select
+          regexp_replace(trim(geo->>'namespace'), '[^a-zA-Z0-9_]', '_', 'g')
+            as ns,
+          (geo->'mins'->>i)::float8
+            as band_min,
+          (geo->'maxs'->>i)::float8
+            as band_max,
+          (geo->'means'->>i)::float8
+            as band_mean,
+          (geo->'stddevs'->>i)::float8
+            as band_stddev,
+          coalesce((geo->>'nodata')::float8, 0)
+            as nodata
+
+        from
+          metadata
+
+        inner join
+          paths
+            on md_hash = pa_hash
+
+        inner join lateral
+          jsonb_array_elements(md_json->'geo_metadata') geo
+            on true
+
+        inner join lateral
+          generate_series(0, jsonb_array_length(coalesce(geo->'means', '[]'::jsonb)) - 1) i
+            on true
+
+        where
+          md_type = 'gdal'
+          and path_hash(gpath) = any(pa_parents)
+      ),
+      stats as (
+        select
+          ns,
+          min(band_min) as ns_min,
+          max(band_max) as ns_max,
+          avg(band_mean) as ns_mean,
+          sqrt(greatest(avg(band_stddev^2 + band_mean^2) - avg(band_mean)^2, 0)) as ns_stddev
+        from
+          bands
+        where
+          (namespace is null or ns = any(namespace))
+          and not (band_min = nodata and band_max = nodata)
+        group by
+          ns
+      )
+      select
+        jsonb_agg(jsonb_build_object(
+          'namespace', ns,
+          'min', ns_min,
+          'max', ns_max,
+          'mean', ns_mean,
+          'stddev', ns_stddev
+        ))
+      from
+        stats
+
+    ), '[]'::jsonb));
+
+    perform mas_reset();
+    return result;
+
+  end
+$$;
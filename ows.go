@@ -14,10 +14,13 @@ package main
 import (
 //	"avs"
 //"encoding/xml"
+	"bytes"
 	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
 	"io/ioutil"
 	"log"
@@ -29,6 +32,7 @@ import (
 	"regexp"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 //"strconv"
 //"os/exec"
@@ -279,12 +283,6 @@ Info.Printf("reqURL: %v\n", reqURL)
 			*params.CRS = "EPSG:4326"
 		}
 
-		var endTime *time.Time
-		if conf.Layers[idx].Accum == true {
-			step := time.Minute * time.Duration(60*24*conf.Layers[idx].StepDays+60*conf.Layers[idx].StepHours+conf.Layers[idx].StepMinutes)
-			eT := params.Time.Add(step)
-			endTime = &eT
-		}
 		if *params.Height > conf.Layers[idx].WmsMaxHeight || *params.Width > conf.Layers[idx].WmsMaxWidth {
 			http.Error(w, fmt.Sprintf("Requested width/height is too large, max width:%d, height:%d", conf.Layers[idx].WmsMaxWidth, conf.Layers[idx].WmsMaxHeight), 400)
 		}
@@ -305,73 +303,32 @@ Info.Printf("reqURL: %v\n", reqURL)
 		if styleIdx >= 0 {
 			styleLayer = &conf.Layers[idx].Styles[styleIdx]
 		}
-		geoReq := &proc.GeoTileRequest{ConfigPayLoad: proc.ConfigPayLoad{NameSpaces: styleLayer.RGBExpressions.VarList,
-			BandExpr: styleLayer.RGBExpressions,
-			Mask:     styleLayer.Mask,
-			Palette:  styleLayer.Palette,
-			ScaleParams: proc.ScaleParams{Offset: styleLayer.OffsetValue,
-				Scale: styleLayer.ScaleValue,
-				Clip:  styleLayer.ClipValue,
-			},
-			ZoomLimit:       conf.Layers[idx].ZoomLimit,
-			PolygonSegments: conf.Layers[idx].WmsPolygonSegments,
-			GrpcConcLimit:   conf.Layers[idx].GrpcWmsConcPerNode,
-			QueryLimit:      -1,
-		},
-			Collection: styleLayer.DataSource,
-			CRS:        *params.CRS,
-			BBox:       params.BBox,
-			Height:     *params.Height,
-			Width:      *params.Width,
-			StartTime:  params.Time,
-			EndTime:    endTime,
-		}
-		ctx, ctxCancel := context.WithCancel(ctx)
-//fmt.Printf("ctx: %+v\n", ctx)
 
-		defer ctxCancel()
-		errChan := make(chan error, 100)
-
-		xRes := (params.BBox[2] - params.BBox[0]) / float64(*params.Width)
-		yRes := (params.BBox[3] - params.BBox[1]) / float64(*params.Height)
-		reqRes := xRes
-		if yRes > reqRes {
-			reqRes = yRes
+		resampling := styleLayer.Resampling
+		if params.Resampling != nil {
+			resampling = *params.Resampling
 		}
-		// AVS: With v 1.1.1 and others that send EPSG:4326 the value is less than 1
-		if (*params.Version == "1.1.1") {
-			reqRes = reqRes * 100000
+
+		composite := styleLayer.Composite
+		if params.Composite != nil {
+			composite = *params.Composite
 		}
-		if conf.Layers[idx].ZoomLimit != 0.0 && reqRes > conf.Layers[idx].ZoomLimit {
-			indexer := proc.NewTileIndexer(ctx, conf.ServiceConfig.MASAddress, errChan)
-			go func() {
-				geoReq.Mask = nil
-				geoReq.QueryLimit = 1
-				indexer.In <- geoReq
-				close(indexer.In)
-			}()
 
-			go indexer.Run(*verbose)
+		// Time ranges are rendered as animations
+		if params.EndTime != nil {
+			serveWMSAnimation(ctx, params, conf, idx, styleLayer, resampling, composite, w)
+			return
+		}
 
-			hasData := false
-			for geo := range indexer.Out {
-				select {
-				case <-errChan:
-					break
-				case <-ctx.Done():
-					break
-				default:
-					if geo.NameSpace != "EmptyTile" {
-						hasData = true
-						break
-					}
-				}
+		geoReq := wmsTileRequest(params, &conf.Layers[idx], styleLayer, params.Time, resampling, composite)
+		ctx, ctxCancel := context.WithCancel(ctx)
+//fmt.Printf("ctx: %+v\n", ctx)
 
-				if hasData {
-					break
-				}
-			}
+		defer ctxCancel()
+		errChan := make(chan error, 100)
 
+		reqRes := utils.RequestResolution(*params.CRS, params.BBox, *params.Width, *params.Height)
+		if zoomedOut, hasData := proc.ZoomedOut(ctx, conf.ServiceConfig.MASAddress, styleLayer, conf.Layers[idx].ZoomLimit, geoReq, reqRes, *verbose); zoomedOut {
 			if hasData {
 				out, err := utils.GetEmptyTile(utils.DataDir+"/zoom.png", *params.Height, *params.Width)
 				if err != nil {
@@ -398,32 +355,8 @@ Info.Printf("reqURL: %v\n", reqURL)
 		tp := proc.InitTilePipeline(ctx, conf.ServiceConfig.MASAddress, conf.ServiceConfig.WorkerNodes, conf.Layers[idx].MaxGrpcRecvMsgSize, conf.Layers[idx].WmsPolygonShardConcLimit, conf.ServiceConfig.MaxGrpcBufferSize, errChan)
 		select {
 		case res := <-tp.Process(geoReq, *verbose):
-			scaleParams := utils.ScaleParams{Offset: geoReq.ScaleParams.Offset,
-				Scale: geoReq.ScaleParams.Scale,
-				Clip:  geoReq.ScaleParams.Clip,
-			}
-			norm, err := utils.Scale(res, scaleParams)
+			out, err := encodeWMSTile(res, styleLayer, geoReq, conf.Layers[idx].NoDataLegendPath)
 			if err != nil {
-				Info.Printf("Error in the utils.Scale: %v\n", err)
-				http.Error(w, err.Error(), 500)
-				return
-			}
-//fmt.Printf("norm: %+v\n", norm[0])
-
-			if len(norm) == 0 || norm[0].Width == 0 || norm[0].Height == 0 {
-				out, err := utils.GetEmptyTile(conf.Layers[idx].NoDataLegendPath, *params.Height, *params.Width)
-				if err != nil {
-					Info.Printf("Error in the utils.GetEmptyTile(): %v\n", err)
-					http.Error(w, err.Error(), 500)
-				} else {
-					w.Write(out)
-				}
-				return
-			}
-			out, err := utils.EncodePNG(norm, styleLayer.Palette)
-//fmt.Printf("out: %+v\n", out)
-			if err != nil {
-				Info.Printf("Error in the utils.EncodePNG: %v\n", err) 
 				http.Error(w, err.Error(), 500)
 				return
 			}
@@ -479,6 +412,25 @@ Info.Printf("reqURL: %v\n", reqURL)
 			styleLayer = &conf.Layers[idx].Styles[styleIdx]
 		}
 
+		// Legends are drawn from the palette of styles
+		// without a legend image
+		if len(styleLayer.LegendPath) == 0 && styleLayer.Palette != nil {
+			scaleParams := utils.ScaleParams{Offset: styleLayer.OffsetValue,
+				Scale:   styleLayer.ScaleValue,
+				Clip:    styleLayer.ClipValue,
+				Stretch: styleLayer.Stretch,
+			}
+			b, err := utils.EncodeLegend(styleLayer.Palette, scaleParams, styleLayer.LegendWidth, styleLayer.LegendHeight)
+			if err != nil {
+				Error.Printf("Error drawing legend: %v\n", err)
+				http.Error(w, err.Error(), 500)
+				return
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(b)
+			return
+		}
+
 		b, err := ioutil.ReadFile(styleLayer.LegendPath)
 		if err != nil {
 			Error.Printf("Error reading legend image: %v, %v\n", styleLayer.LegendPath, err)
@@ -491,6 +443,228 @@ Info.Printf("reqURL: %v\n", reqURL)
 		http.Error(w, fmt.Sprintf("%s not recognised.", *params.Request), 400)
 	}
 }
+// wmsTileRequest returns the tile request of a WMS GetMap at
+// a time. Accum layers and composites span a time step.
+func wmsTileRequest(params utils.WMSParams, layer *utils.Layer, styleLayer *utils.Layer, t *time.Time, resampling string, composite string) *proc.GeoTileRequest {
+	var endTime *time.Time
+	if layer.Accum || len(composite) > 0 && composite != utils.CompositeLatest {
+		step := time.Minute * time.Duration(60*24*layer.StepDays+60*layer.StepHours+layer.StepMinutes)
+		eT := t.Add(step)
+		endTime = &eT
+	}
+
+	return &proc.GeoTileRequest{ConfigPayLoad: proc.ConfigPayLoad{NameSpaces: styleLayer.RGBExpressions.VarList,
+		BandExpr: styleLayer.RGBExpressions,
+		Mask:     styleLayer.Mask,
+		Palette:  styleLayer.Palette,
+		ScaleParams: proc.ScaleParams{Offset: styleLayer.OffsetValue,
+			Scale:   styleLayer.ScaleValue,
+			Clip:    styleLayer.ClipValue,
+			Stretch: styleLayer.Stretch,
+		},
+		ZoomLimit:       layer.ZoomLimit,
+		PolygonSegments: layer.WmsPolygonSegments,
+		GrpcConcLimit:   layer.GrpcWmsConcPerNode,
+		QueryLimit:      -1,
+		Resampling:      resampling,
+		Composite:       composite,
+		Score:           styleLayer.Score,
+		TargetDate:      styleLayer.Score.TargetTime(t),
+		Terrain:         styleLayer.Terrain,
+	},
+		Collection: styleLayer.ResolutionDataSource(utils.RequestResolution(*params.CRS, params.BBox, *params.Width, *params.Height)),
+		CRS:        *params.CRS,
+		BBox:       params.BBox,
+		Height:     *params.Height,
+		Width:      *params.Width,
+		StartTime:  t,
+		EndTime:    endTime,
+	}
+}
+
+// serveWMSAnimation renders a WMS GetMap request over a time
+// range as a GIF or APNG animation with a frame per date of
+// the layer within the range. The frames are rendered by
+// concurrent tile pipelines, up to the concurrency limit of
+// the layer, and labelled with their date.
+func serveWMSAnimation(ctx context.Context, params utils.WMSParams, conf *utils.Config, idx int, styleLayer *utils.Layer, resampling string, composite string, w http.ResponseWriter) {
+	layer := &conf.Layers[idx]
+
+	format := utils.AnimationGIF
+	if params.Format != nil {
+		format = *params.Format
+	}
+	if format != utils.AnimationGIF && format != utils.AnimationAPNG {
+		http.Error(w, fmt.Sprintf("Time ranges can only be rendered as %s or %s", utils.AnimationGIF, utils.AnimationAPNG), 400)
+		return
+	}
+	if params.EndTime.Before(*params.Time) {
+		http.Error(w, "The end of the time range is before its start", 400)
+		return
+	}
+
+	var frameTimes []time.Time
+	for _, date := range layer.Dates {
+		t, err := time.Parse(utils.ISOFormat, date)
+		if err != nil {
+			continue
+		}
+		if !t.Before(*params.Time) && !t.After(*params.EndTime) {
+			frameTimes = append(frameTimes, t)
+		}
+	}
+	if len(frameTimes) == 0 {
+		http.Error(w, fmt.Sprintf("Layer %s has no dates within the time range", layer.Name), 400)
+		return
+	}
+	if len(frameTimes) > layer.WmsMaxAnimationFrames {
+		http.Error(w, fmt.Sprintf("The time range has %d dates, max frames:%d", len(frameTimes), layer.WmsMaxAnimationFrames), 400)
+		return
+	}
+	if pixels := len(frameTimes) * *params.Width * *params.Height; pixels > layer.WmsMaxAnimationPixels {
+		http.Error(w, fmt.Sprintf("The animation has %d pixels, max pixels:%d", pixels, layer.WmsMaxAnimationPixels), 400)
+		return
+	}
+	reqRes := utils.RequestResolution(*params.CRS, params.BBox, *params.Width, *params.Height)
+	zoomReq := wmsTileRequest(params, layer, styleLayer, &frameTimes[0], resampling, composite)
+	if zoomedOut, _ := proc.ZoomedOut(ctx, conf.ServiceConfig.MASAddress, styleLayer, layer.ZoomLimit, zoomReq, reqRes, *verbose); zoomedOut {
+		http.Error(w, "Animations are not available at this zoom level, please zoom in", 400)
+		return
+	}
+
+	ctx, ctxCancel := context.WithCancel(ctx)
+	defer ctxCancel()
+
+	frames := make([]image.Image, len(frameTimes))
+	labels := make([]string, len(frameTimes))
+	errChan := make(chan error, len(frameTimes))
+	limiter := make(chan struct{}, layer.WmsAnimationConcLimit)
+
+	var wg sync.WaitGroup
+	for i := range frameTimes {
+		labels[i] = frameTimes[i].Format("2006-01-02")
+		if layer.StepHours != 0 || layer.StepMinutes != 0 {
+			labels[i] = frameTimes[i].Format("2006-01-02 15:04")
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			limiter <- struct{}{}
+			defer func() { <-limiter }()
+			if ctx.Err() != nil {
+				return
+			}
+
+			geoReq := wmsTileRequest(params, layer, styleLayer, &frameTimes[i], resampling, composite)
+			frame, err := renderWMSFrame(ctx, conf, idx, styleLayer, geoReq)
+			if err != nil {
+				errChan <- err
+				ctxCancel()
+				return
+			}
+			frames[i] = frame
+		}(i)
+	}
+	wg.Wait()
+
+	select {
+	case err := <-errChan:
+		Info.Printf("Error in the WMS animation: %v\n", err)
+		http.Error(w, err.Error(), 500)
+		return
+	default:
+	}
+	if ctx.Err() != nil {
+		Error.Printf("Context cancelled with message: %v\n", ctx.Err())
+		http.Error(w, ctx.Err().Error(), 500)
+		return
+	}
+
+	out, err := utils.EncodeAnimation(frames, labels, format, utils.AnimationFrameDelay)
+	if err != nil {
+		Info.Printf("Error in the utils.EncodeAnimation: %v\n", err)
+		http.Error(w, err.Error(), 500)
+		return
+	}
+	w.Header().Set("Content-Type", format)
+	w.Write(out)
+}
+
+// renderWMSFrame renders a frame of a WMS animation through
+// the tile pipeline
+func renderWMSFrame(ctx context.Context, conf *utils.Config, idx int, styleLayer *utils.Layer, geoReq *proc.GeoTileRequest) (image.Image, error) {
+	timeoutCtx, timeoutCancel := context.WithTimeout(ctx, time.Duration(conf.Layers[idx].WmsTimeout)*time.Second)
+	defer timeoutCancel()
+
+	errChan := make(chan error, 100)
+	tp := proc.InitTilePipeline(timeoutCtx, conf.ServiceConfig.MASAddress, conf.ServiceConfig.WorkerNodes, conf.Layers[idx].MaxGrpcRecvMsgSize, conf.Layers[idx].WmsPolygonShardConcLimit, conf.ServiceConfig.MaxGrpcBufferSize, errChan)
+	select {
+	case res := <-tp.Process(geoReq, *verbose):
+		out, err := encodeWMSTile(res, styleLayer, geoReq, conf.Layers[idx].NoDataLegendPath)
+		if err != nil {
+			return nil, err
+		}
+		return png.Decode(bytes.NewReader(out))
+	case err := <-errChan:
+		return nil, err
+	case <-timeoutCtx.Done():
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, fmt.Errorf("WMS animation frame of %v timed out, threshold:%v seconds", geoReq.StartTime.Format(utils.ISOFormat), conf.Layers[idx].WmsTimeout)
+	}
+}
+
+// encodeWMSTile renders the rasters of a WMS tile as a PNG
+// image, or the nodata image of the layer if the tile is empty
+func encodeWMSTile(res []utils.Raster, styleLayer *utils.Layer, geoReq *proc.GeoTileRequest, noDataLegendPath string) ([]byte, error) {
+	scaleParams := utils.ScaleParams{Offset: geoReq.ScaleParams.Offset,
+		Scale:   geoReq.ScaleParams.Scale,
+		Clip:    geoReq.ScaleParams.Clip,
+		Stretch: geoReq.ScaleParams.Stretch,
+	}
+	// Reliefs are followed by the hillshade of
+	// their elevation
+	var shade utils.Raster
+	if styleLayer.Terrain.IsRelief() && len(res) == 2 {
+		shade = res[1]
+		res = res[:1]
+	}
+
+	// Classified palettes colour the data values
+	var norm []*utils.ByteRaster
+	var err error
+	if styleLayer.Palette.IsClassified() {
+		norm, err = utils.Classify(res, styleLayer.Palette)
+	} else {
+		norm, err = utils.Scale(res, scaleParams)
+	}
+	if err != nil {
+		Info.Printf("Error in the utils.Scale: %v\n", err)
+		return nil, err
+	}
+
+	if len(norm) == 0 || norm[0].Width == 0 || norm[0].Height == 0 {
+		out, err := utils.GetEmptyTile(noDataLegendPath, geoReq.Height, geoReq.Width)
+		if err != nil {
+			Info.Printf("Error in the utils.GetEmptyTile(): %v\n", err)
+		}
+		return out, err
+	}
+
+	var out []byte
+	if shade != nil {
+		out, err = utils.EncodeReliefPNG(norm[0], shade, styleLayer.Palette)
+	} else {
+		out, err = utils.EncodePNG(norm, styleLayer.Palette)
+	}
+	if err != nil {
+		Info.Printf("Error in the utils.EncodePNG: %v\n", err)
+	}
+	return out, err
+}
+
 func Save_aggregate_netcdf (masterTempFile string) {
 /*
 AVS:
@@ -586,11 +760,57 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 			http.Error(w, fmt.Sprintf("Request %s should contain a valid ISO 'crs/srs' parameter.", reqURL), 400)
 			return
 		}
+
+		var clipGeom *utils.ClipGeometry
+		if params.Clip != nil || params.ClipID != nil {
+			var clipText string
+			if params.Clip != nil {
+				clipText = *params.Clip
+			} else {
+				clipText, err = utils.LoadClipGeometry(conf.ServiceConfig.ClipDir, *params.ClipID)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("%v: %s", err, reqURL), 400)
+					return
+				}
+			}
+
+			clipEPSG, err := utils.ExtractEPSGCode(*params.CRS)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid CRS code %s", *params.CRS), 400)
+				return
+			}
+
+			clipGeom, err = utils.ParseClipGeometry(clipText, clipEPSG)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Invalid clip geometry: %v", err), 400)
+				return
+			}
+
+			maxClipArea := conf.Layers[idx].WcsMaxClipArea
+			if maxClipArea > 0 && clipGeom.Area > maxClipArea {
+				http.Error(w, fmt.Sprintf("The area of the clip geometry (%v) exceeds the maximum allowed area (%v)", clipGeom.Area, maxClipArea), 400)
+				return
+			}
+
+			// Workers receive the clip geometry in the POST body
+			// in the request CRS together with an explicit bbox
+			if len(params.BBox) != 4 {
+				params.BBox = clipGeom.BBox
+			}
+			reqURL = removeURLParams(reqURL, "clip", "clip_id", "bbox")
+			reqURL += fmt.Sprintf("&bbox=%v,%v,%v,%v", params.BBox[0], params.BBox[1], params.BBox[2], params.BBox[3])
+		}
+
 		if len(params.BBox) != 4 {
 			http.Error(w, fmt.Sprintf("Request %s should contain a valid 'bbox' parameter.", reqURL), 400)
 			return
 		}
-		if params.Height == nil || params.Width == nil {
+		useResolution := params.Native || params.ResX != nil || params.ResY != nil
+		if useResolution && !params.Native && (params.ResX == nil || params.ResY == nil || *params.ResX <= 0 || *params.ResY <= 0) {
+			http.Error(w, fmt.Sprintf("Request %s should contain positive 'resx' and 'resy' parameters.", reqURL), 400)
+			return
+		}
+		if !useResolution && (params.Height == nil || params.Width == nil) {
 			http.Error(w, fmt.Sprintf("Request %s should contain valid 'width' and 'height' parameters.", reqURL), 400)
 			return
 		}
@@ -629,6 +849,27 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 			styleLayer = &conf.Layers[idx].Styles[styleIdx]
 		}
 
+		resampling := styleLayer.Resampling
+		if params.Interpolation != nil {
+			resampling = *params.Interpolation
+		}
+
+		composite := styleLayer.Composite
+		if params.Composite != nil {
+			composite = *params.Composite
+		}
+		if endTime == nil && len(composite) > 0 && composite != utils.CompositeLatest {
+			// Composites of non accum layers span a time step
+			step := time.Minute * time.Duration(60*24*conf.Layers[idx].StepDays+60*conf.Layers[idx].StepHours+conf.Layers[idx].StepMinutes)
+			eT := params.Time.Add(step)
+			endTime = &eT
+		}
+
+		var cutline string
+		if clipGeom != nil {
+			cutline = clipGeom.WKT
+		}
+
 		maxXTileSize := conf.Layers[idx].WcsMaxTileWidth
 		maxYTileSize := conf.Layers[idx].WcsMaxTileHeight
 		checkpointThreshold := 300
@@ -640,20 +881,28 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 		_, isWorker := query["wbbox"]
 
 		getGeoTileRequest := func(width int, height int, bbox []float64, offX int, offY int) *proc.GeoTileRequest {
+			reqRes := utils.RequestResolution(*params.CRS, bbox, width, height)
 			geoReq := &proc.GeoTileRequest{ConfigPayLoad: proc.ConfigPayLoad{NameSpaces: styleLayer.RGBExpressions.VarList,
 				BandExpr: styleLayer.RGBExpressions,
 				Mask:     styleLayer.Mask,
 				Palette:  styleLayer.Palette,
 				ScaleParams: proc.ScaleParams{Offset: styleLayer.OffsetValue,
-					Scale: styleLayer.ScaleValue,
-					Clip:  styleLayer.ClipValue,
+					Scale:   styleLayer.ScaleValue,
+					Clip:    styleLayer.ClipValue,
+					Stretch: styleLayer.Stretch,
 				},
 				ZoomLimit:       0.0,
 				PolygonSegments: conf.Layers[idx].WcsPolygonSegments,
 				GrpcConcLimit:   conf.Layers[idx].GrpcWcsConcPerNode,
 				QueryLimit:      -1,
+				Resampling:      resampling,
+				Composite:       composite,
+				Score:           styleLayer.Score,
+				TargetDate:      styleLayer.Score.TargetTime(params.Time),
+				Terrain:         styleLayer.Terrain,
+				Cutline:         cutline,
 			},
-				Collection: styleLayer.DataSource,
+				Collection: styleLayer.ResolutionDataSource(reqRes),
 				CRS:        *params.CRS,
 				BBox:       bbox,
 				Height:     height,
@@ -676,6 +925,46 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 			return
 		}
 
+		if useResolution {
+			if isWorker {
+				msg := "WCS: worker requests must specify width and height"
+				Info.Printf(msg)
+				http.Error(w, msg, 500)
+				return
+			}
+
+			geoReq := getGeoTileRequest(0, 0, params.BBox, 0, 0)
+			grid, err := proc.ComputeNativeGrid(ctx, geoReq, conf.ServiceConfig.MASAddress, epsg, *verbose)
+			if !params.Native {
+				if err != nil {
+					// Without source metadata the grid is anchored at the requested bbox
+					if *verbose {
+						Info.Printf("WCS: native grid unavailable, output is not snapped: %v", err)
+					}
+					grid = &utils.NativeGrid{OriginX: params.BBox[0], OriginY: params.BBox[3]}
+				}
+				grid.ResX = *params.ResX
+				grid.ResY = *params.ResY
+			} else if err != nil {
+				errMsg := fmt.Sprintf("WCS: failed to compute native resolution: %v", err)
+				Info.Printf(errMsg)
+				http.Error(w, errMsg, 500)
+				return
+			}
+
+			bbox, width, height := grid.SnapBBox(params.BBox)
+			params.BBox = bbox
+			params.Width = &width
+			params.Height = &height
+			if *verbose {
+				Info.Printf("WCS: Output grid: bbox=%v, width=%v, height=%v", bbox, width, height)
+			}
+
+			// Workers receive the snapped grid as an explicit bbox and size
+			reqURL = removeURLParams(reqURL, "resx", "resy", "width", "height", "bbox")
+			reqURL += fmt.Sprintf("&bbox=%v,%v,%v,%v&width=%d&height=%d", bbox[0], bbox[1], bbox[2], bbox[3], width, height)
+		}
+
 		if *params.Width <= 0 || *params.Height <= 0 {
 			if isWorker {
 				msg := "WCS: worker width or height negative"
@@ -844,7 +1133,12 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 				}
 
 				trans := &http.Transport{}
-				req, err := http.NewRequest("GET", queryURL, nil)
+				var req *http.Request
+				if clipGeom != nil {
+					req, err = http.NewRequest("POST", queryURL, strings.NewReader(clipGeom.WKT))
+				} else {
+					req, err = http.NewRequest("GET", queryURL, nil)
+				}
 				if err != nil {
 					errMsg := fmt.Sprintf("WCS: worker NewRequest error: %v", err)
 					Info.Printf(errMsg)
@@ -905,6 +1199,12 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 
 		isInit := false
 
+		// Reliefs also return the hillshade of their elevation
+		nBands := len(styleLayer.RGBProducts)
+		if styleLayer.Terrain.IsRelief() {
+			nBands++
+		}
+
 		tp := proc.InitTilePipeline(ctx, conf.ServiceConfig.MASAddress, conf.ServiceConfig.WorkerNodes, conf.Layers[idx].MaxGrpcRecvMsgSize, conf.Layers[idx].WcsPolygonShardConcLimit, conf.ServiceConfig.MaxGrpcBufferSize, errChan)
 		for ir, geoReq := range workerTileRequests[0] {
 			if *verbose {
@@ -914,7 +1214,7 @@ func serveWCS(ctx context.Context, params utils.WCSParams, conf *utils.Config, r
 			select {
 			case res := <-tp.Process(geoReq, *verbose):
 				if !isInit {
-					hDstDS, masterTempFile, err = utils.EncodeGdalOpen(conf.ServiceConfig.TempDir, 1024, 256, driverFormat, geot, epsg, res, *params.Width, *params.Height, len(styleLayer.RGBProducts))
+					hDstDS, masterTempFile, err = utils.EncodeGdalOpen(conf.ServiceConfig.TempDir, 1024, 256, driverFormat, geot, epsg, res, *params.Width, *params.Height, nBands)
 					if err != nil {
 						os.Remove(masterTempFile)
 						errMsg := fmt.Sprintf("EncodeGdalOpen() failed: %v", err)
@@ -1123,6 +1423,317 @@ func avs_write() {
 // AVS --------------------------------------------
 
 
+// wpsTemporalAggregation returns the temporal aggregation of
+// the time series requested by the WPS parameters. Anomalies
+// are computed against the baseline of the request, or of the
+// process if the request does not specify one.
+func wpsTemporalAggregation(params utils.WPSParams, process utils.Process) (proc.TemporalAggregation, error) {
+	ta := proc.TemporalAggregation{Period: params.Period, Reducer: params.Aggregate, Anomaly: params.Anomaly}
+	if ta.IsZero() {
+		if len(ta.Reducer) > 0 {
+			return ta, fmt.Errorf("The aggregate input requires a period")
+		}
+		return ta, nil
+	}
+
+	if process.ProcessType == utils.WPSProcessProfile {
+		return ta, fmt.Errorf("Profile processes do not support temporal aggregation")
+	}
+
+	if len(ta.Anomaly) == 0 {
+		return ta, nil
+	}
+
+	baselineStart, baselineEnd := params.BaselineStart, params.BaselineEnd
+	if len(baselineStart) == 0 {
+		baselineStart = process.BaselineStart
+	}
+	if len(baselineEnd) == 0 {
+		baselineEnd = process.BaselineEnd
+	}
+	if len(baselineStart) == 0 || len(baselineEnd) == 0 {
+		return ta, fmt.Errorf("Anomalies require a baseline_start and a baseline_end")
+	}
+
+	var err error
+	if ta.BaselineStart, err = utils.ParseWPSDate(baselineStart); err != nil {
+		return ta, fmt.Errorf("Invalid baseline_start: %v", baselineStart)
+	}
+	if ta.BaselineEnd, err = utils.ParseWPSDate(baselineEnd); err != nil {
+		return ta, fmt.Errorf("Invalid baseline_end: %v", baselineEnd)
+	}
+	if !ta.BaselineStart.Before(ta.BaselineEnd) {
+		return ta, fmt.Errorf("The baseline_start must be before the baseline_end")
+	}
+	return ta, nil
+}
+
+// runWPSProcess runs the drill pipeline of every data source
+// of a WPS process and returns their outputs. Requests with
+// several features produce a single output per data source
+// whose rows are keyed by feature. feats are in the CRS of
+// the request and wgs84Feats are the same features in WGS84.
+// If progress is not nil, it is called after each data source
+// completes. The process fails when its timeout is exceeded
+// unless partial results are requested, in which case the
+// outputs only contain the dates drilled so far and the
+// returned flag is set.
+func runWPSProcess(ctx context.Context, params utils.WPSParams, process utils.Process, feats [][]byte, wgs84Feats [][]byte, conf *utils.Config, progress func(done int, total int)) ([]*proc.DrillOutput, bool, error) {
+	var outputs []*proc.DrillOutput
+	partial := false
+
+	deadline := time.Now().Add(time.Duration(process.WpsTimeout) * time.Second)
+	var ctxCancel context.CancelFunc
+	if params.Partial {
+		ctx, ctxCancel = context.WithCancel(ctx)
+	} else {
+		ctx, ctxCancel = context.WithDeadline(ctx, deadline)
+	}
+	defer ctxCancel()
+	errChan := make(chan error, 100)
+
+	temporal, err := wpsTemporalAggregation(params, process)
+	if err != nil {
+		return nil, false, err
+	}
+
+	for ids, dataSource := range process.DataSources {
+		bandExpr := dataSource.RGBExpressions
+		if len(params.Bands) > 0 {
+			var names []string
+			for _, name := range params.Bands {
+				for _, exprName := range bandExpr.ExprNames {
+					if name == exprName {
+						names = append(names, name)
+						break
+					}
+				}
+			}
+
+			// Data sources without any of the requested
+			// bands are left out of the outputs
+			if len(names) == 0 {
+				outputs = append(outputs, nil)
+				if progress != nil {
+					progress(ids+1, len(process.DataSources))
+				}
+				continue
+			}
+
+			bandExpr, err = utils.SelectBandExpressions(bandExpr, names)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+
+		log.Printf("WPS: Processing '%v' (%d of %d)", dataSource.DataSource, ids+1, len(process.DataSources))
+
+		startDateTime := time.Time{}
+		stStartInput, errStartInput := time.Parse(utils.ISOFormat, *params.StartDateTime)
+		if errStartInput != nil {
+			if len(*params.StartDateTime) > 0 {
+				log.Printf("WPS: invalid input start date '%v' with error '%v'", *params.StartDateTime, errStartInput)
+			}
+			startDateTimeStr := strings.TrimSpace(dataSource.StartISODate)
+			if len(startDateTimeStr) > 0 {
+				st, errStart := time.Parse(utils.ISOFormat, startDateTimeStr)
+				if errStart != nil {
+					log.Printf("WPS: Failed to parse start date '%v' into ISO format with error: %v, defaulting to no start date", startDateTimeStr, errStart)
+				} else {
+					startDateTime = st
+				}
+			}
+		} else {
+			startDateTime = stStartInput
+		}
+
+		endDateTime := time.Now().UTC()
+		stEndInput, errEndInput := time.Parse(utils.ISOFormat, *params.EndDateTime)
+		if errEndInput != nil {
+			if len(*params.EndDateTime) > 0 {
+				log.Printf("WPS: invalid input end date '%v' with error '%v'", *params.EndDateTime, errEndInput)
+			}
+			endDateTimeStr := strings.TrimSpace(dataSource.EndISODate)
+			if len(endDateTimeStr) > 0 && strings.ToLower(endDateTimeStr) != "now" {
+				dt, errEnd := time.Parse(utils.ISOFormat, endDateTimeStr)
+				if errEnd != nil {
+					log.Printf("WPS: Failed to parse end date '%s' into ISO format with error: %v, defaulting to now()", endDateTimeStr, errEnd)
+				} else {
+					endDateTime = dt
+				}
+			}
+		} else {
+			if !time.Time.IsZero(stEndInput) {
+				endDateTime = stEndInput
+			}
+		}
+
+		geoReqs := make([]proc.GeoDrillRequest, len(feats))
+		for i, feat := range feats {
+			geoReqs[i] = proc.GeoDrillRequest{Geometry: string(feat),
+				CRS:         params.CRS,
+				Collection:  dataSource.DataSource,
+				NameSpaces:  bandExpr.VarList,
+				BandExpr:    bandExpr,
+				Statistics:  params.Statistics,
+				Histogram:   process.ProcessType == utils.WPSProcessHistogram,
+				ClassLabels: dataSource.ClassLabels,
+				FeatureID:   params.FeatureIDs[i],
+				StartTime:   startDateTime,
+				EndTime:     endDateTime,
+			}
+			if process.ProcessType == utils.WPSProcessProfile {
+				geoReqs[i].ProfileSpacing = params.Spacing
+			}
+			if params.CRS != utils.WPSDefaultCRS {
+				geoReqs[i].IndexGeometry = string(wgs84Feats[i])
+			}
+			geoReqs[i].Exact = process.Exact
+			if params.Exact != nil {
+				geoReqs[i].Exact = *params.Exact
+			}
+			geoReqs[i].Temporal = temporal
+		}
+
+		dp := proc.InitDrillPipeline(ctx, conf.ServiceConfig.MASAddress, conf.ServiceConfig.WorkerNodes, process.IdentityTol, process.DpTol, errChan)
+		dp.Deadline = deadline
+		dp.Partial = params.Partial
+
+		if params.BandStrides > 0 {
+			dataSource.BandStrides = params.BandStrides
+		}
+		if dataSource.BandStrides <= 0 {
+			dataSource.BandStrides = 1
+		}
+
+		approx := *process.Approx
+		if params.Approx != nil {
+			approx = *params.Approx
+		}
+
+		var drillOut chan *proc.DrillOutput
+		if len(geoReqs) == 1 {
+			drillOut = dp.Process(geoReqs[0], dataSource.BandStrides, approx)
+		} else {
+			featureColumn := "feature"
+			if len(params.IDProperty) > 0 {
+				featureColumn = params.IDProperty
+			}
+			drillOut = dp.ProcessFeatures(geoReqs, featureColumn, dataSource.BandStrides, approx, process.FeatureConcLimit)
+		}
+
+		select {
+		case res := <-drillOut:
+			outputs = append(outputs, res)
+			partial = partial || res.Partial
+		case err := <-errChan:
+			// The data sources failing after the deadline of
+			// a partial request are left out of the outputs
+			if params.Partial && time.Now().After(deadline) {
+				Info.Printf("WPS: '%v' timed out: %v\n", dataSource.DataSource, err)
+				outputs = append(outputs, nil)
+				partial = true
+				break
+			}
+			if ctx.Err() == context.DeadlineExceeded {
+				err = wpsTimeoutError(process)
+			}
+			Info.Printf("Error in the pipeline: %v\n", err)
+			return nil, false, err
+		case <-ctx.Done():
+			Error.Printf("Context cancelled with message: %v\n", ctx.Err())
+			if ctx.Err() == context.DeadlineExceeded {
+				Error.Printf("WPS pipeline timed out, threshold:%v seconds", process.WpsTimeout)
+				return nil, false, wpsTimeoutError(process)
+			}
+			return nil, false, ctx.Err()
+		}
+
+		if progress != nil {
+			progress(ids+1, len(process.DataSources))
+		}
+	}
+
+	return outputs, partial, nil
+}
+
+func wpsTimeoutError(process utils.Process) error {
+	return fmt.Errorf("The process %s timed out after %d seconds, partial=true returns the dates completed so far", process.Identifier, process.WpsTimeout)
+}
+
+// wpsPartialWarning is the warning of the outputs of a
+// partial request cut short by the timeout of the process
+func wpsPartialWarning(process utils.Process) string {
+	return fmt.Sprintf("The process %s timed out after %d seconds, the outputs only contain the dates completed so far.", process.Identifier, process.WpsTimeout)
+}
+
+// encodeWPSOutputs encodes the outputs of the data sources of
+// a process in the requested MIME type. The output templates of
+// the data sources are used if no MIME type is requested. The
+// outputs of the data sources left out of the request are nil.
+func encodeWPSOutputs(outputs []*proc.DrillOutput, process utils.Process, mimeType string, tempDir string) ([]byte, error) {
+	var drilled []*proc.DrillOutput
+	for _, out := range outputs {
+		if out != nil {
+			drilled = append(drilled, out)
+		}
+	}
+
+	switch mimeType {
+	case utils.WPSMimeCSV:
+		return proc.EncodeDrillCSV(drilled)
+	case utils.WPSMimeJSON:
+		return proc.EncodeDrillJSON(drilled)
+	case utils.WPSMimeNetCDF:
+		return proc.EncodeDrillNetCDF(drilled, tempDir)
+	}
+
+	var result string
+	suffix := fmt.Sprintf("_%04d", rand.Intn(1000))
+	for i, out := range outputs {
+		if out == nil {
+			continue
+		}
+		res, err := out.Render(process.DataSources[i].MetadataURL, suffix)
+		if err != nil {
+			return nil, err
+		}
+		result += res
+	}
+	return []byte(result), nil
+}
+
+// wpsDocumentOutputs returns the process outputs embedded
+// in the ExecuteResponse document of a WPS process
+func wpsDocumentOutputs(outputs []*proc.DrillOutput, process utils.Process, mimeType string, tempDir string) (string, error) {
+	data, err := encodeWPSOutputs(outputs, process, mimeType, tempDir)
+	if err != nil {
+		return "", err
+	}
+
+	if len(mimeType) == 0 {
+		return string(data), nil
+	}
+	return utils.EncodeWPSComplexData(mimeType, data), nil
+}
+
+// writeWPSException writes the ExceptionReport of an invalid
+// WPS request. Errors other than WPS exceptions are reported
+// as invalid parameter values.
+func writeWPSException(w http.ResponseWriter, err error) {
+	exc, ok := err.(*utils.WPSException)
+	if !ok {
+		exc = &utils.WPSException{Code: utils.WPSExceptionInvalidParameter, Text: err.Error()}
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(400)
+	tplErr := utils.ExecuteWriteTemplateFile(w, exc, utils.DataDir+"/templates/WPS_ExceptionReport.tpl")
+	if tplErr != nil {
+		Error.Printf("Failed to write the WPS exception '%v': %v\n", err, tplErr)
+	}
+}
+
 func serveWPS(ctx context.Context, params utils.WPSParams, conf *utils.Config, reqURL string, w http.ResponseWriter) {
 
 	if params.Request == nil {
@@ -1170,117 +1781,270 @@ func serveWPS(ctx context.Context, params utils.WPSParams, conf *utils.Config, r
 			return
 		}
 
-		var feat []byte
-		geom := params.FeatCol.Features[0].Geometry
-		switch geom := geom.(type) {
+		if len(params.FeatCol.Features) > process.MaxFeatures {
+			Info.Printf("The request contains %d features, more than the maximum of %d.\n", len(params.FeatCol.Features), process.MaxFeatures)
+			http.Error(w, fmt.Sprintf("The request contains too many features. The maximum number of features is %d.", process.MaxFeatures), 400)
+			return
+		}
 
-		case *geo.Point:
-			feat, _ = json.Marshal(&geo.Feature{Type: "Feature", Geometry: geom})
+		if err := utils.ValidateWPSInputs(params.Inputs, process); err != nil {
+			Info.Printf("Invalid WPS input: %v\n", err)
+			writeWPSException(w, err)
+			return
+		}
 
-		case *geo.Polygon, *geo.MultiPolygon:
-			area := utils.GetArea(geom)
-			log.Println("Requested polygon has an area of", area)
-			if area == 0.0 || area > process.MaxArea {
-				Info.Printf("The requested area %.02f, is too large.\n", area)
-				http.Error(w, "The requested area is too large. Please try with a smaller one.", 400)
+		for _, name := range params.Bands {
+			found := false
+			for _, dataSource := range process.DataSources {
+				for _, exprName := range dataSource.RGBExpressions.ExprNames {
+					found = found || exprName == name
+				}
+			}
+			if !found {
+				writeWPSException(w, &utils.WPSException{Code: utils.WPSExceptionInvalidParameter, Locator: "bands", Text: fmt.Sprintf("unknown band: %s", name)})
 				return
 			}
-			feat, _ = json.Marshal(&geo.Feature{Type: "Feature", Geometry: geom})
+		}
 
-		default:
-			http.Error(w, "Geometry not supported. Only Features containing Polygon or MultiPolygon are available..", 400)
+		if _, err := wpsTemporalAggregation(params, process); err != nil {
+			Info.Printf("Invalid temporal aggregation: %v\n", err)
+			writeWPSException(w, err)
 			return
 		}
 
-		var result string
-		ctx, ctxCancel := context.WithCancel(ctx)
-		defer ctxCancel()
-		errChan := make(chan error, 100)
-		suffix := fmt.Sprintf("_%04d", rand.Intn(1000))
+		if process.ProcessType == utils.WPSProcessProfile && params.Spacing <= 0 {
+			params.Spacing = process.ProfileSpacing
+		}
 
-		for ids, dataSource := range process.DataSources {
-			log.Printf("WPS: Processing '%v' (%d of %d)", dataSource.DataSource, ids+1, len(process.DataSources))
+		if len(params.CRS) == 0 {
+			params.CRS = utils.WPSDefaultCRS
+		}
 
-			startDateTime := time.Time{}
-			stStartInput, errStartInput := time.Parse(utils.ISOFormat, *params.StartDateTime)
-			if errStartInput != nil {
-				if len(*params.StartDateTime) > 0 {
-					log.Printf("WPS: invalid input start date '%v' with error '%v'", *params.StartDateTime, errStartInput)
+		feats := make([][]byte, len(params.FeatCol.Features))
+		wgs84Feats := make([][]byte, len(params.FeatCol.Features))
+		for i, feature := range params.FeatCol.Features {
+			// Geometries in other CRSs are reprojected to WGS84
+			// to check their area and query MAS whereas workers
+			// reproject them to the CRS of each dataset
+			wgs84Geom := feature.Geometry
+			if params.CRS != utils.WPSDefaultCRS {
+				geomJSON, _ := json.Marshal(feature.Geometry)
+				wgs84JSON, err := utils.TransformGeometry(geomJSON, params.CRS, utils.WPSDefaultCRS)
+				if err != nil {
+					Info.Printf("Failed to reproject feature %s: %v\n", params.FeatureIDs[i], err)
+					writeWPSException(w, &utils.WPSException{Code: utils.WPSExceptionInvalidParameter, Locator: "crs", Text: fmt.Sprintf("feature %s: %v", params.FeatureIDs[i], err)})
+					return
 				}
-				startDateTimeStr := strings.TrimSpace(dataSource.StartISODate)
-				if len(startDateTimeStr) > 0 {
-					st, errStart := time.Parse(utils.ISOFormat, startDateTimeStr)
-					if errStart != nil {
-						log.Printf("WPS: Failed to parse start date '%v' into ISO format with error: %v, defaulting to no start date", startDateTimeStr, errStart)
-					} else {
-						startDateTime = st
-					}
+
+				var wgs84Feat geo.Feature
+				err = json.Unmarshal([]byte(fmt.Sprintf(`{"type":"Feature","geometry":%s}`, wgs84JSON)), &wgs84Feat)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Failed to parse the reprojected feature %s: %v", params.FeatureIDs[i], err), 500)
+					return
 				}
-			} else {
-				startDateTime = stStartInput
+				wgs84Geom = wgs84Feat.Geometry
 			}
 
-			endDateTime := time.Now().UTC()
-			stEndInput, errEndInput := time.Parse(utils.ISOFormat, *params.EndDateTime)
-			if errEndInput != nil {
-				if len(*params.EndDateTime) > 0 {
-					log.Printf("WPS: invalid input end date '%v' with error '%v'", *params.EndDateTime, errEndInput)
+			if process.ProcessType == utils.WPSProcessProfile {
+				geomJSON, _ := json.Marshal(wgs84Geom)
+				coords, err := utils.LineStringCoordinates(geomJSON)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("Geometry not supported. Profile processes only accept Features containing a LineString: %v", err), 400)
+					return
 				}
-				endDateTimeStr := strings.TrimSpace(dataSource.EndISODate)
-				if len(endDateTimeStr) > 0 && strings.ToLower(endDateTimeStr) != "now" {
-					dt, errEnd := time.Parse(utils.ISOFormat, endDateTimeStr)
-					if errEnd != nil {
-						log.Printf("WPS: Failed to parse end date '%s' into ISO format with error: %v, defaulting to now()", endDateTimeStr, errEnd)
-					} else {
-						endDateTime = dt
-					}
+				nSamples := utils.ProfileSampleCount(coords, params.Spacing)
+				if nSamples > process.MaxProfileSamples {
+					Info.Printf("The requested profile has %d samples, more than the maximum of %d.\n", nSamples, process.MaxProfileSamples)
+					http.Error(w, fmt.Sprintf("The profile of feature %s has too many samples. The maximum number of samples is %d, please increase the spacing.", params.FeatureIDs[i], process.MaxProfileSamples), 400)
+					return
 				}
-			} else {
-				if !time.Time.IsZero(stEndInput) {
-					endDateTime = stEndInput
+				// Profiles are sampled along WGS84 geometries
+				feats[i], _ = json.Marshal(&geo.Feature{Type: "Feature", Geometry: wgs84Geom})
+				wgs84Feats[i] = feats[i]
+				continue
+			}
+
+			switch geom := wgs84Geom.(type) {
+
+			case *geo.Point:
+
+			case *geo.Polygon, *geo.MultiPolygon:
+				area := utils.GetArea(geom)
+				log.Println("Requested polygon has an area of", area)
+				if area == 0.0 || area > process.MaxArea {
+					Info.Printf("The requested area %.02f, is too large.\n", area)
+					http.Error(w, fmt.Sprintf("The requested area of feature %s is too large. Please try with a smaller one.", params.FeatureIDs[i]), 400)
+					return
 				}
+
+			default:
+				http.Error(w, "Geometry not supported. Only Features containing Polygon or MultiPolygon are available..", 400)
+				return
 			}
+			feats[i], _ = json.Marshal(&geo.Feature{Type: "Feature", Geometry: feature.Geometry})
+			wgs84Feats[i], _ = json.Marshal(&geo.Feature{Type: "Feature", Geometry: wgs84Geom})
+		}
 
-			geoReq := proc.GeoDrillRequest{Geometry: string(feat),
-				CRS:        "EPSG:4326",
-				Collection: dataSource.DataSource,
-				NameSpaces: dataSource.RGBExpressions.VarList,
-				BandExpr:   dataSource.RGBExpressions,
-				StartTime:  startDateTime,
-				EndTime:    endDateTime,
+		if process.ProcessType == utils.WPSProcessProfile {
+			params.CRS = utils.WPSDefaultCRS
+		}
+
+		tplPath := utils.DataDir + "/templates/WPS_Execute.tpl"
+		if params.StoreResponse {
+			jobID, err := utils.NewWPSJobID()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Failed to create WPS job: %v", err), 500)
+				return
 			}
 
-			dp := proc.InitDrillPipeline(ctx, conf.ServiceConfig.MASAddress, conf.ServiceConfig.WorkerNodes, process.IdentityTol, process.DpTol, errChan)
+			resultDir := conf.ServiceConfig.WpsResultDir
+			retention := time.Duration(conf.ServiceConfig.WpsResultRetention) * time.Hour
+			if err := utils.PurgeWPSJobs(resultDir, retention); err != nil {
+				Error.Printf("Failed to purge expired WPS jobs: %v\n", err)
+			}
 
-			if dataSource.BandStrides <= 0 {
-				dataSource.BandStrides = 1
+			owsPath := "/ows"
+			if len(conf.ServiceConfig.NameSpace) > 0 && conf.ServiceConfig.NameSpace != "." {
+				owsPath += "/" + conf.ServiceConfig.NameSpace
 			}
-			proc := dp.Process(geoReq, suffix, dataSource.MetadataURL, dataSource.BandStrides, *process.Approx, *verbose)
+			statusLocation := fmt.Sprintf("http://%s%s?service=WPS&request=GetStatus&jobid=%s", conf.ServiceConfig.OWSHostname, owsPath, jobID)
 
-			select {
-			case res := <-proc:
-				result += res
-			case err := <-errChan:
-				Info.Printf("Error in the pipeline: %v\n", err)
+			accepted := utils.NewWPSExecuteResponse(statusLocation, utils.WPSProcessAccepted, fmt.Sprintf(`The service "%s" has been accepted.`, process.Identifier))
+			err = utils.WriteWPSStatus(resultDir, jobID, accepted, tplPath)
+			if err != nil {
 				http.Error(w, err.Error(), 500)
 				return
-			case <-ctx.Done():
-				Error.Printf("Context cancelled with message: %v\n", ctx.Err())
-				http.Error(w, ctx.Err().Error(), 500)
+			}
+
+			go func() {
+				writeStatus := func(resp *utils.WPSExecuteResponse) {
+					if err := utils.WriteWPSStatus(resultDir, jobID, resp, tplPath); err != nil {
+						Error.Printf("WPS job %s: %v\n", jobID, err)
+					}
+				}
+
+				var progress func(int, int)
+				if params.Status {
+					progress = func(done int, total int) {
+						started := utils.NewWPSExecuteResponse(statusLocation, utils.WPSProcessStarted, fmt.Sprintf("Processed %d of %d data sources.", done, total))
+						started.PercentComplete = 100 * done / total
+						writeStatus(started)
+					}
+					progress(0, len(process.DataSources))
+				}
+
+				// The job outlives the HTTP request and
+				// therefore cannot use the request context
+				outputs, partial, err := runWPSProcess(context.Background(), params, process, feats, wgs84Feats, conf, progress)
+				if err != nil {
+					writeStatus(utils.NewWPSExecuteResponse(statusLocation, utils.WPSProcessFailed, err.Error()))
+					return
+				}
+
+				result, err := wpsDocumentOutputs(outputs, process, params.MimeType, conf.ServiceConfig.TempDir)
+				if err != nil {
+					writeStatus(utils.NewWPSExecuteResponse(statusLocation, utils.WPSProcessFailed, err.Error()))
+					return
+				}
+
+				message := fmt.Sprintf(`The service "%s" ran successfully.`, process.Identifier)
+				if partial {
+					message = wpsPartialWarning(process)
+				}
+				succeeded := utils.NewWPSExecuteResponse(statusLocation, utils.WPSProcessSucceeded, message)
+				succeeded.Outputs = result
+				writeStatus(succeeded)
+			}()
+
+			err = utils.ExecuteWriteTemplateFile(w, accepted, tplPath)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
+			}
+			return
+		}
+
+		outputs, partial, err := runWPSProcess(ctx, params, process, feats, wgs84Feats, conf, nil)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
+		}
+
+		if partial {
+			w.Header().Set("Warning", fmt.Sprintf(`199 - "%s"`, wpsPartialWarning(process)))
+		}
+
+		if params.RawOutput && len(params.MimeType) > 0 {
+			data, err := encodeWPSOutputs(outputs, process, params.MimeType, conf.ServiceConfig.TempDir)
+			if err != nil {
+				http.Error(w, err.Error(), 500)
 				return
 			}
+
+			w.Header().Set("Content-Type", params.MimeType)
+			if params.MimeType == utils.WPSMimeNetCDF {
+				w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.nc", process.Identifier))
+			}
+			w.Write(data)
+			return
+		}
+
+		result, err := wpsDocumentOutputs(outputs, process, params.MimeType, conf.ServiceConfig.TempDir)
+		if err != nil {
+			http.Error(w, err.Error(), 500)
+			return
 		}
 
-		err = utils.ExecuteWriteTemplateFile(w, result, utils.DataDir+"/templates/WPS_Execute.tpl")
+		message := fmt.Sprintf(`The service "%s" ran successfully.`, process.Identifier)
+		if partial {
+			message = wpsPartialWarning(process)
+		}
+		succeeded := utils.NewWPSExecuteResponse("", utils.WPSProcessSucceeded, message)
+		succeeded.Outputs = result
+		err = utils.ExecuteWriteTemplateFile(w, succeeded, tplPath)
 		if err != nil {
 			http.Error(w, err.Error(), 500)
 		}
 
+	case "GetStatus":
+		if params.JobID == nil {
+			http.Error(w, "Malformed WPS GetStatus request, a valid 'jobid' needs to be specified", 400)
+			return
+		}
+
+		doc, err := utils.ReadWPSStatus(conf.ServiceConfig.WpsResultDir, *params.JobID)
+		if err != nil {
+			http.Error(w, err.Error(), 404)
+			return
+		}
+		w.Write(doc)
+
 	default:
 		http.Error(w, fmt.Sprintf("%s not recognised.", *params.Request), 400)
 	}
 }
 
+// isWCSQuery reports whether the URL parameters of a
+// request identify it as a WCS request
+func isWCSQuery(query map[string][]string) bool {
+	if service, serviceOK := query["service"]; serviceOK {
+		return service[0] == "WCS"
+	}
+	if request, requestOK := query["request"]; requestOK {
+		return request[0] == "GetCoverage"
+	}
+	return false
+}
+
+// removeURLParams removes the given parameters from the
+// query string of a request URL
+func removeURLParams(reqURL string, keys ...string) string {
+	for _, key := range keys {
+		rex := regexp.MustCompile(fmt.Sprintf(`(?i)([?&])%s\s*=\s*[^&]*`, key))
+		reqURL = rex.ReplaceAllString(reqURL, `${1}`)
+	}
+	return reqURL
+}
+
 // owsHandler handles every request received on /ows
 func generalHandler(conf *utils.Config, w http.ResponseWriter, r *http.Request) {
 //Info.Printf("%s\n", r.URL.String())
@@ -1294,6 +2058,23 @@ func generalHandler(conf *utils.Config, w http.ResponseWriter, r *http.Request)
 	var err error
 	switch r.Method {
 	case "POST":
+		// WCS GetCoverage takes its parameters from the URL and
+		// an optional clip geometry from the POST body
+		urlQuery := utils.NormaliseKeys(r.URL.Query())
+		if isWCSQuery(urlQuery) {
+			body, err := ioutil.ReadAll(r.Body)
+			r.Body.Close()
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Error reading WCS POST payload: %s", err), 400)
+				return
+			}
+			query = urlQuery
+			if len(strings.TrimSpace(string(body))) > 0 {
+				query["clip"] = []string{string(body)}
+			}
+			break
+		}
+
 		query, err = utils.ParsePost(r.Body)
 		if err != nil {
 			http.Error(w, fmt.Sprintf("Error parsing WPS POST payload: %s", err), 400)
@@ -1350,7 +2131,8 @@ func generalHandler(conf *utils.Config, w http.ResponseWriter, r *http.Request)
 	case "WPS":
 		params, err := utils.WPSParamsChecker(query, reWPSMap)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Wrong WPS parameters on URL: %s", err), 400)
+			Info.Printf("Wrong WPS parameters: %v\n", err)
+			writeWPSException(w, err)
 			return
 		}
 		serveWPS(ctx, params, conf, r.URL.String(), w)
@@ -6,6 +6,7 @@ import (
 	"math/rand"
 	"time"
 
+	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -17,6 +18,10 @@ type GeoDrillGRPC struct {
 	Out     chan *DrillResult
 	Error   chan error
 	Clients []string
+	// Partial drills output the granules left when their
+	// context is done as incomplete results instead of
+	// failing
+	Partial bool
 }
 
 func NewDrillGRPC(ctx context.Context, serverAddress []string, errChan chan error) *GeoDrillGRPC {
@@ -29,7 +34,7 @@ func NewDrillGRPC(ctx context.Context, serverAddress []string, errChan chan erro
 	}
 }
 
-func (gi *GeoDrillGRPC) Run(bandStrides int) {
+func (gi *GeoDrillGRPC) Run(bandStrides int, statistics []string, histogram bool, profileSpacing float64, exact bool) {
 	defer close(gi.Out)
 	start := time.Now()
 
@@ -45,8 +50,22 @@ func (gi *GeoDrillGRPC) Run(bandStrides int) {
 		return
 	}
 
+	// Precomputed statistics only contain the means
+	// of the pixels touched by the geometry
+	meanOnly := !histogram && !exact && profileSpacing <= 0 && (len(statistics) == 0 || (len(statistics) == 1 && statistics[0] == utils.DefaultZonalStatistic))
+
+	// Interpolating class histograms or profiles between bands is meaningless
+	if histogram || profileSpacing > 0 {
+		bandStrides = 1
+	}
+
+	sketchSize := 0
+	if utils.ZonalStatisticsNeedSketch(statistics) {
+		sketchSize = utils.DefaultQuantileSketchSize
+	}
+
 	var inputsRecompute []*GeoDrillGranule
-	if inputs[0].Approx {
+	if inputs[0].Approx && meanOnly {
 		for _, gran := range inputs {
 			if len(gran.Means) == 0 || len(gran.TimeStamps) != len(gran.Means) || len(gran.SampleCounts) != len(gran.Means) {
 				inputsRecompute = append(inputsRecompute, gran)
@@ -95,7 +114,8 @@ func (gi *GeoDrillGRPC) Run(bandStrides int) {
 	for i, client := range gi.Clients {
 		conn, err := grpc.Dial(client, opts...)
 		if err != nil {
-			log.Fatalf("gRPC connection problem: %v", err)
+			gi.Error <- fmt.Errorf("gRPC connection problem: %v", err)
+			return
 		}
 		defer conn.Close()
 		conns[i] = conn
@@ -104,11 +124,20 @@ func (gi *GeoDrillGRPC) Run(bandStrides int) {
 	cLimiter := NewConcLimiter(DefaultWpsConcLimit * len(conns))
 	workerStart := rand.Intn(len(conns))
 	i := 0
-	for _, gran := range inputsRecompute {
+	for ig, gran := range inputsRecompute {
 		i++
 		select {
 		case <-gi.Context.Done():
-			gi.Error <- fmt.Errorf("Drill gRPC context has been cancel: %v", gi.Context.Err())
+			cLimiter.Wait()
+			if !gi.Partial {
+				gi.Error <- fmt.Errorf("Drill gRPC context has been cancel: %v", gi.Context.Err())
+				return
+			}
+
+			for _, g := range inputsRecompute[ig:] {
+				gi.Out <- &DrillResult{NameSpace: g.NameSpace, Dates: g.TimeStamps, Incomplete: true}
+			}
+			log.Printf("gRPC drill cut short: %v, processed: %d of %d", gi.Context.Err(), ig, len(inputsRecompute))
 			return
 		default:
 			cLimiter.Increase()
@@ -118,9 +147,13 @@ func (gi *GeoDrillGRPC) Run(bandStrides int) {
 				bands, err := getBands(g.TimeStamps)
 				epsg, err := extractEPSGCode(g.CRS)
 
-				granule := &pb.GeoRPCGranule{Path: g.Path, EPSG: int32(epsg), Geometry: g.Geometry, Bands: bands, BandStrides: int32(bandStrides)}
+				granule := &pb.GeoRPCGranule{Path: g.Path, EPSG: int32(epsg), Geometry: g.Geometry, Bands: bands, BandStrides: int32(bandStrides), SketchSize: int32(sketchSize), Histogram: histogram, ProfileSpacing: profileSpacing, Exact: exact}
 				r, err := c.Process(gi.Context, granule)
 				if err != nil {
+					if gi.Partial && gi.Context.Err() != nil {
+						gi.Out <- &DrillResult{NameSpace: g.NameSpace, Dates: g.TimeStamps, Incomplete: true}
+						return
+					}
 					gi.Error <- err
 					r = &pb.Result{}
 					return
@@ -30,6 +30,9 @@ type DrillIndexer struct {
 	IdentityTol float64
 	DpTol       float64
 	Approx      bool
+	// Partial drills stop indexing silently at the
+	// deadline of their context
+	Partial bool
 }
 
 func NewDrillIndexer(ctx context.Context, apiAddr string, identityTol float64, dpTol float64, approx bool, errChan chan error) *DrillIndexer {
@@ -50,10 +53,17 @@ const DefaultMaxLogLength = 3000
 func (p *DrillIndexer) Run() {
 	defer close(p.Out)
 	for geoReq := range p.In {
+		// MAS is queried with the WGS84 geometry, the granules
+		// keeping the geometry of the request for the workers
+		indexGeometry, indexCRS := geoReq.Geometry, geoReq.CRS
+		if len(geoReq.IndexGeometry) > 0 {
+			indexGeometry, indexCRS = geoReq.IndexGeometry, "EPSG:4326"
+		}
+
 		var feat geo.Feature
-		err := json.Unmarshal([]byte(geoReq.Geometry), &feat)
+		err := json.Unmarshal([]byte(indexGeometry), &feat)
 		if err != nil {
-			p.Error <- fmt.Errorf("Problem unmarshalling GeoJSON object: %v", geoReq.Geometry)
+			p.Error <- fmt.Errorf("Problem unmarshalling GeoJSON object: %v", indexGeometry)
 			return
 		}
 
@@ -63,7 +73,7 @@ func (p *DrillIndexer) Run() {
 		if !time.Time.IsZero(geoReq.StartTime) {
 			startTimeStr = geoReq.StartTime.Format(ISOFormat)
 		}
-		reqURL := strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&until=%s&srs=%s&namespace=%s&identitytol=%f&dptol=%f", p.APIAddress, geoReq.Collection, startTimeStr, geoReq.EndTime.Format(ISOFormat), geoReq.CRS, namespaces, p.IdentityTol, p.DpTol), " ", "%20", -1)
+		reqURL := strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&until=%s&srs=%s&namespace=%s&identitytol=%f&dptol=%f", p.APIAddress, geoReq.Collection, startTimeStr, geoReq.EndTime.Format(ISOFormat), indexCRS, namespaces, p.IdentityTol, p.DpTol), " ", "%20", -1)
 		featWKT := feat.Geometry.MarshalWKT()
 		postBody := url.Values{"wkt": {featWKT}}
 
@@ -74,15 +84,30 @@ func (p *DrillIndexer) Run() {
 		}
 		log.Printf("mas_url:%s\tpost_body:%s", reqURL, postBodyStr[:maxLogLen])
 
-		resp, err := http.PostForm(reqURL, postBody)
+		req, err := http.NewRequest("POST", reqURL, strings.NewReader(postBody.Encode()))
 		if err != nil {
 			p.Error <- fmt.Errorf("POST request to %s failed. Error: %v", reqURL, err)
 			continue
 		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := http.DefaultClient.Do(req.WithContext(p.Context))
+		if err != nil {
+			if p.Partial && p.Context.Err() == context.DeadlineExceeded {
+				log.Printf("Indexer deadline exceeded: %s", reqURL)
+				continue
+			}
+			p.Error <- fmt.Errorf("POST request to %s failed. Error: %v", reqURL, err)
+			continue
+		}
 
-		defer resp.Body.Close()
 		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
 		if err != nil {
+			if p.Partial && p.Context.Err() == context.DeadlineExceeded {
+				log.Printf("Indexer deadline exceeded: %s", reqURL)
+				continue
+			}
 			p.Error <- fmt.Errorf("Error parsing response body from %s. Error: %v", reqURL, err)
 			continue
 		}
@@ -1,10 +1,13 @@
 package processor
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"math"
 	"sort"
+	"strconv"
+	"time"
 
 	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
@@ -12,23 +15,83 @@ import (
 
 type DrillMerger struct {
 	In    chan *DrillResult
-	Out   chan string
+	Out   chan *DrillOutput
 	Error chan error
+	// Context is the context of the drill stages, whose
+	// deadline marks the outputs as partial when exceeded
+	Context context.Context
 }
 
 func NewDrillMerger(errChan chan error) *DrillMerger {
 	return &DrillMerger{
 		In:    make(chan *DrillResult, 100),
-		Out:   make(chan string),
+		Out:   make(chan *DrillOutput),
 		Error: errChan,
 	}
 }
 
-func (dm *DrillMerger) Run(suffix string, namespaces []string, templateFileName string, bandExpr *utils.BandExpressions) {
+func (dm *DrillMerger) Run(geoReq GeoDrillRequest) {
 	defer close(dm.Out)
+
+	out, err := dm.Merge(geoReq)
+	if err != nil {
+		dm.Error <- err
+		return
+	}
+
+	if len(out.Data) == 0 {
+		if out.Partial {
+			dm.Error <- fmt.Errorf("WPS: The drill timed out before any date was completed")
+			return
+		}
+		dm.Error <- fmt.Errorf("WPS: Merger hasn't received any result")
+		return
+	}
+	dm.Out <- out
+}
+
+// Merge consumes the drill results of a request and returns
+// the merged time series, or the class histograms if the
+// request is a histogram. The rows of the output are keyed
+// by the FeatureID of the request and aggregated over time
+// as requested. The output contains no rows if no result has
+// been received. The dates of incomplete results are left out
+// and the output is partial if the deadline of the drill has
+// been exceeded.
+func (dm *DrillMerger) Merge(geoReq GeoDrillRequest) (*DrillOutput, error) {
+	out, err := dm.mergeResults(geoReq)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err = aggregateTemporal(out, geoReq.Temporal, geoReq.StartTime, geoReq.EndTime)
+	if err != nil {
+		return nil, err
+	}
+	out.Partial = dm.Context != nil && dm.Context.Err() == context.DeadlineExceeded
+	return out, nil
+}
+
+func (dm *DrillMerger) mergeResults(geoReq GeoDrillRequest) (*DrillOutput, error) {
+	namespaces := append([]string{}, geoReq.NameSpaces...)
+	bandExpr := geoReq.BandExpr
+	featureID := geoReq.FeatureID
+	statistics := geoReq.Statistics
+	if len(statistics) == 0 {
+		statistics = []string{utils.DefaultZonalStatistic}
+	}
+
 	results := make(map[string]map[string][]*pb.TimeSeries)
+	incomplete := make(map[string]bool)
 
 	for drillRes := range dm.In {
+		if drillRes.Incomplete {
+			for _, date := range drillRes.Dates {
+				incomplete[date.Format(ISOFormat)] = true
+			}
+			continue
+		}
+
 		if _, ok := results[drillRes.NameSpace]; !ok {
 			results[drillRes.NameSpace] = make(map[string][]*pb.TimeSeries)
 			nsFound := false
@@ -52,9 +115,40 @@ func (dm *DrillMerger) Run(suffix string, namespaces []string, templateFileName
 			}
 		}
 	}
+
+	// The dates missing some of their granules are dropped
+	// rather than computed from a part of the geometry
+	for _, dates := range results {
+		for isoDate := range incomplete {
+			delete(dates, isoDate)
+		}
+	}
+
+	if geoReq.Histogram {
+		return mergeHistograms(results, namespaces, geoReq.ClassLabels, featureID)
+	}
+
+	if geoReq.ProfileSpacing > 0 {
+		var feat struct {
+			Geometry json.RawMessage `json:"geometry"`
+		}
+		if err := json.Unmarshal([]byte(geoReq.Geometry), &feat); err != nil {
+			return nil, fmt.Errorf("WPS: Problem unmarshalling GeoJSON object: %v", err)
+		}
+		coords, err := utils.LineStringCoordinates(feat.Geometry)
+		if err != nil {
+			return nil, fmt.Errorf("WPS: %v", err)
+		}
+		samples, err := utils.SampleLineString(coords, geoReq.ProfileSpacing)
+		if err != nil {
+			return nil, fmt.Errorf("WPS: %v", err)
+		}
+		return mergeProfiles(results, bandExpr, samples)
+	}
+
+	out := &DrillOutput{Series: seriesNames(bandExpr.ExprNames, statistics), Statistics: statistics}
 	if len(results) == 0 {
-		dm.Error <- fmt.Errorf("WPS: Merger hasn't received any result")
-		return
+		return out, nil
 	}
 
 	var dates []string
@@ -63,81 +157,293 @@ func (dm *DrillMerger) Run(suffix string, namespaces []string, templateFileName
 	}
 	sort.Strings(dates)
 
-	csv := bytes.NewBufferString("")
 	for _, key := range dates {
-		values := map[string]float64{}
+		date, err := time.Parse(ISOFormat, key)
+		if err != nil {
+			return nil, fmt.Errorf("WPS: invalid date '%v': %v", key, err)
+		}
+
+		// Partial statistics of the granules are merged per
+		// namespace and then evaluated for each statistic
+		values := make(map[string]map[string]float64, len(statistics))
+		for _, stat := range statistics {
+			values[stat] = map[string]float64{}
+		}
+
 		for _, ns := range namespaces {
-			total := 0.0
-			count := 0
+			zs := &utils.ZonalStats{}
 			for _, data := range results[ns][key] {
 				if !math.IsNaN(data.Value) {
-					total += data.Value * float64(data.Count)
-					count += int(data.Count)
+					zs.Merge(timeSeriesToZonalStats(data), utils.DefaultQuantileSketchSize)
 				}
 			}
-			if !math.IsNaN(total) && count > 0 {
-				values[ns] = total / float64(count)
+
+			for _, stat := range statistics {
+				if val, ok := zs.Statistic(stat); ok {
+					values[stat][ns] = val
+				}
 			}
 		}
 
-		fmt.Fprintf(csv, "%s", key)
+		row := DrillRow{FeatureID: featureID, Date: date}
 
 		if len(bandExpr.Expressions) == 0 {
 			for _, ns := range bandExpr.ExprNames {
-				fmt.Fprint(csv, ",")
-				if val, ok := values[ns]; ok {
-					fmt.Fprintf(csv, "%f", val)
+				for _, stat := range statistics {
+					val, ok := values[stat][ns]
+					if !ok {
+						val = math.NaN()
+					}
+					row.Values = append(row.Values, val)
 				}
 			}
 
-			fmt.Fprint(csv, "\\n")
+			out.Data = append(out.Data, row)
 			continue
 		}
 
 		for ix, expr := range bandExpr.Expressions {
-			noData := false
-			for _, variable := range bandExpr.ExprVarRef[ix] {
-				if _, ok := values[variable]; !ok {
-					noData = true
-					break
+			for _, stat := range statistics {
+				noData := false
+				parameters := make(map[string]interface{}, len(bandExpr.ExprVarRef[ix]))
+				for _, variable := range bandExpr.ExprVarRef[ix] {
+					val, ok := values[stat][variable]
+					if !ok {
+						noData = true
+						val = math.NaN()
+					}
+					parameters[variable] = val
 				}
+
+				// Expressions calling isnodata see nodata as NaN
+				if noData && !handlesNoData(bandExpr, ix) {
+					row.Values = append(row.Values, math.NaN())
+					continue
+				}
+
+				result, err := expr.Evaluate(parameters)
+				if err != nil {
+					return nil, fmt.Errorf("WPS: Eval '%v' error: %v", bandExpr.ExprText[ix], err)
+				}
+
+				val, ok := result.(float32)
+				if !ok {
+					return nil, fmt.Errorf("WPS: Failed to cast eval results '%v' to float32, %v", val, bandExpr.ExprText[ix])
+				}
+
+				row.Values = append(row.Values, float64(val))
 			}
+		}
 
-			fmt.Fprint(csv, ",")
+		out.Data = append(out.Data, row)
+	}
 
-			if noData {
-				continue
+	return out, nil
+}
+
+// mergeHistograms sums the class counts and areas of the
+// granules. The output contains two series per class with
+// the pixel count and the area in square kilometres, named
+// after the label of the class if there is one. Series are
+// prefixed with the namespace if there are several of them.
+func mergeHistograms(results map[string]map[string][]*pb.TimeSeries, namespaces []string, classLabels []utils.ClassLabel, featureID string) (*DrillOutput, error) {
+	labels := make(map[float64]string, len(classLabels))
+	for _, cl := range classLabels {
+		labels[cl.Value] = cl.Label
+	}
+
+	dateSet := make(map[string]bool)
+	classSet := make(map[string]map[float64]bool)
+	for _, ns := range namespaces {
+		classSet[ns] = make(map[float64]bool)
+		for key, tss := range results[ns] {
+			dateSet[key] = true
+			for _, ts := range tss {
+				for _, cc := range ts.ClassCounts {
+					classSet[ns][cc.Value] = true
+				}
 			}
+		}
+	}
+
+	out := &DrillOutput{}
+	var classes [][]float64
+	for _, ns := range namespaces {
+		var nsClasses []float64
+		for val := range classSet[ns] {
+			nsClasses = append(nsClasses, val)
+		}
+		sort.Float64s(nsClasses)
+		classes = append(classes, nsClasses)
 
-			parameters := make(map[string]interface{}, len(bandExpr.ExprVarRef[ix]))
-			for _, variable := range bandExpr.ExprVarRef[ix] {
-				parameters[variable] = values[variable]
+		for _, val := range nsClasses {
+			name, found := labels[val]
+			if !found {
+				name = strconv.FormatFloat(val, 'f', -1, 64)
+			}
+			if len(namespaces) > 1 {
+				name = ns + "_" + name
 			}
+			out.Series = append(out.Series, name+"_pixels", name+"_km2")
+		}
+	}
+
+	var dates []string
+	for key := range dateSet {
+		dates = append(dates, key)
+	}
+	sort.Strings(dates)
+
+	for _, key := range dates {
+		date, err := time.Parse(ISOFormat, key)
+		if err != nil {
+			return nil, fmt.Errorf("WPS: invalid date '%v': %v", key, err)
+		}
 
-			result, err := expr.Evaluate(parameters)
-			if err != nil {
-				dm.Error <- fmt.Errorf("WPS: Eval '%v' error: %v", bandExpr.ExprText[ix], err)
-				return
+		row := DrillRow{FeatureID: featureID, Date: date}
+		for ins, ns := range namespaces {
+			tss, hasData := results[ns][key]
+			counts := make(map[float64]*pb.ClassCount)
+			for _, ts := range tss {
+				for _, cc := range ts.ClassCounts {
+					if _, found := counts[cc.Value]; !found {
+						counts[cc.Value] = &pb.ClassCount{Value: cc.Value}
+					}
+					counts[cc.Value].Count += cc.Count
+					counts[cc.Value].Area += cc.Area
+				}
 			}
 
-			val, ok := result.(float32)
-			if !ok {
-				dm.Error <- fmt.Errorf("WPS: Failed to cast eval results '%v' to float32, %v", val, bandExpr.ExprText[ix])
-				return
+			for _, val := range classes[ins] {
+				if !hasData {
+					row.Values = append(row.Values, math.NaN(), math.NaN())
+					continue
+				}
+
+				count, area := 0.0, 0.0
+				if cc, found := counts[val]; found {
+					count, area = float64(cc.Count), cc.Area
+				}
+				row.Values = append(row.Values, count, area)
 			}
+		}
+
+		out.Data = append(out.Data, row)
+	}
 
-			fmt.Fprintf(csv, "%f", float64(val))
+	return out, nil
+}
+
+// mergeProfiles combines the samples of the granules along
+// a profile. The output contains a row per date and sample,
+// keyed by the index of the sample along the line, holding
+// the distance in metres, the coordinates of the sample and
+// the values of the bands or of their expressions.
+func mergeProfiles(results map[string]map[string][]*pb.TimeSeries, bandExpr *utils.BandExpressions, samples []utils.ProfileSample) (*DrillOutput, error) {
+	out := &DrillOutput{Series: append([]string{"distance", "longitude", "latitude"}, bandExpr.ExprNames...), FeatureColumn: "sample"}
+
+	dateSet := make(map[string]bool)
+	for _, nsResults := range results {
+		for key := range nsResults {
+			dateSet[key] = true
 		}
+	}
 
-		fmt.Fprint(csv, "\\n")
+	var dates []string
+	for key := range dateSet {
+		dates = append(dates, key)
+	}
+	sort.Strings(dates)
 
+	for _, key := range dates {
+		date, err := time.Parse(ISOFormat, key)
+		if err != nil {
+			return nil, fmt.Errorf("WPS: invalid date '%v': %v", key, err)
+		}
+
+		// Granules overlapping along the line provide the
+		// same samples, the first valid value is kept
+		values := make(map[string][]float64, len(results))
+		for ns, nsResults := range results {
+			nsValues := make([]float64, len(samples))
+			for is := range nsValues {
+				nsValues[is] = math.NaN()
+			}
+			for _, ts := range nsResults[key] {
+				for is, val := range ts.Samples {
+					if is < len(nsValues) && math.IsNaN(nsValues[is]) {
+						nsValues[is] = val
+					}
+				}
+			}
+			values[ns] = nsValues
+		}
+
+		for is, sample := range samples {
+			row := DrillRow{FeatureID: strconv.Itoa(is), Date: date, Values: []float64{sample.Distance, sample.Lon, sample.Lat}}
+
+			if len(bandExpr.Expressions) == 0 {
+				for _, ns := range bandExpr.ExprNames {
+					val := math.NaN()
+					if nsValues, ok := values[ns]; ok {
+						val = nsValues[is]
+					}
+					row.Values = append(row.Values, val)
+				}
+				out.Data = append(out.Data, row)
+				continue
+			}
+
+			for ix, expr := range bandExpr.Expressions {
+				noData := false
+				parameters := make(map[string]interface{}, len(bandExpr.ExprVarRef[ix]))
+				for _, variable := range bandExpr.ExprVarRef[ix] {
+					val := math.NaN()
+					if nsValues, ok := values[variable]; ok {
+						val = nsValues[is]
+					}
+					if math.IsNaN(val) {
+						noData = true
+					}
+					parameters[variable] = val
+				}
+
+				if noData && !handlesNoData(bandExpr, ix) {
+					row.Values = append(row.Values, math.NaN())
+					continue
+				}
+
+				result, err := expr.Evaluate(parameters)
+				if err != nil {
+					return nil, fmt.Errorf("WPS: Eval '%v' error: %v", bandExpr.ExprText[ix], err)
+				}
+
+				val, ok := result.(float32)
+				if !ok {
+					return nil, fmt.Errorf("WPS: Failed to cast eval results '%v' to float32, %v", val, bandExpr.ExprText[ix])
+				}
+
+				row.Values = append(row.Values, float64(val))
+			}
+
+			out.Data = append(out.Data, row)
+		}
 	}
 
-	out := bytes.NewBufferString("")
-	err := utils.ExecuteWriteTemplateFile(out, csv, templateFileName)
-	if err != nil {
-		dm.Error <- fmt.Errorf("WPS: output template error: %v", err)
-		return
+	return out, nil
+}
+
+func timeSeriesToZonalStats(ts *pb.TimeSeries) *utils.ZonalStats {
+	zs := &utils.ZonalStats{Count: int64(ts.Count), Weight: ts.Weight, Mean: ts.Value, M2: ts.M2, Min: ts.Min, Max: ts.Max, Centroids: ts.Centroids}
+	zs.CentroidCounts = make([]int64, len(ts.CentroidCounts))
+	for i, c := range ts.CentroidCounts {
+		zs.CentroidCounts[i] = int64(c)
 	}
-	dm.Out <- fmt.Sprintf(out.String(), suffix)
+	return zs
+}
+
+// handlesNoData tells whether an expression calls isnodata
+// and is evaluated over nodata inputs
+func handlesNoData(bandExpr *utils.BandExpressions, ix int) bool {
+	return ix < len(bandExpr.HandlesNoData) && bandExpr.HandlesNoData[ix]
 }
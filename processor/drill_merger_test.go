@@ -0,0 +1,124 @@
+package processor
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nci/gsky/utils"
+	pb "github.com/nci/gsky/worker/gdalservice"
+)
+
+func TestMergeHistograms(t *testing.T) {
+	d0 := "2018-01-01T00:00:00.000Z"
+	d1 := "2018-02-01T00:00:00.000Z"
+	results := map[string]map[string][]*pb.TimeSeries{
+		"landcover": {
+			d0: {
+				{Count: 3, ClassCounts: []*pb.ClassCount{{Value: 1, Count: 2, Area: 0.5}, {Value: 7, Count: 1, Area: 0.25}}},
+				{Count: 2, ClassCounts: []*pb.ClassCount{{Value: 1, Count: 2, Area: 0.5}}},
+			},
+			d1: {
+				{Count: 1, ClassCounts: []*pb.ClassCount{{Value: 7, Count: 1, Area: 0.25}}},
+			},
+		},
+	}
+	labels := []utils.ClassLabel{{Value: 1, Label: "forest"}}
+
+	out, err := mergeHistograms(results, []string{"landcover"}, labels, "")
+	if err != nil {
+		t.Fatalf("failed to merge histograms: %v", err)
+	}
+
+	expSeries := []string{"forest_pixels", "forest_km2", "7_pixels", "7_km2"}
+	if len(out.Series) != len(expSeries) {
+		t.Fatalf("expected series %v, got %v", expSeries, out.Series)
+	}
+	for i, name := range expSeries {
+		if out.Series[i] != name {
+			t.Errorf("expected series %s, got %s", name, out.Series[i])
+		}
+	}
+
+	expValues := [][]float64{{4, 1, 1, 0.25}, {0, 0, 1, 0.25}}
+	if len(out.Data) != len(expValues) {
+		t.Fatalf("expected %d rows, got %d", len(expValues), len(out.Data))
+	}
+	for ir, row := range out.Data {
+		for iv, val := range row.Values {
+			if math.Abs(val-expValues[ir][iv]) > 1e-9 {
+				t.Errorf("row %d, series %s: expected %f, got %f", ir, out.Series[iv], expValues[ir][iv], val)
+			}
+		}
+	}
+
+	if header := out.Header(); header != "date,forest_pixels,forest_km2,7_pixels,7_km2" {
+		t.Errorf("unexpected header: %s", header)
+	}
+}
+
+func TestMergeProfiles(t *testing.T) {
+	d0 := "2018-01-01T00:00:00.000Z"
+	nan := math.NaN()
+	results := map[string]map[string][]*pb.TimeSeries{
+		"elevation": {
+			d0: {
+				{Count: 1, Samples: []float64{10, nan, nan}},
+				{Count: 2, Samples: []float64{nan, 20, nan}},
+			},
+		},
+	}
+	samples := []utils.ProfileSample{{Distance: 0, Lon: 149, Lat: -35}, {Distance: 100, Lon: 149.001, Lat: -35}, {Distance: 150, Lon: 149.0015, Lat: -35}}
+	bandExpr := &utils.BandExpressions{ExprNames: []string{"elevation"}}
+
+	out, err := mergeProfiles(results, bandExpr, samples)
+	if err != nil {
+		t.Fatalf("failed to merge profiles: %v", err)
+	}
+
+	if out.FeatureColumn != "sample" || len(out.Series) != 4 || out.Series[3] != "elevation" {
+		t.Errorf("unexpected output layout: %v, %v", out.FeatureColumn, out.Series)
+	}
+	if len(out.Data) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(out.Data))
+	}
+
+	expValues := []float64{10, 20, nan}
+	for is, row := range out.Data {
+		if row.FeatureID != strconv.Itoa(is) || row.Values[0] != samples[is].Distance || row.Values[1] != samples[is].Lon {
+			t.Errorf("unexpected sample row: %+v", row)
+		}
+		if val := row.Values[3]; val != expValues[is] && !(math.IsNaN(val) && math.IsNaN(expValues[is])) {
+			t.Errorf("sample %d: expected %f, got %f", is, expValues[is], val)
+		}
+	}
+}
+
+func TestMergeIncomplete(t *testing.T) {
+	d0 := time.Date(2018, time.January, 1, 0, 0, 0, 0, time.UTC)
+	d1 := time.Date(2018, time.February, 1, 0, 0, 0, 0, time.UTC)
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	dm := NewDrillMerger(make(chan error, 1))
+	dm.Context = ctx
+	dm.In <- &DrillResult{NameSpace: "landcover", Dates: []time.Time{d0, d1}, Data: []*pb.TimeSeries{
+		{Count: 1, ClassCounts: []*pb.ClassCount{{Value: 1, Count: 1, Area: 0.25}}},
+		{Count: 1, ClassCounts: []*pb.ClassCount{{Value: 1, Count: 1, Area: 0.25}}},
+	}}
+	dm.In <- &DrillResult{NameSpace: "landcover", Dates: []time.Time{d1}, Incomplete: true}
+	close(dm.In)
+
+	out, err := dm.Merge(GeoDrillRequest{NameSpaces: []string{"landcover"}, Histogram: true})
+	if err != nil {
+		t.Fatalf("failed to merge: %v", err)
+	}
+	if !out.Partial {
+		t.Errorf("expected a partial output")
+	}
+	if len(out.Data) != 1 || !out.Data[0].Date.Equal(d0) {
+		t.Errorf("expected the incomplete date to be dropped, got %+v", out.Data)
+	}
+}
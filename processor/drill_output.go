@@ -0,0 +1,178 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/nci/gsky/utils"
+)
+
+type drillJSONSeries struct {
+	Name    string     `json:"name"`
+	Feature string     `json:"feature,omitempty"`
+	Dates   []string   `json:"dates"`
+	Values  []*float64 `json:"values"`
+}
+
+type drillJSONOutput struct {
+	FeatureColumn string            `json:"feature_column,omitempty"`
+	Series        []drillJSONSeries `json:"series"`
+}
+
+// EncodeDrillCSV encodes the outputs as a long format CSV
+// table with a header row and one row per date and series.
+// Missing values are left empty.
+func EncodeDrillCSV(outputs []*DrillOutput) ([]byte, error) {
+	featureColumn := drillFeatureColumn(outputs)
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := []string{"date", "series", "value"}
+	if len(featureColumn) > 0 {
+		header = append([]string{featureColumn}, header...)
+	}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, out := range outputs {
+		for _, row := range out.Data {
+			for iv, val := range row.Values {
+				record := []string{row.Date.Format(ISOFormat), out.Series[iv], ""}
+				if !math.IsNaN(val) {
+					record[2] = strconv.FormatFloat(val, 'f', -1, 64)
+				}
+				if len(featureColumn) > 0 {
+					record = append([]string{row.FeatureID}, record...)
+				}
+				if err := w.Write(record); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// EncodeDrillJSON encodes the outputs as a list of named
+// series, one per feature if the outputs contain several
+// features, with ISO dates. Missing values are null.
+func EncodeDrillJSON(outputs []*DrillOutput) ([]byte, error) {
+	res := drillJSONOutput{FeatureColumn: drillFeatureColumn(outputs), Series: []drillJSONSeries{}}
+
+	for _, out := range outputs {
+		features, featureRows := groupDrillRows(out)
+		for _, feature := range features {
+			for iv, name := range out.Series {
+				series := drillJSONSeries{Name: name, Feature: feature}
+				for _, row := range featureRows[feature] {
+					series.Dates = append(series.Dates, row.Date.Format(ISOFormat))
+					if math.IsNaN(row.Values[iv]) {
+						series.Values = append(series.Values, nil)
+					} else {
+						val := row.Values[iv]
+						series.Values = append(series.Values, &val)
+					}
+				}
+				res.Series = append(res.Series, series)
+			}
+		}
+	}
+
+	return json.Marshal(res)
+}
+
+// EncodeDrillNetCDF encodes the outputs as a CF NetCDF file
+// with a time dimension made of the dates of all the outputs
+// and a feature dimension if the outputs contain several
+// features. tempDir holds the intermediate file.
+func EncodeDrillNetCDF(outputs []*DrillOutput, tempDir string) ([]byte, error) {
+	featureColumn := drillFeatureColumn(outputs)
+
+	dateIndex := make(map[time.Time]int)
+	var dates []time.Time
+	featureIndex := make(map[string]int)
+	var featureIDs []string
+	for _, out := range outputs {
+		for _, row := range out.Data {
+			if _, found := dateIndex[row.Date]; !found {
+				dateIndex[row.Date] = 0
+				dates = append(dates, row.Date)
+			}
+			if _, found := featureIndex[row.FeatureID]; len(featureColumn) > 0 && !found {
+				featureIndex[row.FeatureID] = len(featureIDs)
+				featureIDs = append(featureIDs, row.FeatureID)
+			}
+		}
+	}
+
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	for it, date := range dates {
+		dateIndex[date] = it
+	}
+
+	nValues := len(dates)
+	if len(featureIDs) > 0 {
+		nValues *= len(featureIDs)
+	}
+
+	var varNames []string
+	var values [][]float64
+	for _, out := range outputs {
+		outValues := make([][]float64, len(out.Series))
+		for iv := range outValues {
+			outValues[iv] = make([]float64, nValues)
+			for i := range outValues[iv] {
+				outValues[iv][i] = math.NaN()
+			}
+		}
+
+		for _, row := range out.Data {
+			offset := dateIndex[row.Date]
+			if len(featureIDs) > 0 {
+				offset += featureIndex[row.FeatureID] * len(dates)
+			}
+			for iv, val := range row.Values {
+				outValues[iv][offset] = val
+			}
+		}
+
+		varNames = append(varNames, out.Series...)
+		values = append(values, outValues...)
+	}
+
+	return utils.EncodeNetCDFTimeSeries(tempDir, dates, featureColumn, featureIDs, varNames, values)
+}
+
+// drillFeatureColumn returns the name of the feature
+// column if the outputs contain several features
+func drillFeatureColumn(outputs []*DrillOutput) string {
+	for _, out := range outputs {
+		if len(out.FeatureColumn) > 0 {
+			return out.FeatureColumn
+		}
+	}
+	return ""
+}
+
+// groupDrillRows groups the rows of an output by feature
+// keeping the order in which the features appear
+func groupDrillRows(out *DrillOutput) ([]string, map[string][]DrillRow) {
+	var features []string
+	featureRows := make(map[string][]DrillRow)
+	for _, row := range out.Data {
+		if _, found := featureRows[row.FeatureID]; !found {
+			features = append(features, row.FeatureID)
+		}
+		featureRows[row.FeatureID] = append(featureRows[row.FeatureID], row)
+	}
+	return features, featureRows
+}
@@ -0,0 +1,73 @@
+package processor
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncodeDrillOutputs(t *testing.T) {
+	t0 := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := time.Date(2018, 2, 1, 0, 0, 0, 0, time.UTC)
+	out := &DrillOutput{
+		Series:        []string{"PV", "NPV"},
+		FeatureColumn: "lga",
+		Data: []DrillRow{
+			{FeatureID: "Albury", Date: t0, Values: []float64{1.5, 2}},
+			{FeatureID: "Albury", Date: t1, Values: []float64{math.NaN(), 3}},
+			{FeatureID: "Ballina, NSW", Date: t0, Values: []float64{4, 5}},
+		},
+	}
+
+	csv, err := EncodeDrillCSV([]*DrillOutput{out})
+	if err != nil {
+		t.Fatalf("failed to encode CSV: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(csv)), "\n")
+	if len(lines) != 7 {
+		t.Fatalf("expected 7 CSV lines, got %d: %s", len(lines), csv)
+	}
+	if lines[0] != "lga,date,series,value" {
+		t.Errorf("unexpected CSV header: %s", lines[0])
+	}
+	if lines[3] != "Albury,2018-02-01T00:00:00.000Z,PV," {
+		t.Errorf("unexpected CSV missing value row: %s", lines[3])
+	}
+	if lines[5] != `"Ballina, NSW",2018-01-01T00:00:00.000Z,PV,4` {
+		t.Errorf("unexpected CSV quoted row: %s", lines[5])
+	}
+
+	doc, err := EncodeDrillJSON([]*DrillOutput{out})
+	if err != nil {
+		t.Fatalf("failed to encode JSON: %v", err)
+	}
+
+	var res drillJSONOutput
+	if err = json.Unmarshal(doc, &res); err != nil {
+		t.Fatalf("invalid JSON output: %v", err)
+	}
+	if len(res.Series) != 4 {
+		t.Fatalf("expected 4 series, got %d", len(res.Series))
+	}
+	albury := res.Series[0]
+	if albury.Name != "PV" || albury.Feature != "Albury" || len(albury.Dates) != 2 || albury.Values[1] != nil || *albury.Values[0] != 1.5 {
+		t.Errorf("unexpected series: %+v", albury)
+	}
+}
+
+func TestDrillOutputRows(t *testing.T) {
+	out := &DrillOutput{
+		Series:     []string{"PV_mean", "PV_max"},
+		Statistics: []string{"mean", "max"},
+		Data:       []DrillRow{{Date: time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC), Values: []float64{1, math.NaN()}}},
+	}
+
+	if rows := out.Rows(); rows != `2018-01-01T00:00:00.000Z,1.000000,\n` {
+		t.Errorf("unexpected template rows: %s", rows)
+	}
+	if cols := out.Columns("date,PV"); cols != "date,PV_mean,PV_max" {
+		t.Errorf("unexpected template columns: %s", cols)
+	}
+}
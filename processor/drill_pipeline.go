@@ -3,6 +3,8 @@ package processor
 import (
 	"context"
 	"fmt"
+	"strings"
+	"time"
 )
 
 type DrillPipeline struct {
@@ -12,6 +14,11 @@ type DrillPipeline struct {
 	APIAddr     string
 	IdentityTol float64
 	DpTol       float64
+	// Deadline is the time at which the drills are cancelled
+	// unless it is zero. Partial drills then return the dates
+	// drilled so far instead of failing.
+	Deadline time.Time
+	Partial  bool
 }
 
 func InitDrillPipeline(ctx context.Context, apiAddr string, rpcAddrs []string, identityTol float64, dpTol float64, errChan chan error) *DrillPipeline {
@@ -25,26 +32,144 @@ func InitDrillPipeline(ctx context.Context, apiAddr string, rpcAddrs []string, i
 	}
 }
 
-func (dp *DrillPipeline) Process(geoReq GeoDrillRequest, suffix string, templateFileName string, bandStrides int, approx bool) chan string {
-	grpcDriller := NewDrillGRPC(dp.Context, dp.RPCAddrs, dp.Error)
+func (dp *DrillPipeline) Process(geoReq GeoDrillRequest, bandStrides int, approx bool) chan *DrillOutput {
+	dm := dp.startDrill(geoReq, bandStrides, approx)
+	go dm.Run(geoReq)
+
+	return dm.Out
+}
+
+// ProcessFeatures drills each of the requests, typically one
+// per feature of a collection, running at most concLimit of
+// them at a time. The rows of all the requests are returned
+// as a single output whose rows are keyed by the FeatureID of
+// the request under the featureColumn name.
+func (dp *DrillPipeline) ProcessFeatures(geoReqs []GeoDrillRequest, featureColumn string, bandStrides int, approx bool, concLimit int) chan *DrillOutput {
+	out := make(chan *DrillOutput)
+
+	go func() {
+		defer close(out)
+
+		if concLimit <= 0 {
+			concLimit = 1
+		}
+
+		outputs := make([]*DrillOutput, len(geoReqs))
+		cLimiter := NewConcLimiter(concLimit)
+		for ir := range geoReqs {
+			select {
+			case <-dp.Context.Done():
+				cLimiter.Wait()
+				return
+			default:
+			}
+
+			cLimiter.Increase()
+			go func(geoReq GeoDrillRequest, ir int) {
+				defer cLimiter.Decrease()
+
+				dm := dp.startDrill(geoReq, bandStrides, approx)
+				featureOut, err := dm.Merge(geoReq)
+				if err != nil {
+					dp.Error <- fmt.Errorf("WPS: feature %s: %v", geoReq.FeatureID, err)
+					return
+				}
+				outputs[ir] = featureOut
+			}(geoReqs[ir], ir)
+		}
+		cLimiter.Wait()
+
+		var res *DrillOutput
+		for ir, featureOut := range outputs {
+			// The error of the failed feature has
+			// already been sent to the pipeline
+			if featureOut == nil {
+				return
+			}
+			if res == nil {
+				res = &DrillOutput{Series: featureOut.Series, Statistics: featureOut.Statistics, FeatureColumn: featureColumn}
+				if len(featureOut.FeatureColumn) > 0 {
+					res.FeatureColumn += "/" + featureOut.FeatureColumn
+				}
+			}
+			res.Partial = res.Partial || featureOut.Partial
+
+			// Rows already keyed within the feature, such as
+			// the samples of profiles, are prefixed with the
+			// key of the feature
+			if len(featureOut.FeatureColumn) > 0 {
+				for i := range featureOut.Data {
+					featureOut.Data[i].FeatureID = geoReqs[ir].FeatureID + "/" + featureOut.Data[i].FeatureID
+				}
+			}
+			res.Data = append(res.Data, featureOut.Data...)
+		}
+
+		if res == nil || len(res.Data) == 0 {
+			dp.Error <- fmt.Errorf("WPS: Merger hasn't received any result")
+			return
+		}
+
+		select {
+		case out <- res:
+		case <-dp.Context.Done():
+		}
+	}()
+
+	return out
+}
+
+// startDrill starts the indexer and gRPC stages of a drill
+// and returns the merger reading their results.
+func (dp *DrillPipeline) startDrill(geoReq GeoDrillRequest, bandStrides int, approx bool) *DrillMerger {
+	ctx, cancel := dp.Context, context.CancelFunc(func() {})
+	if !dp.Deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(dp.Context, dp.Deadline)
+	}
+
+	grpcDriller := NewDrillGRPC(ctx, dp.RPCAddrs, dp.Error)
 	if grpcDriller == nil {
 		dp.Error <- fmt.Errorf("Couldn't instantiate RPCDriller %s/n", dp.RPCAddrs)
 	}
+	grpcDriller.Partial = dp.Partial
 
-	i := NewDrillIndexer(dp.Context, dp.APIAddr, dp.IdentityTol, dp.DpTol, approx, dp.Error)
+	// Anomalies also require the data of the baseline period
+	indexReq := geoReq
+	indexReq.StartTime, indexReq.EndTime = geoReq.Temporal.TimeRange(geoReq.StartTime, geoReq.EndTime)
+
+	i := NewDrillIndexer(ctx, dp.APIAddr, dp.IdentityTol, dp.DpTol, approx, dp.Error)
+	i.Partial = dp.Partial
 	go func() {
-		i.In <- &geoReq
+		i.In <- &indexReq
 		close(i.In)
 	}()
 
 	dm := NewDrillMerger(dp.Error)
+	dm.Context = ctx
 
 	grpcDriller.In = i.Out
 	dm.In = grpcDriller.Out
 
 	go i.Run()
-	go grpcDriller.Run(bandStrides)
-	go dm.Run(suffix, geoReq.NameSpaces, templateFileName, geoReq.BandExpr)
+	go func() {
+		defer cancel()
+		grpcDriller.Run(bandStrides, geoReq.Statistics, geoReq.Histogram, geoReq.ProfileSpacing, geoReq.Exact)
+	}()
 
-	return dm.Out
+	return dm
+}
+
+// csvFieldValue replaces the characters that would break the
+// CSV rows embedded in the JSON output templates, including the
+// percent signs interpreted when the output suffix is applied.
+func csvFieldValue(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == ',' || r == '"' || r == '\\' || r == '%':
+			return '_'
+		case r < ' ':
+			return ' '
+		}
+		return r
+	}, value)
 }
@@ -0,0 +1,259 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/nci/gsky/utils"
+)
+
+// TemporalAggregation describes how the time series of a
+// drill are aggregated into periods and compared with the
+// climatology of a baseline period. The zero value leaves
+// the time series unchanged.
+type TemporalAggregation struct {
+	// Period is one of the utils.WPSPeriod values or empty
+	// to keep the dates of the data
+	Period string
+	// Reducer combines the values of a period. It is one of
+	// mean, sum, min and max and defaults to the mean.
+	Reducer string
+	// Anomaly is one of the utils.WPSAnomaly values or empty
+	Anomaly       string
+	BaselineStart time.Time
+	BaselineEnd   time.Time
+}
+
+// IsZero reports whether the aggregation leaves the
+// time series unchanged
+func (ta TemporalAggregation) IsZero() bool {
+	return len(ta.Period) == 0 && len(ta.Anomaly) == 0
+}
+
+// TimeRange returns the time range that must be drilled
+// to compute the aggregation of the series between start
+// and end, which includes the baseline for anomalies
+func (ta TemporalAggregation) TimeRange(start, end time.Time) (time.Time, time.Time) {
+	if len(ta.Anomaly) == 0 {
+		return start, end
+	}
+	if !start.IsZero() && ta.BaselineStart.Before(start) {
+		start = ta.BaselineStart
+	}
+	if ta.BaselineEnd.After(end) {
+		end = ta.BaselineEnd
+	}
+	return start, end
+}
+
+type periodKey struct {
+	featureID string
+	start     time.Time
+}
+
+// aggregateTemporal aggregates the rows of the output dated
+// between start and end and computes their anomalies against
+// the climatology of the baseline period if requested.
+func aggregateTemporal(out *DrillOutput, ta TemporalAggregation, start, end time.Time) (*DrillOutput, error) {
+	if ta.IsZero() {
+		return out, nil
+	}
+
+	reducer := ta.Reducer
+	if len(reducer) == 0 {
+		reducer = utils.WPSReducerMean
+	}
+
+	res := &DrillOutput{Series: append([]string{}, out.Series...), Statistics: out.Statistics, FeatureColumn: out.FeatureColumn}
+
+	rows, err := reduceRows(out.Data, ta.Period, reducer, start, end)
+	if err != nil {
+		return nil, err
+	}
+	res.Data = rows
+
+	if len(ta.Anomaly) == 0 {
+		return res, nil
+	}
+
+	baseline, err := reduceRows(out.Data, ta.Period, reducer, ta.BaselineStart, ta.BaselineEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	suffix := "_anomaly"
+	if ta.Anomaly == utils.WPSAnomalyStandardized {
+		suffix = "_std_anomaly"
+	}
+	for iv := range res.Series {
+		res.Series[iv] += suffix
+	}
+
+	// The climatology of each feature is made of the mean and
+	// standard deviation of the values of each slot of the year
+	type climKey struct {
+		featureID string
+		slot      int
+	}
+	climValues := make(map[climKey][][]float64)
+	for _, row := range baseline {
+		key := climKey{row.FeatureID, climatologySlot(row.Date, ta.Period)}
+		if _, found := climValues[key]; !found {
+			climValues[key] = make([][]float64, len(row.Values))
+		}
+		for iv, val := range row.Values {
+			if iv < len(climValues[key]) && !math.IsNaN(val) {
+				climValues[key][iv] = append(climValues[key][iv], val)
+			}
+		}
+	}
+
+	climStats := make(map[climKey][]*utils.ZonalStats, len(climValues))
+	for key, values := range climValues {
+		climStats[key] = make([]*utils.ZonalStats, len(values))
+		for iv := range values {
+			climStats[key][iv] = utils.NewZonalStats(values[iv], 0)
+		}
+	}
+
+	for ir := range res.Data {
+		row := &res.Data[ir]
+		stats := climStats[climKey{row.FeatureID, climatologySlot(row.Date, ta.Period)}]
+		for iv, val := range row.Values {
+			if iv >= len(stats) || stats[iv].Count == 0 || math.IsNaN(val) {
+				row.Values[iv] = math.NaN()
+				continue
+			}
+
+			anomaly := val - stats[iv].Mean
+			if ta.Anomaly == utils.WPSAnomalyStandardized {
+				stdDev := stats[iv].StdDev()
+				if stdDev == 0 {
+					anomaly = math.NaN()
+				} else {
+					anomaly /= stdDev
+				}
+			}
+			row.Values[iv] = anomaly
+		}
+	}
+
+	return res, nil
+}
+
+// reduceRows reduces the values of the rows dated between
+// start and end per feature and period. Rows are dated by
+// the start of their period and sorted by date within each
+// feature. A zero start leaves the range open.
+func reduceRows(rows []DrillRow, period string, reducer string, start, end time.Time) ([]DrillRow, error) {
+	var keys []periodKey
+	groups := make(map[periodKey][]DrillRow)
+	featureOrder := make(map[string]int)
+	for _, row := range rows {
+		if (!start.IsZero() && row.Date.Before(start)) || row.Date.After(end) {
+			continue
+		}
+
+		if _, found := featureOrder[row.FeatureID]; !found {
+			featureOrder[row.FeatureID] = len(featureOrder)
+		}
+
+		key := periodKey{row.FeatureID, periodStart(row.Date, period)}
+		if _, found := groups[key]; !found {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], row)
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].featureID != keys[j].featureID {
+			return featureOrder[keys[i].featureID] < featureOrder[keys[j].featureID]
+		}
+		return keys[i].start.Before(keys[j].start)
+	})
+
+	res := make([]DrillRow, 0, len(keys))
+	for _, key := range keys {
+		group := groups[key]
+		reduced := DrillRow{FeatureID: key.featureID, Date: key.start, Values: make([]float64, len(group[0].Values))}
+		for iv := range reduced.Values {
+			val, err := reduceValues(group, iv, reducer)
+			if err != nil {
+				return nil, err
+			}
+			reduced.Values[iv] = val
+		}
+		res = append(res, reduced)
+	}
+	return res, nil
+}
+
+func reduceValues(rows []DrillRow, iv int, reducer string) (float64, error) {
+	res := math.NaN()
+	count := 0
+	for _, row := range rows {
+		if iv >= len(row.Values) || math.IsNaN(row.Values[iv]) {
+			continue
+		}
+		val := row.Values[iv]
+		count++
+
+		if count == 1 {
+			res = val
+			continue
+		}
+
+		switch reducer {
+		case utils.WPSReducerMean, utils.WPSReducerSum:
+			res += val
+		case utils.WPSReducerMin:
+			res = math.Min(res, val)
+		case utils.WPSReducerMax:
+			res = math.Max(res, val)
+		default:
+			return 0, fmt.Errorf("WPS: unsupported temporal reducer: %s", reducer)
+		}
+	}
+
+	if reducer == utils.WPSReducerMean && count > 0 {
+		res /= float64(count)
+	}
+	return res, nil
+}
+
+// periodStart returns the start of the period containing
+// the date. Seasons are the three month periods starting in
+// December, March, June and September.
+func periodStart(date time.Time, period string) time.Time {
+	date = date.UTC()
+	switch period {
+	case utils.WPSPeriodMonthly:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	case utils.WPSPeriodSeasonal:
+		month := date.Month() - (date.Month() % 3)
+		year := date.Year()
+		if month == 0 {
+			month = time.December
+			year--
+		}
+		return time.Date(year, month, 1, 0, 0, 0, 0, time.UTC)
+	case utils.WPSPeriodAnnual:
+		return time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return date
+}
+
+// climatologySlot returns the slot of the year of a period
+// start. Dates that are not aggregated fall in the slot of
+// their month.
+func climatologySlot(date time.Time, period string) int {
+	switch period {
+	case utils.WPSPeriodAnnual:
+		return 0
+	case utils.WPSPeriodSeasonal:
+		return int(date.Month()) / 3
+	}
+	return int(date.Month())
+}
@@ -0,0 +1,74 @@
+package processor
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/nci/gsky/utils"
+)
+
+func TestAggregateTemporal(t *testing.T) {
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	out := &DrillOutput{Series: []string{"Prec"}}
+	for year := 2000; year <= 2003; year++ {
+		for _, month := range []time.Month{time.January, time.February, time.July} {
+			val := float64(year-2000) + float64(month)
+			out.Data = append(out.Data, DrillRow{Date: date(year, month, 1), Values: []float64{val}}, DrillRow{Date: date(year, month, 15), Values: []float64{val + 1}})
+		}
+	}
+	out.Data = append(out.Data, DrillRow{Date: date(2003, time.December, 1), Values: []float64{math.NaN()}})
+
+	monthly, err := aggregateTemporal(out, TemporalAggregation{Period: utils.WPSPeriodMonthly, Reducer: utils.WPSReducerSum}, date(2003, time.January, 1), date(2003, time.December, 31))
+	if err != nil {
+		t.Fatalf("failed to aggregate: %v", err)
+	}
+	if len(monthly.Data) != 4 {
+		t.Fatalf("expected 4 monthly rows, got %d", len(monthly.Data))
+	}
+	if row := monthly.Data[1]; !row.Date.Equal(date(2003, time.February, 1)) || row.Values[0] != 11 {
+		t.Errorf("unexpected monthly row: %+v", row)
+	}
+	if !math.IsNaN(monthly.Data[3].Values[0]) {
+		t.Errorf("expected NaN for a period without data, got %f", monthly.Data[3].Values[0])
+	}
+
+	seasonal, err := aggregateTemporal(out, TemporalAggregation{Period: utils.WPSPeriodSeasonal, Reducer: utils.WPSReducerMax}, date(2001, time.January, 1), date(2001, time.December, 31))
+	if err != nil {
+		t.Fatalf("failed to aggregate: %v", err)
+	}
+	if len(seasonal.Data) != 2 || !seasonal.Data[0].Date.Equal(date(2000, time.December, 1)) || seasonal.Data[0].Values[0] != 4 {
+		t.Errorf("unexpected seasonal rows: %+v", seasonal.Data)
+	}
+
+	ta := TemporalAggregation{Period: utils.WPSPeriodAnnual, Anomaly: utils.WPSAnomaly, BaselineStart: date(2000, time.January, 1), BaselineEnd: date(2001, time.December, 31)}
+	anomalies, err := aggregateTemporal(out, ta, date(2003, time.January, 1), date(2003, time.December, 31))
+	if err != nil {
+		t.Fatalf("failed to compute anomalies: %v", err)
+	}
+	if anomalies.Series[0] != "Prec_anomaly" || len(anomalies.Data) != 1 {
+		t.Fatalf("unexpected anomalies: %v, %+v", anomalies.Series, anomalies.Data)
+	}
+	// The annual means are 23/6 + year - 2000, the baseline
+	// mean is 26/6 and its standard deviation is 0.5
+	if val := anomalies.Data[0].Values[0]; math.Abs(val-2.5) > 1e-9 {
+		t.Errorf("expected anomaly 2.5, got %f", val)
+	}
+
+	ta.Anomaly = utils.WPSAnomalyStandardized
+	standardized, err := aggregateTemporal(out, ta, date(2003, time.January, 1), date(2003, time.December, 31))
+	if err != nil {
+		t.Fatalf("failed to compute standardized anomalies: %v", err)
+	}
+	if val := standardized.Data[0].Values[0]; math.Abs(val-5) > 1e-9 {
+		t.Errorf("expected standardized anomaly 5, got %f", val)
+	}
+
+	start, end := ta.TimeRange(date(2003, time.January, 1), date(2003, time.December, 31))
+	if !start.Equal(ta.BaselineStart) || !end.Equal(date(2003, time.December, 31)) {
+		t.Errorf("unexpected drill time range: %v, %v", start, end)
+	}
+}
@@ -1,7 +1,11 @@
 package processor
 
 import (
+	"bytes"
+	"fmt"
 	"image"
+	"math"
+	"strings"
 	"time"
 
 	"github.com/nci/gsky/utils"
@@ -9,13 +13,28 @@ import (
 )
 
 type GeoDrillRequest struct {
-	Geometry   string
-	CRS        string
-	Collection string
-	NameSpaces []string
-	BandExpr   *utils.BandExpressions
-	StartTime  time.Time
-	EndTime    time.Time
+	// Geometry is a GeoJSON feature in the CRS which is
+	// drilled by the workers. IndexGeometry is the same
+	// feature in WGS84 used to query MAS if CRS is not WGS84.
+	Geometry      string
+	IndexGeometry string
+	CRS           string
+	Collection    string
+	NameSpaces    []string
+	BandExpr      *utils.BandExpressions
+	Statistics    []string
+	FeatureID     string
+	Histogram     bool
+	ClassLabels   []utils.ClassLabel
+	// ProfileSpacing is the distance in metres between the
+	// samples of a profile along a LineString geometry
+	ProfileSpacing float64
+	// Exact weights the pixels by the fraction of their
+	// area covered by the geometry
+	Exact     bool
+	Temporal  TemporalAggregation
+	StartTime time.Time
+	EndTime   time.Time
 }
 
 type GeoDrillGranule struct {
@@ -31,10 +50,122 @@ type GeoDrillGranule struct {
 	Approx       bool
 }
 
+// DrillResult contains the time series of a granule. The
+// result of a granule whose drill has been cut short by the
+// deadline of a partial drill is Incomplete and has no data.
 type DrillResult struct {
-	NameSpace string
-	Dates     []time.Time
-	Data      []*pb.TimeSeries
+	NameSpace  string
+	Dates      []time.Time
+	Data       []*pb.TimeSeries
+	Incomplete bool
+}
+
+// DrillRow contains the values of the time series
+// of a drill at a given date. Missing values are NaN.
+type DrillRow struct {
+	FeatureID string
+	Date      time.Time
+	Values    []float64
+}
+
+// DrillOutput contains the time series computed by a drill.
+// Series holds the name of the series of each value of the
+// rows. If FeatureColumn is not empty, the rows come from
+// several features and are keyed by their FeatureID. Partial
+// outputs only contain the dates drilled before the deadline.
+type DrillOutput struct {
+	Series        []string
+	Data          []DrillRow
+	Statistics    []string
+	FeatureColumn string
+	Partial       bool
+}
+
+// Rows returns the CSV rows embedded in the WPS output
+// templates, which are separated by escaped new lines.
+func (o *DrillOutput) Rows() string {
+	csv := bytes.NewBufferString("")
+	for _, row := range o.Data {
+		if len(o.FeatureColumn) > 0 {
+			fmt.Fprintf(csv, "%s,", csvFieldValue(row.FeatureID))
+		}
+		fmt.Fprintf(csv, "%s", row.Date.Format(ISOFormat))
+
+		for _, val := range row.Values {
+			fmt.Fprint(csv, ",")
+			if !math.IsNaN(val) {
+				fmt.Fprintf(csv, "%f", val)
+			}
+		}
+
+		fmt.Fprint(csv, "\\n")
+	}
+	return csv.String()
+}
+
+// String returns the rows so that templates written
+// for mean values keep working.
+func (o *DrillOutput) String() string {
+	return o.Rows()
+}
+
+// Columns expands the comma separated CSV header of a
+// template into one column per requested statistic. The
+// first column holds the dates and is kept unchanged as
+// are the other columns when only the mean is requested.
+func (o *DrillOutput) Columns(header string) string {
+	names := strings.Split(header, ",")
+	columns := []string{}
+	if len(o.FeatureColumn) > 0 {
+		columns = append(columns, csvFieldValue(o.FeatureColumn))
+	}
+	columns = append(columns, names[0])
+	columns = append(columns, seriesNames(names[1:], o.Statistics)...)
+	return strings.Join(columns, ",")
+}
+
+// Header returns the CSV header of the rows made of the
+// date column and the name of every series. It suits
+// outputs whose series are only known at run time such
+// as class histograms.
+func (o *DrillOutput) Header() string {
+	columns := []string{}
+	if len(o.FeatureColumn) > 0 {
+		columns = append(columns, csvFieldValue(o.FeatureColumn))
+	}
+	columns = append(columns, "date")
+	for _, name := range o.Series {
+		columns = append(columns, csvFieldValue(name))
+	}
+	return strings.Join(columns, ",")
+}
+
+// Render executes the WPS output template with the output.
+// The suffix replaces the %s verb left in the template.
+func (o *DrillOutput) Render(templateFileName string, suffix string) (string, error) {
+	out := bytes.NewBufferString("")
+	err := utils.ExecuteWriteTemplateFile(out, o, templateFileName)
+	if err != nil {
+		return "", fmt.Errorf("WPS: output template error: %v", err)
+	}
+	return fmt.Sprintf(out.String(), suffix), nil
+}
+
+// seriesNames returns the name of the series of every
+// statistic of each variable. Names are left unchanged
+// when only the mean is computed.
+func seriesNames(names []string, statistics []string) []string {
+	if len(statistics) == 0 || (len(statistics) == 1 && statistics[0] == utils.DefaultZonalStatistic) {
+		return append([]string{}, names...)
+	}
+
+	var series []string
+	for _, name := range names {
+		for _, stat := range statistics {
+			series = append(series, name+"_"+stat)
+		}
+	}
+	return series
 }
 
 type DrillFileDescriptor struct {
@@ -90,6 +90,18 @@ func GetFeatureInfo(ctx context.Context, params utils.WMSParams, conf *utils.Con
 			} else {
 				valueStr = fmt.Sprintf("%v", value)
 			}
+
+		case *utils.TypedRaster:
+			pixels, err := utils.NewPixels(t.Type, t.Data)
+			if err != nil {
+				return "", err
+			}
+			value := pixels.Value(offset)
+			if value == utils.CastValue(t.Type, t.NoData) {
+				valueStr = `"n/a"`
+			} else {
+				valueStr = fmt.Sprintf("%v", value)
+			}
 		}
 
 		out += fmt.Sprintf(`"%s": %s`, ns, valueStr)
@@ -137,12 +149,12 @@ func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config,
 		return nil, nil, nil, fmt.Errorf("Request should contain a valid 'bbox' parameter.")
 	}
 
+	if params.Height == nil || params.Width == nil {
+		return nil, nil, nil, fmt.Errorf("Request should contain valid 'width' and 'height' parameters.")
+	}
+
 	xRes := (params.BBox[2] - params.BBox[0]) / float64(*params.Width)
 	yRes := (params.BBox[3] - params.BBox[1]) / float64(*params.Height)
-	reqRes := xRes
-	if yRes > reqRes {
-		reqRes = yRes
-	}
 
 	styleIdx, err := utils.GetLayerStyleIndex(params, conf, idx)
 	if err != nil {
@@ -167,13 +179,6 @@ func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config,
 		bandExpr = styleLayer.RGBExpressions
 	}
 
-	if conf.Layers[idx].ZoomLimit != 0.0 && reqRes > conf.Layers[idx].ZoomLimit {
-		return []utils.Raster{&utils.ByteRaster{NameSpace: "ZoomOut"}}, bandExpr.ExprNames, nil, nil
-	}
-
-	if params.Height == nil || params.Width == nil {
-		return nil, nil, nil, fmt.Errorf("Request should contain valid 'width' and 'height' parameters.")
-	}
 	if *params.Height > conf.Layers[idx].WmsMaxHeight || *params.Width > conf.Layers[idx].WmsMaxWidth {
 		return nil, nil, nil, fmt.Errorf("Requested width/height is too large, max width:%d, height:%d", conf.Layers[idx].WmsMaxWidth, conf.Layers[idx].WmsMaxHeight)
 	}
@@ -199,6 +204,28 @@ func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config,
 		return nil, nil, nil, fmt.Errorf("Invalid data source")
 	}
 
+	reqRes := utils.RequestResolution(*params.CRS, params.BBox, *params.Width, *params.Height)
+	geoReq := &GeoTileRequest{ConfigPayLoad: ConfigPayLoad{NameSpaces: namespaces,
+		BandExpr:        bandExpr,
+		Mask:            styleLayer.Mask,
+		ZoomLimit:       conf.Layers[idx].ZoomLimit,
+		PolygonSegments: conf.Layers[idx].WmsPolygonSegments,
+		GrpcConcLimit:   conf.Layers[idx].GrpcWmsConcPerNode,
+		QueryLimit:      -1,
+	},
+		Collection: styleLayer.ResolutionDataSource(reqRes),
+		CRS:        *params.CRS,
+		BBox:       params.BBox,
+		Height:     *params.Height,
+		Width:      *params.Width,
+		StartTime:  params.Time,
+		EndTime:    endTime,
+	}
+
+	if zoomedOut, _ := ZoomedOut(ctx, conf.ServiceConfig.MASAddress, styleLayer, conf.Layers[idx].ZoomLimit, geoReq, reqRes, verbose); zoomedOut {
+		return []utils.Raster{&utils.ByteRaster{NameSpace: "ZoomOut"}}, bandExpr.ExprNames, nil, nil
+	}
+
 	// We construct a 2x2 image corresponding to an infinitesimal bounding box
 	// to approximate a pixel.
 	// We observed several order of magnitude of performance improvement as a
@@ -217,22 +244,9 @@ func getRaster(ctx context.Context, params utils.WMSParams, conf *utils.Config,
 
 	params.BBox = []float64{xmin, ymin, xmax, ymax}
 
-	geoReq := &GeoTileRequest{ConfigPayLoad: ConfigPayLoad{NameSpaces: namespaces,
-		BandExpr:        bandExpr,
-		Mask:            styleLayer.Mask,
-		ZoomLimit:       conf.Layers[idx].ZoomLimit,
-		PolygonSegments: conf.Layers[idx].WmsPolygonSegments,
-		GrpcConcLimit:   conf.Layers[idx].GrpcWmsConcPerNode,
-		QueryLimit:      -1,
-	},
-		Collection: styleLayer.DataSource,
-		CRS:        *params.CRS,
-		BBox:       params.BBox,
-		Height:     *params.Height,
-		Width:      *params.Width,
-		StartTime:  params.Time,
-		EndTime:    endTime,
-	}
+	geoReq.BBox = params.BBox
+	geoReq.Height = *params.Height
+	geoReq.Width = *params.Width
 
 	ctx, ctxCancel := context.WithCancel(ctx)
 	defer ctxCancel()
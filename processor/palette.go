@@ -26,46 +26,7 @@ func InterpolateColor(a, b color.RGBA, i, sectionLength int) color.RGBA {
 
 // GradientRGBAPalette returns a palette of 256 colors creating an
 // interpolation that goes though a list of provided colours.
+// It is the palette of utils.EncodePNG.
 func GradientRGBAPalette(palette *utils.Palette) ([]color.RGBA, error) {
-	if palette == nil {
-		return nil, nil
-	}
-
-	ramp := make([]color.RGBA, 256)
-
-	if palette.Interpolate {
-		bins := len(palette.Colours) - 1
-		sectionLength := 256 / bins
-		bonus := 256 - (sectionLength * bins)
-		bonusArr := make([]int, bins)
-		for i := 0; i < bonus; i++ {
-			bonusArr[i] = 1
-		}
-
-		index := 0
-		for section, upperColour := range palette.Colours[1:] {
-			for i := 0; i < sectionLength+bonusArr[section]; i++ {
-				ramp[index] = InterpolateColor(palette.Colours[section], upperColour, i, sectionLength)
-				index++
-			}
-		}
-	} else {
-		bins := len(palette.Colours)
-		sectionLength := 256 / bins
-		bonus := 256 - (sectionLength * bins)
-		bonusArr := make([]int, bins)
-		for i := 0; i < bonus; i++ {
-			bonusArr[i] = 1
-		}
-
-		index := 0
-		for section, colour := range palette.Colours {
-			for i := 0; i < sectionLength+bonusArr[section]; i++ {
-				ramp[index] = colour
-				index++
-			}
-		}
-	}
-
-	return ramp, nil
+	return utils.GradientRGBAPalette(palette)
 }
@@ -0,0 +1,306 @@
+package processor
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"unsafe"
+
+	"github.com/nci/gsky/utils"
+)
+
+// CompositeStack reduces the rasters of a namespace observed
+// at different dates into a single Float32 raster. The sum,
+// count, min and max of the pixels are accumulated as the
+// rasters stream in. The median needs the values of every
+// date, which are kept as one plane per date. A pixel seen by
+// overlapping granules of the same date counts once.
+type CompositeStack struct {
+	Reducer       string
+	NoData        float64
+	Height, Width int
+	OffX, OffY    int
+	ConfigPayLoad ConfigPayLoad
+
+	sum    []float64
+	count  []uint32
+	min    []float32
+	max    []float32
+	seen   map[int64][]uint64
+	planes map[int64][]float32
+	bytes  int
+}
+
+// NewCompositeStack returns the composite stack of the
+// namespace of a raster
+func NewCompositeStack(reducer string, r *FlexRaster) *CompositeStack {
+	s := &CompositeStack{Reducer: reducer, NoData: r.NoData,
+		Height: r.Height, Width: r.Width, OffX: r.OffX, OffY: r.OffY,
+		ConfigPayLoad: r.ConfigPayLoad,
+		seen:          map[int64][]uint64{},
+		planes:        map[int64][]float32{},
+	}
+
+	size := r.Height * r.Width
+	switch reducer {
+	case utils.CompositeMedian:
+	case utils.CompositeMin:
+		s.min = make([]float32, size)
+		s.count = make([]uint32, size)
+		s.bytes = 8 * size
+	case utils.CompositeMax:
+		s.max = make([]float32, size)
+		s.count = make([]uint32, size)
+		s.bytes = 8 * size
+	default:
+		s.sum = make([]float64, size)
+		s.count = make([]uint32, size)
+		s.bytes = 12 * size
+	}
+	return s
+}
+
+// Add accumulates the valid pixels of a raster which are not
+// masked out
+func (s *CompositeStack) Add(r *FlexRaster, mask []bool) error {
+	if r.Height != s.Height || r.Width != s.Width {
+		return fmt.Errorf("composite of %s: raster size %dx%d differs from %dx%d", r.NameSpace, r.Width, r.Height, s.Width, s.Height)
+	}
+
+	data, err := flexRasterValues(r)
+	if err != nil {
+		return err
+	}
+
+	date := r.TimeStamp.UnixNano()
+	if s.Reducer == utils.CompositeMedian {
+		plane, found := s.planes[date]
+		if !found {
+			if err := s.reserve(SizeofFloat32 * len(data)); err != nil {
+				return err
+			}
+			plane = make([]float32, len(data))
+			for i := range plane {
+				plane[i] = float32(math.NaN())
+			}
+			s.planes[date] = plane
+		}
+		for i, val := range data {
+			if !math.IsNaN(float64(val)) && !mask[i] && math.IsNaN(float64(plane[i])) {
+				plane[i] = val
+			}
+		}
+		return nil
+	}
+
+	seen, found := s.seen[date]
+	if !found {
+		if err := s.reserve(8 * ((len(data) + 63) / 64)); err != nil {
+			return err
+		}
+		seen = make([]uint64, (len(data)+63)/64)
+		s.seen[date] = seen
+	}
+
+	for i, val := range data {
+		if math.IsNaN(float64(val)) || mask[i] || seen[i/64]&(1<<uint(i%64)) != 0 {
+			continue
+		}
+		seen[i/64] |= 1 << uint(i%64)
+
+		switch s.Reducer {
+		case utils.CompositeMin:
+			if s.count[i] == 0 || val < s.min[i] {
+				s.min[i] = val
+			}
+		case utils.CompositeMax:
+			if s.count[i] == 0 || val > s.max[i] {
+				s.max[i] = val
+			}
+		default:
+			s.sum[i] += float64(val)
+		}
+		s.count[i]++
+	}
+	return nil
+}
+
+func (s *CompositeStack) reserve(bytes int) error {
+	if s.bytes+bytes > utils.CompositeMaxBytes {
+		return fmt.Errorf("%s composite exceeds the limit of %d MB, please reduce the time range or the size of the request", s.Reducer, utils.CompositeMaxBytes/(1024*1024))
+	}
+	s.bytes += bytes
+	return nil
+}
+
+// Reduce returns the composite as a Float32 raster. Pixels
+// without observations are nodata except for the count
+// composite which is zero.
+func (s *CompositeStack) Reduce(nameSpace string) *FlexRaster {
+	data := make([]uint8, SizeofFloat32*s.Height*s.Width)
+	headr := *(*reflect.SliceHeader)(unsafe.Pointer(&data))
+	headr.Len /= SizeofFloat32
+	headr.Cap /= SizeofFloat32
+	out := *(*[]float32)(unsafe.Pointer(&headr))
+	noData := float32(s.NoData)
+
+	switch s.Reducer {
+	case utils.CompositeMedian:
+		var dates []int64
+		for date := range s.planes {
+			dates = append(dates, date)
+		}
+		values := make([]float32, 0, len(dates))
+		for i := range out {
+			values = values[:0]
+			for _, date := range dates {
+				if val := s.planes[date][i]; !math.IsNaN(float64(val)) {
+					values = append(values, val)
+				}
+			}
+			out[i] = median(values, noData)
+		}
+	case utils.CompositeCount:
+		for i := range out {
+			out[i] = float32(s.count[i])
+		}
+	default:
+		for i := range out {
+			if s.count[i] == 0 {
+				out[i] = noData
+				continue
+			}
+			switch s.Reducer {
+			case utils.CompositeMin:
+				out[i] = s.min[i]
+			case utils.CompositeMax:
+				out[i] = s.max[i]
+			case utils.CompositeSum:
+				out[i] = float32(s.sum[i])
+			default:
+				out[i] = float32(s.sum[i] / float64(s.count[i]))
+			}
+		}
+	}
+
+	return &FlexRaster{ConfigPayLoad: s.ConfigPayLoad, NoData: s.NoData, Data: data,
+		Height: s.Height, Width: s.Width, OffX: s.OffX, OffY: s.OffY,
+		Type: "Float32", NameSpace: nameSpace}
+}
+
+// median sorts values in place and returns their median
+func median(values []float32, noData float32) float32 {
+	n := len(values)
+	if n == 0 {
+		return noData
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i] < values[j] })
+	if n%2 == 1 {
+		return values[n/2]
+	}
+	return (values[n/2-1] + values[n/2]) / 2
+}
+
+// flexRasterValues returns the pixels of a raster as float32
+// with NaN in place of nodata
+func flexRasterValues(r *FlexRaster) ([]float32, error) {
+	header := *(*reflect.SliceHeader)(unsafe.Pointer(&r.Data))
+
+	var out []float32
+	switch r.Type {
+	case "Byte":
+		data := *(*[]uint8)(unsafe.Pointer(&header))
+		nodata := uint8(r.NoData)
+		out = make([]float32, len(data))
+		for i, val := range data {
+			if val == nodata {
+				out[i] = float32(math.NaN())
+			} else {
+				out[i] = float32(val)
+			}
+		}
+	case "Int16":
+		header.Len /= SizeofInt16
+		header.Cap /= SizeofInt16
+		data := *(*[]int16)(unsafe.Pointer(&header))
+		nodata := int16(r.NoData)
+		out = make([]float32, len(data))
+		for i, val := range data {
+			if val == nodata {
+				out[i] = float32(math.NaN())
+			} else {
+				out[i] = float32(val)
+			}
+		}
+	case "UInt16":
+		header.Len /= SizeofUint16
+		header.Cap /= SizeofUint16
+		data := *(*[]uint16)(unsafe.Pointer(&header))
+		nodata := uint16(r.NoData)
+		out = make([]float32, len(data))
+		for i, val := range data {
+			if val == nodata {
+				out[i] = float32(math.NaN())
+			} else {
+				out[i] = float32(val)
+			}
+		}
+	case "Float32":
+		header.Len /= SizeofFloat32
+		header.Cap /= SizeofFloat32
+		data := *(*[]float32)(unsafe.Pointer(&header))
+		nodata := float32(r.NoData)
+		out = make([]float32, len(data))
+		for i, val := range data {
+			if val == nodata || math.IsInf(float64(val), 0) {
+				out[i] = float32(math.NaN())
+			} else {
+				out[i] = val
+			}
+		}
+	default:
+		pixels, err := utils.NewPixels(r.Type, r.Data)
+		if err != nil {
+			return nil, fmt.Errorf("composite hasn't been implemented for Raster type %s", r.Type)
+		}
+		nodata := utils.CastValue(r.Type, r.NoData)
+		out = make([]float32, pixels.Len())
+		for i := range out {
+			if val := pixels.Value(i); val == nodata || math.IsInf(val, 0) {
+				out[i] = float32(math.NaN())
+			} else {
+				out[i] = float32(val)
+			}
+		}
+	}
+	return out, nil
+}
+
+// ProcessCompositeStack adds a batch of rasters to the
+// composite stacks of their namespaces
+func ProcessCompositeStack(rasterStack map[int64][]*FlexRaster, maskMap map[int64][]bool, stackMap map[string]*CompositeStack) (map[string]*CompositeStack, error) {
+	for geoStamp, rasters := range rasterStack {
+		for _, r := range rasters {
+			if _, ok := stackMap[r.NameSpace]; !ok {
+				stackMap[r.NameSpace] = NewCompositeStack(r.Composite, r)
+			}
+
+			mask, ok := maskMap[geoStamp]
+			if !ok {
+				mask = make([]bool, r.Height*r.Width)
+			}
+			if err := stackMap[r.NameSpace].Add(r, mask); err != nil {
+				return stackMap, err
+			}
+		}
+		delete(rasterStack, geoStamp)
+	}
+	return stackMap, nil
+}
+
+// isComposite tells whether a raster is merged by a
+// composite reducer rather than by the latest pixel
+func isComposite(r *FlexRaster) bool {
+	return len(r.Composite) > 0 && r.Composite != utils.CompositeLatest
+}
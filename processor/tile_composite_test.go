@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/nci/gsky/utils"
+)
+
+func TestCompositeStack(t *testing.T) {
+	newRaster := func(day int, polygon string, values ...uint16) *FlexRaster {
+		data := make([]byte, SizeofUint16*len(values))
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&data))
+		headr.Len /= SizeofUint16
+		headr.Cap /= SizeofUint16
+		copy(*(*[]uint16)(unsafe.Pointer(&headr)), values)
+		return &FlexRaster{Data: data, Height: 1, Width: len(values), Type: "UInt16", NoData: 0,
+			NameSpace: "ndvi", TimeStamp: time.Date(2018, time.January, day, 0, 0, 0, 0, time.UTC), Polygon: polygon}
+	}
+
+	// The second granule of January 2 overlaps the first one
+	// and must not be counted twice
+	rasters := []*FlexRaster{
+		newRaster(1, "a", 1, 4, 0, 0),
+		newRaster(2, "a", 3, 2, 0, 0),
+		newRaster(2, "b", 3, 2, 5, 0),
+		newRaster(3, "a", 8, 0, 0, 0),
+	}
+	mask := []bool{false, false, false, false}
+	masked := []bool{false, true, false, false}
+
+	expected := map[string][]float32{
+		utils.CompositeMedian: {3, 3, 5, -1},
+		utils.CompositeMean:   {4, 3, 5, -1},
+		utils.CompositeMin:    {1, 2, 5, -1},
+		utils.CompositeMax:    {8, 4, 5, -1},
+		utils.CompositeCount:  {3, 2, 1, 0},
+		utils.CompositeSum:    {12, 6, 5, -1},
+	}
+
+	for reducer, values := range expected {
+		stack := NewCompositeStack(reducer, rasters[0])
+		stack.NoData = -1
+		for i, r := range rasters {
+			m := mask
+			if i == 3 {
+				m = masked
+			}
+			if err := stack.Add(r, m); err != nil {
+				t.Fatalf("%s: failed to add raster: %v", reducer, err)
+			}
+		}
+
+		out := stack.Reduce("ndvi")
+		if out.Type != "Float32" || out.NameSpace != "ndvi" {
+			t.Fatalf("%s: unexpected composite %s %s", reducer, out.Type, out.NameSpace)
+		}
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out.Data))
+		headr.Len /= SizeofFloat32
+		headr.Cap /= SizeofFloat32
+		data := *(*[]float32)(unsafe.Pointer(&headr))
+		if !reflect.DeepEqual(data, values) {
+			t.Errorf("%s: expected %v, got %v", reducer, values, data)
+		}
+	}
+}
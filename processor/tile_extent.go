@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"unsafe"
 
+	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -142,3 +143,64 @@ func ComputeReprojectionExtent(ctx context.Context, geoReq *GeoTileRequest, masA
 
 	return maxWidth, maxHeight, nil
 }
+
+// ComputeNativeGrid returns the finest pixel grid among the
+// granules intersecting geoReq, expressed in the CRS given
+// by epsg. The grid is derived from the geotransforms MAS
+// holds for each dataset.
+func ComputeNativeGrid(ctx context.Context, geoReq *GeoTileRequest, masAddress string, epsg int, verbose bool) (*utils.NativeGrid, error) {
+	errChan := make(chan error, 100)
+	indexer := NewTileIndexer(ctx, masAddress, errChan)
+	go func() {
+		indexer.In <- geoReq
+		close(indexer.In)
+	}()
+
+	go indexer.Run(verbose)
+
+	var nativeGrid *utils.NativeGrid
+	visited := make(map[string]bool)
+	for gran := range indexer.Out {
+		select {
+		case err := <-errChan:
+			return nil, err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		default:
+		}
+
+		if gran.Path == "NULL" || len(gran.GeoTransform) != 6 {
+			continue
+		}
+
+		// Granules sharing a pixel size and projection
+		// yield the same grid resolution
+		gridKey := fmt.Sprintf("%v_%v_%s", gran.GeoTransform[1], gran.GeoTransform[5], gran.ProjWKT)
+		if visited[gridKey] {
+			continue
+		}
+		visited[gridKey] = true
+
+		grid, err := utils.ReprojectGrid(gran.GeoTransform, gran.ProjWKT, epsg, geoReq.BBox)
+		if err != nil {
+			if verbose {
+				log.Printf("tile_extent: %v: %v", gran.Path, err)
+			}
+			continue
+		}
+
+		if nativeGrid == nil || grid.ResX*grid.ResY < nativeGrid.ResX*nativeGrid.ResY {
+			nativeGrid = grid
+		}
+	}
+
+	if nativeGrid == nil {
+		return nil, fmt.Errorf("unable to find the native grid of %v", geoReq.Collection)
+	}
+
+	if verbose {
+		log.Printf("tile_extent: native grid %+v", *nativeGrid)
+	}
+
+	return nativeGrid, nil
+}
@@ -10,6 +10,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
 	"golang.org/x/net/context"
 	"google.golang.org/grpc"
@@ -259,19 +260,7 @@ func (gi *GeoRasterGRPC) sendError(err error) {
 }
 
 func getDataSize(dataType string) (int, error) {
-	switch dataType {
-	case "Byte":
-		return 1, nil
-	case "Int16":
-		return 2, nil
-	case "UInt16":
-		return 2, nil
-	case "Float32":
-		return 4, nil
-	default:
-		return -1, fmt.Errorf("Unsupported raster type %s", dataType)
-
-	}
+	return utils.RasterTypeSize(dataType)
 }
 
 func getRPCRaster(ctx context.Context, g *GeoTileGranule, conn *grpc.ClientConn) (*pb.Result, error) {
@@ -279,7 +268,7 @@ func getRPCRaster(ctx context.Context, g *GeoTileGranule, conn *grpc.ClientConn)
 	band, err := getBand(g.TimeStamps, g.TimeStamp)
 	epsg, err := extractEPSGCode(g.CRS)
 	geot := BBox2Geot(g.Width, g.Height, g.BBox)
-	granule := &pb.GeoRPCGranule{Height: int32(g.Height), Width: int32(g.Width), Path: g.Path, EPSG: int32(epsg), Geot: geot, Bands: []int32{band}}
+	granule := &pb.GeoRPCGranule{Height: int32(g.Height), Width: int32(g.Width), Path: g.Path, EPSG: int32(epsg), Geot: geot, Bands: []int32{band}, Resampling: g.Resampling, Cutline: g.Cutline}
 	r, err := c.Process(ctx, granule)
 	if err != nil {
 		return nil, err
@@ -22,6 +22,22 @@ type GDALDataset struct {
 	Means        []float64   `json:"means"`
 	SampleCounts []int       `json:"sample_counts"`
 	NoData       float64     `json:"nodata"`
+	GeoTransform []float64   `json:"geotransform"`
+	ProjWKT      string      `json:"proj_wkt"`
+	XSize        int32       `json:"x_size"`
+	Overviews    []Overview  `json:"overviews"`
+}
+
+// OverviewFactor returns the downsampling factor of the
+// coarsest overview of a dataset, 1 if it has none
+func (ds *GDALDataset) OverviewFactor() float64 {
+	factor := 1.0
+	for _, ovr := range ds.Overviews {
+		if ovr.XSize > 0 && ds.XSize > 0 && float64(ds.XSize)/float64(ovr.XSize) > factor {
+			factor = float64(ds.XSize) / float64(ovr.XSize)
+		}
+	}
+	return factor
 }
 
 type MetadataResponse struct {
@@ -91,11 +107,71 @@ func (p *TileIndexer) Run(verbose bool) {
 					maskCollection = geoReq.Collection
 				}
 
-				if maskCollection != geoReq.Collection || geoReq.Mask.ID != nameSpaces {
+				// Namespaces already queried for the bands
+				// are not queried again
+				var maskNS []string
+				for _, ns := range geoReq.Mask.NameSpaces {
+					queried := false
+					if maskCollection == geoReq.Collection {
+						for _, band := range geoReq.NameSpaces {
+							if band == ns {
+								queried = true
+								break
+							}
+						}
+					}
+					if !queried {
+						maskNS = append(maskNS, ns)
+					}
+				}
+
+				maskNameSpaces := strings.Join(maskNS, ",")
+				if len(maskNS) > 0 {
+					if geoReq.EndTime == nil {
+						url = strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&srs=%s&wkt=%s&namespace=%s&nseg=%d&limit=%d", p.APIAddress, maskCollection, geoReq.StartTime.Format(ISOFormat), geoReq.CRS, BBox2WKT(geoReq.BBox), maskNameSpaces, geoReq.PolygonSegments, geoReq.QueryLimit), " ", "%20", -1)
+					} else {
+						url = strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&until=%s&srs=%s&wkt=%s&namespace=%s&nseg=%d&limit=%d", p.APIAddress, maskCollection, geoReq.StartTime.Format(ISOFormat), geoReq.EndTime.Format(ISOFormat), geoReq.CRS, BBox2WKT(geoReq.BBox), maskNameSpaces, geoReq.PolygonSegments, geoReq.QueryLimit), " ", "%20", -1)
+					}
+					if verbose {
+						log.Println(url)
+					}
+
+					wg.Add(1)
+					go URLIndexGet(p.Context, url, geoReq, p.Error, p.Out, &wg)
+				}
+			}
+			if geoReq.Score != nil && len(geoReq.Score.NameSpaces) > 0 {
+				scoreCollection := geoReq.Score.DataSource
+				if len(scoreCollection) == 0 {
+					scoreCollection = geoReq.Collection
+				}
+
+				// Namespaces already queried for the bands
+				// or the mask are not queried again
+				queried := make(map[string]bool)
+				if scoreCollection == geoReq.Collection {
+					for _, ns := range geoReq.NameSpaces {
+						queried[ns] = true
+					}
+				}
+				if geoReq.Mask != nil && (geoReq.Mask.DataSource == scoreCollection || len(geoReq.Mask.DataSource) == 0 && scoreCollection == geoReq.Collection) {
+					for _, ns := range geoReq.Mask.NameSpaces {
+						queried[ns] = true
+					}
+				}
+				var scoreNS []string
+				for _, ns := range geoReq.Score.NameSpaces {
+					if !queried[ns] {
+						scoreNS = append(scoreNS, ns)
+					}
+				}
+
+				scoreNameSpaces := strings.Join(scoreNS, ",")
+				if len(scoreNS) > 0 {
 					if geoReq.EndTime == nil {
-						url = strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&srs=%s&wkt=%s&namespace=%s&nseg=%d&limit=%d", p.APIAddress, maskCollection, geoReq.StartTime.Format(ISOFormat), geoReq.CRS, BBox2WKT(geoReq.BBox), geoReq.Mask.ID, geoReq.PolygonSegments, geoReq.QueryLimit), " ", "%20", -1)
+						url = strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&srs=%s&wkt=%s&namespace=%s&nseg=%d&limit=%d", p.APIAddress, scoreCollection, geoReq.StartTime.Format(ISOFormat), geoReq.CRS, BBox2WKT(geoReq.BBox), scoreNameSpaces, geoReq.PolygonSegments, geoReq.QueryLimit), " ", "%20", -1)
 					} else {
-						url = strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&until=%s&srs=%s&wkt=%s&namespace=%s&nseg=%d&limit=%d", p.APIAddress, maskCollection, geoReq.StartTime.Format(ISOFormat), geoReq.EndTime.Format(ISOFormat), geoReq.CRS, BBox2WKT(geoReq.BBox), geoReq.Mask.ID, geoReq.PolygonSegments, geoReq.QueryLimit), " ", "%20", -1)
+						url = strings.Replace(fmt.Sprintf("http://%s%s?intersects&metadata=gdal&time=%s&until=%s&srs=%s&wkt=%s&namespace=%s&nseg=%d&limit=%d", p.APIAddress, scoreCollection, geoReq.StartTime.Format(ISOFormat), geoReq.EndTime.Format(ISOFormat), geoReq.CRS, BBox2WKT(geoReq.BBox), scoreNameSpaces, geoReq.PolygonSegments, geoReq.QueryLimit), " ", "%20", -1)
 					}
 					if verbose {
 						log.Println(url)
@@ -151,7 +227,7 @@ func URLIndexGet(ctx context.Context, url string, geoReq *GeoTileRequest, errCha
 			}
 			for _, t := range ds.TimeStamps {
 				if t.Equal(*geoReq.StartTime) || geoReq.EndTime != nil && t.After(*geoReq.StartTime) && t.Before(*geoReq.EndTime) {
-					out <- &GeoTileGranule{ConfigPayLoad: ConfigPayLoad{NameSpaces: geoReq.NameSpaces, Mask: geoReq.Mask, ScaleParams: geoReq.ScaleParams, Palette: geoReq.Palette, GrpcConcLimit: geoReq.GrpcConcLimit}, Path: ds.DSName, NameSpace: ds.NameSpace, RasterType: ds.ArrayType, TimeStamps: ds.TimeStamps, TimeStamp: t, Polygon: ds.Polygon, BBox: geoReq.BBox, Height: geoReq.Height, Width: geoReq.Width, OffX: geoReq.OffX, OffY: geoReq.OffY, CRS: geoReq.CRS}
+					out <- &GeoTileGranule{ConfigPayLoad: ConfigPayLoad{NameSpaces: geoReq.NameSpaces, Mask: geoReq.Mask, ScaleParams: geoReq.ScaleParams, Palette: geoReq.Palette, GrpcConcLimit: geoReq.GrpcConcLimit, Resampling: geoReq.Resampling, Composite: geoReq.Composite, Score: geoReq.Score, TargetDate: geoReq.TargetDate, Cutline: geoReq.Cutline}, Path: ds.DSName, NameSpace: ds.NameSpace, RasterType: ds.ArrayType, TimeStamps: ds.TimeStamps, TimeStamp: t, Polygon: ds.Polygon, GeoTransform: ds.GeoTransform, ProjWKT: ds.ProjWKT, OverviewFactor: ds.OverviewFactor(), BBox: geoReq.BBox, Height: geoReq.Height, Width: geoReq.Width, OffX: geoReq.OffX, OffY: geoReq.OffY, CRS: geoReq.CRS}
 				}
 			}
 		}
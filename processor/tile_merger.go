@@ -2,13 +2,13 @@ package processor
 
 import (
 	"context"
+	"encoding/binary"
 	"fmt"
 	"hash/fnv"
 	"log"
 	"math"
 	"reflect"
 	"sort"
-	"strconv"
 	"time"
 	"unsafe"
 
@@ -139,58 +139,161 @@ func MergeMaskedRaster(r *FlexRaster, canvasMap map[string]*FlexRaster, mask []b
 			canvasMap[r.NameSpace].TimeStamp = r.TimeStamp
 		}
 	default:
-		err = fmt.Errorf("MergeMaskedRaster hasn't been implemented for Raster type %s", r.Type)
+		canvas, cErr := utils.NewPixels(r.Type, canvasMap[r.NameSpace].Data)
+		data, dErr := utils.NewPixels(r.Type, r.Data)
+		if cErr != nil || dErr != nil {
+			err = fmt.Errorf("MergeMaskedRaster hasn't been implemented for Raster type %s", r.Type)
+			return
+		}
+		nodata := utils.CastValue(r.Type, r.NoData)
+
+		if r.TimeStamp.Before(canvasMap[r.NameSpace].TimeStamp) {
+			for i := 0; i < data.Len(); i++ {
+				if val := data.Value(i); val != nodata && !mask[i] && canvas.Value(i) == nodata {
+					canvas.SetValue(i, val)
+				}
+			}
+		} else {
+			for i := 0; i < data.Len(); i++ {
+				if val := data.Value(i); val != nodata && !mask[i] {
+					canvas.SetValue(i, val)
+				}
+			}
+			canvasMap[r.NameSpace].TimeStamp = r.TimeStamp
+		}
 	}
 	return
 }
 
 func initNoDataSlice(rType string, noDataValue float64, size int) []uint8 {
-	switch rType {
-	case "Byte":
-		out := make([]uint8, size)
-		fill := uint8(noDataValue)
-		for i := 0; i < size; i++ {
-			out[i] = fill
-		}
-		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
-		return *(*[]uint8)(unsafe.Pointer(&headr))
-	case "Int16":
-		out := make([]int16, size)
-		fill := int16(noDataValue)
-		for i := 0; i < size; i++ {
-			out[i] = fill
-		}
-		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
-		headr.Len *= SizeofInt16
-		headr.Cap *= SizeofInt16
-		return *(*[]uint8)(unsafe.Pointer(&headr))
-	case "UInt16":
-		out := make([]uint16, size)
-		fill := uint16(noDataValue)
-		for i := 0; i < size; i++ {
-			out[i] = fill
-		}
-		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
-		headr.Len *= SizeofUint16
-		headr.Cap *= SizeofUint16
-		return *(*[]uint8)(unsafe.Pointer(&headr))
-	case "Float32":
-		out := make([]float32, size)
-		fill := float32(noDataValue)
-		for i := 0; i < size; i++ {
-			out[i] = fill
-		}
-		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
-		headr.Len *= SizeofFloat32
-		headr.Cap *= SizeofFloat32
-		return *(*[]uint8)(unsafe.Pointer(&headr))
-	default:
+	out, err := utils.NewNoDataBuffer(rType, noDataValue, size)
+	if err != nil {
 		return []uint8{}
 	}
+	return out
+}
+
+// MergeScoredRaster merges the valid pixels of a raster whose
+// score is higher than the score of the canvas pixels. Pixels
+// without a score rank below scored pixels and only fill the
+// canvas where it has no data.
+func MergeScoredRaster(r *FlexRaster, canvasMap map[string]*FlexRaster, mask []bool, score []float32, scoreCanvas map[string][]float32) error {
+	values, err := flexRasterValues(r)
+	if err != nil {
+		return fmt.Errorf("MergeScoredRaster hasn't been implemented for Raster type %s", r.Type)
+	}
+
+	canvasScore, ok := scoreCanvas[r.NameSpace]
+	if !ok {
+		canvasScore = make([]float32, len(values))
+		for i := range canvasScore {
+			canvasScore[i] = float32(math.NaN())
+		}
+		scoreCanvas[r.NameSpace] = canvasScore
+	}
+
+	win := make([]bool, len(values))
+	for i, val := range values {
+		if math.IsNaN(float64(val)) || mask[i] {
+			continue
+		}
+		s := float32(math.Inf(-1))
+		if score != nil && !math.IsNaN(float64(score[i])) {
+			s = score[i]
+		}
+		if math.IsNaN(float64(canvasScore[i])) || s > canvasScore[i] {
+			canvasScore[i] = s
+			win[i] = true
+		}
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	// The winning pixels are copied as bytes whatever their type
+	size := len(r.Data) / len(values)
+	canvas := canvasMap[r.NameSpace].Data
+	for i, w := range win {
+		if w {
+			copy(canvas[i*size:(i+1)*size], r.Data[i*size:(i+1)*size])
+		}
+	}
+	return nil
+}
+
+// ComputeScore evaluates the quality score of the pixels of
+// the rasters of a date and polygon given the rasters of the
+// auxiliary namespaces. It returns nil if an auxiliary
+// namespace is missing. Pixels whose auxiliary values are
+// nodata have a NaN score.
+func ComputeScore(score *utils.QualityScore, auxRasters map[string]*FlexRaster, timeStamp time.Time, target time.Time, size int) ([]float32, error) {
+	parameters := make(map[string]interface{}, len(score.Expr.VarList))
+	valid := make([]bool, size)
+	for i := range valid {
+		valid[i] = true
+	}
+
+	for _, ns := range score.NameSpaces {
+		r, found := auxRasters[ns]
+		if !found {
+			return nil, nil
+		}
+		values, err := flexRasterValues(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != size {
+			return nil, fmt.Errorf("score namespace %s has %d pixels instead of %d", ns, len(values), size)
+		}
+		for i, val := range values {
+			if math.IsNaN(float64(val)) {
+				valid[i] = false
+			}
+		}
+		parameters[ns] = values
+	}
+
+	for _, v := range score.Expr.VarList {
+		if v == utils.QualityScoreDays {
+			days := float32(math.Abs(timeStamp.Sub(target).Hours()) / 24)
+			values := make([]float32, size)
+			for i := range values {
+				values[i] = days
+			}
+			parameters[v] = values
+		}
+	}
 
+	out := make([]float32, size)
+	if len(score.Expr.Expressions) == 0 {
+		copy(out, parameters[score.Expr.VarList[0]].([]float32))
+	} else {
+		result, err := score.Expr.Expressions[0].Evaluate(parameters)
+		if err != nil {
+			return nil, fmt.Errorf("score expression '%v' error: %v", score.Expression, err)
+		}
+		switch res := result.(type) {
+		case float32:
+			for i := range out {
+				out[i] = res
+			}
+		case []float32:
+			copy(out, res)
+		default:
+			return nil, fmt.Errorf("unknown data type for returned value '%v' for score expression '%v'", result, score.Expression)
+		}
+	}
+
+	for i, val := range out {
+		if !valid[i] || math.IsInf(float64(val), 0) {
+			out[i] = float32(math.NaN())
+		}
+	}
+	return out, nil
 }
 
-func ProcessRasterStack(rasterStack map[int64][]*FlexRaster, maskMap map[int64][]bool, canvasMap map[string]*FlexRaster) (map[string]*FlexRaster, error) {
+func ProcessRasterStack(rasterStack map[int64][]*FlexRaster, maskMap map[int64][]bool, scoreMap map[int64][]float32, canvasMap map[string]*FlexRaster, scoreCanvas map[string][]float32) (map[string]*FlexRaster, error) {
 	var keys []int64
 	for k := range rasterStack {
 		keys = append(keys, k)
@@ -207,10 +310,14 @@ func ProcessRasterStack(rasterStack map[int64][]*FlexRaster, maskMap map[int64][
 					Height: r.Height, Width: r.Width, OffX: r.OffX, OffY: r.OffY,
 					Type: r.Type, NameSpace: r.NameSpace}
 			}
-			if mask, ok := maskMap[geoStamp]; ok {
-				err = MergeMaskedRaster(r, canvasMap, mask)
+			mask, ok := maskMap[geoStamp]
+			if !ok {
+				mask = make([]bool, r.Height*r.Width)
+			}
+			if r.Score != nil {
+				err = MergeScoredRaster(r, canvasMap, mask, scoreMap[geoStamp], scoreCanvas)
 			} else {
-				err = MergeMaskedRaster(r, canvasMap, make([]bool, r.Height*r.Width))
+				err = MergeMaskedRaster(r, canvasMap, mask)
 			}
 
 			if err != nil {
@@ -223,110 +330,65 @@ func ProcessRasterStack(rasterStack map[int64][]*FlexRaster, maskMap map[int64][
 	return canvasMap, nil
 }
 
-func ComputeMask(mask *utils.Mask, data []byte, rType string) (out []bool, err error) {
-	if len(mask.Value) == 0 {
-		if len(mask.BitTests) == 0 {
-			err = fmt.Errorf("Please specify either mask.Value or mask.BitTests")
-			return
-		} else if len(mask.BitTests)%2 != 0 {
-			err = fmt.Errorf("The entries in mask.BitTests must be in pairs")
-			return
-		}
+// ComputeMask evaluates the expression of a mask over the
+// rasters of its namespaces for a date and polygon. Pixels
+// where the expression is true are masked out. It returns nil
+// if a mask namespace is missing. The expression is evaluated
+// once per distinct combination of the mask values, which are
+// typically a few quality flags.
+func ComputeMask(mask *utils.Mask, maskRasters map[string]*FlexRaster, size int) ([]bool, error) {
+	if mask.Expr == nil {
+		return nil, fmt.Errorf("mask expression %s hasn't been parsed", mask.Expression)
 	}
 
-	header := *(*reflect.SliceHeader)(unsafe.Pointer(&data))
-
-	switch rType {
-	case "Byte":
-		data := *(*[]uint8)(unsafe.Pointer(&header))
-		out = make([]bool, len(data))
-		if len(mask.Value) > 0 {
-			maskValue64, _ := strconv.ParseUint(mask.Value, 2, 8)
-			maskValue := uint8(maskValue64)
-			for i, val := range data {
-				if (val & maskValue) > 0 {
-					out[i] = true
-				}
-			}
-		} else {
-			for i, val := range data {
-				for j := 0; j < len(mask.BitTests); j += 2 {
-					maskFilter64, _ := strconv.ParseInt(mask.BitTests[j], 2, 8)
-					maskFilter := uint8(maskFilter64)
-
-					maskValue64, _ := strconv.ParseInt(mask.BitTests[j+1], 2, 8)
-					maskValue := uint8(maskValue64)
-
-					if (val & maskFilter) == maskValue {
-						out[i] = true
-						break
-					}
-				}
-			}
+	pixels := make([]utils.Pixels, len(mask.NameSpaces))
+	for i, ns := range mask.NameSpaces {
+		r, found := maskRasters[ns]
+		if !found {
+			return nil, nil
 		}
-	case "Int16":
-		header.Len /= SizeofInt16
-		header.Cap /= SizeofInt16
-		data := *(*[]int16)(unsafe.Pointer(&header))
-		out = make([]bool, len(data))
-		if len(mask.Value) > 0 {
-			maskValue64, _ := strconv.ParseInt(mask.Value, 2, 16)
-			maskValue := int16(maskValue64)
-			for i, val := range data {
-				if (val & maskValue) > 0 {
-					out[i] = true
-				}
-			}
-		} else {
-			for i, val := range data {
-				for j := 0; j < len(mask.BitTests); j += 2 {
-					maskFilter64, _ := strconv.ParseInt(mask.BitTests[j], 2, 16)
-					maskFilter := int16(maskFilter64)
-
-					maskValue64, _ := strconv.ParseInt(mask.BitTests[j+1], 2, 16)
-					maskValue := int16(maskValue64)
-
-					if (val & maskFilter) == maskValue {
-						out[i] = true
-						break
-					}
-				}
-			}
+		p, err := utils.NewPixels(r.Type, r.Data)
+		if err != nil {
+			return nil, fmt.Errorf("mask hasn't been implemented for Raster type %s", r.Type)
 		}
-	case "UInt16":
-		header.Len /= SizeofUint16
-		header.Cap /= SizeofUint16
-		data := *(*[]uint16)(unsafe.Pointer(&header))
-		out = make([]bool, len(data))
-		if len(mask.Value) > 0 {
-			maskValue64, _ := strconv.ParseUint(mask.Value, 2, 16)
-			maskValue := uint16(maskValue64)
-			for i, val := range data {
-				if (val & maskValue) > 0 {
-					out[i] = true
-				}
-			}
-		} else {
-			for i, val := range data {
-				for j := 0; j < len(mask.BitTests); j += 2 {
-					maskFilter64, _ := strconv.ParseInt(mask.BitTests[j], 2, 16)
-					maskFilter := uint16(maskFilter64)
+		if p.Len() != size {
+			return nil, fmt.Errorf("mask namespace %s has %d pixels instead of %d", ns, p.Len(), size)
+		}
+		pixels[i] = p
+	}
 
-					maskValue64, _ := strconv.ParseInt(mask.BitTests[j+1], 2, 16)
-					maskValue := uint16(maskValue64)
+	parameters := make(map[string]interface{}, len(mask.NameSpaces))
+	results := make(map[string]bool)
+	key := make([]byte, 8*len(pixels))
+	out := make([]bool, size)
+	for i := range out {
+		for j, p := range pixels {
+			val := p.Value(i)
+			parameters[mask.NameSpaces[j]] = val
+			binary.LittleEndian.PutUint64(key[8*j:], math.Float64bits(val))
+		}
 
-					if (val & maskFilter) == maskValue {
-						out[i] = true
-						break
-					}
-				}
+		masked, found := results[string(key)]
+		if !found {
+			result, err := mask.Expr.Evaluate(parameters)
+			if err != nil {
+				return nil, fmt.Errorf("mask expression '%v' error: %v", mask.Expression, err)
 			}
+			switch res := result.(type) {
+			case bool:
+				masked = res
+			case float64:
+				masked = res != 0 && !math.IsNaN(res)
+			case float32:
+				masked = res != 0 && !math.IsNaN(float64(res))
+			default:
+				return nil, fmt.Errorf("unknown data type for returned value '%v' for mask expression '%v'", result, mask.Expression)
+			}
+			results[string(key)] = masked
 		}
-	default:
-		err = fmt.Errorf("Type %s cannot contain a bit mask", rType)
-
+		out[i] = masked
 	}
-	return
+	return out, nil
 }
 
 func (enc *RasterMerger) Run(polyLimiter *ConcLimiter, bandExpr *utils.BandExpressions, verbose bool) {
@@ -336,6 +398,8 @@ func (enc *RasterMerger) Run(polyLimiter *ConcLimiter, bandExpr *utils.BandExpre
 	defer close(enc.Out)
 
 	canvasMap := map[string]*FlexRaster{}
+	stackMap := map[string]*CompositeStack{}
+	scoreCanvas := map[string][]float32{}
 	for inRasters := range enc.In {
 		select {
 		case <-enc.Context.Done():
@@ -346,6 +410,9 @@ func (enc *RasterMerger) Run(polyLimiter *ConcLimiter, bandExpr *utils.BandExpre
 
 		maskMap := map[int64][]bool{}
 		rasterStack := map[int64][]*FlexRaster{}
+		compositeStack := map[int64][]*FlexRaster{}
+		auxMap := map[int64]map[string]*FlexRaster{}
+		maskAux := map[int64]map[string]*FlexRaster{}
 
 		for _, r := range inRasters {
 			if r == nil {
@@ -356,25 +423,67 @@ func (enc *RasterMerger) Run(polyLimiter *ConcLimiter, bandExpr *utils.BandExpre
 			h.Write([]byte(r.Polygon))
 			geoStamp := r.TimeStamp.UnixNano() + int64(h.Sum32())
 
-			// Raster namespace is identified as Mask
-			if r.Mask != nil && r.Mask.ID == r.NameSpace {
-				mask, err := ComputeMask(r.Mask, r.Data, r.Type)
-				if err != nil {
-					enc.sendError(err)
-					return
+			// Raster namespace is read by the Mask
+			if r.Mask != nil && r.Mask.IsNameSpace(r.NameSpace) {
+				if _, ok := maskAux[geoStamp]; !ok {
+					maskAux[geoStamp] = map[string]*FlexRaster{}
 				}
-				maskMap[geoStamp] = mask
+				maskAux[geoStamp][r.NameSpace] = r
 				if !r.Mask.Inclusive {
 					continue
 				}
 
 			}
 
+			// Raster namespace is an auxiliary band of the score
+			if r.Score != nil && !isComposite(r) && isScoreNameSpace(r) {
+				if _, ok := auxMap[geoStamp]; !ok {
+					auxMap[geoStamp] = map[string]*FlexRaster{}
+				}
+				auxMap[geoStamp][r.NameSpace] = r
+				if !isBandNameSpace(r) {
+					continue
+				}
+			}
+
+			if isComposite(r) {
+				compositeStack[geoStamp] = append(compositeStack[geoStamp], r)
+				continue
+			}
 			rasterStack[geoStamp] = append(rasterStack[geoStamp], r)
 		}
 
+		for geoStamp, maskRasters := range maskAux {
+			var r *FlexRaster
+			for _, r = range maskRasters {
+				break
+			}
+			mask, err := ComputeMask(r.Mask, maskRasters, r.Height*r.Width)
+			if err != nil {
+				enc.sendError(err)
+				return
+			}
+			if mask != nil {
+				maskMap[geoStamp] = mask
+			}
+		}
+
+		scoreMap := map[int64][]float32{}
+		for geoStamp, rasters := range rasterStack {
+			r := rasters[0]
+			if r.Score == nil {
+				continue
+			}
+			score, err := ComputeScore(r.Score, auxMap[geoStamp], r.TimeStamp, r.TargetDate, r.Height*r.Width)
+			if err != nil {
+				enc.sendError(err)
+				return
+			}
+			scoreMap[geoStamp] = score
+		}
+
 		if len(rasterStack) > 0 {
-			tmpMap, err := ProcessRasterStack(rasterStack, maskMap, canvasMap)
+			tmpMap, err := ProcessRasterStack(rasterStack, maskMap, scoreMap, canvasMap, scoreCanvas)
 			if err != nil {
 				enc.sendError(err)
 				return
@@ -382,6 +491,15 @@ func (enc *RasterMerger) Run(polyLimiter *ConcLimiter, bandExpr *utils.BandExpre
 			canvasMap = tmpMap
 		}
 
+		if len(compositeStack) > 0 {
+			tmpMap, err := ProcessCompositeStack(compositeStack, maskMap, stackMap)
+			if err != nil {
+				enc.sendError(err)
+				return
+			}
+			stackMap = tmpMap
+		}
+
 		polyLimiter.Decrease()
 	}
 
@@ -391,6 +509,11 @@ func (enc *RasterMerger) Run(polyLimiter *ConcLimiter, bandExpr *utils.BandExpre
 	default:
 	}
 
+	for ns, stack := range stackMap {
+		canvasMap[ns] = stack.Reduce(ns)
+		delete(stackMap, ns)
+	}
+
 	var nameSpaces []string
 	for _, canvas := range canvasMap {
 		nameSpaces = canvas.ConfigPayLoad.NameSpaces
@@ -475,8 +598,24 @@ func (enc *RasterMerger) Run(polyLimiter *ConcLimiter, bandExpr *utils.BandExpre
 			}
 
 		default:
-			enc.sendError(fmt.Errorf("raster type %s not recognised", canvas.Type))
-			return
+			pixels, err := utils.NewPixels(canvas.Type, canvas.Data)
+			if err != nil {
+				enc.sendError(fmt.Errorf("raster type %s not recognised", canvas.Type))
+				return
+			}
+			if !hasExpr {
+				out[i] = &utils.TypedRaster{NoData: canvas.NoData, Data: canvas.Data, Type: canvas.Type,
+					Width: canvas.Width, Height: canvas.Height, NameSpace: ns}
+			} else {
+				// The nodata value is rounded to float32 like the
+				// pixels for the nodata masks of the expressions
+				noData := float32(utils.CastValue(canvas.Type, canvas.NoData))
+				varData := make([]float32, pixels.Len())
+				for i := range varData {
+					varData[i] = float32(pixels.Value(i))
+				}
+				bandVars[i] = &utils.Float32Raster{NoData: float64(noData), Data: varData}
+			}
 		}
 	}
 
@@ -487,8 +626,10 @@ func (enc *RasterMerger) Run(polyLimiter *ConcLimiter, bandExpr *utils.BandExpre
 		height := canvasMap[nameSpaces[0]].Height
 		noData := bandVars[0].NoData
 		noDataMasks := make([]bool, width*height)
+		allValid := make([]bool, width*height)
 		for i := 0; i < len(noDataMasks); i++ {
 			noDataMasks[i] = true
+			allValid[i] = true
 		}
 
 		for i, ns := range nameSpaces {
@@ -501,21 +642,49 @@ func (enc *RasterMerger) Run(polyLimiter *ConcLimiter, bandExpr *utils.BandExpre
 			}
 		}
 
+		// Expressions calling isnodata see nodata as NaN
+		// and decide the nodata pixels of their output
+		var nanParameters map[string]interface{}
+
 		for iv := range bandExpr.Expressions {
-			result, err := bandExpr.Expressions[iv].Evaluate(parameters)
+			exprParameters := parameters
+			validMasks := noDataMasks
+			if iv < len(bandExpr.HandlesNoData) && bandExpr.HandlesNoData[iv] {
+				if nanParameters == nil {
+					nanParameters = make(map[string]interface{}, len(bandVars))
+					for i, ns := range nameSpaces {
+						nanData := make([]float32, len(bandVars[i].Data))
+						for j, val := range bandVars[i].Data {
+							if float64(val) == bandVars[i].NoData {
+								nanData[j] = float32(math.NaN())
+							} else {
+								nanData[j] = val
+							}
+						}
+						nanParameters[ns] = nanData
+					}
+				}
+				exprParameters = nanParameters
+				validMasks = allValid
+			}
+
+			result, err := bandExpr.Expressions[iv].Evaluate(exprParameters)
 			if err != nil {
 				enc.sendError(fmt.Errorf("bandExpr '%v' error: %v", bandExpr.ExprText[iv], err))
 				return
 			}
 
-			outRaster := &utils.Float32Raster{NoData: noData, Data: make([]float32, len(noDataMasks)),
+			outRaster := &utils.Float32Raster{NoData: noData, Data: make([]float32, len(validMasks)),
 				Width: width, Height: height, NameSpace: bandExpr.ExprNames[iv]}
 			out[iv] = outRaster
 
 			resScal, isScal := result.(float32)
 			if isScal {
+				if math.IsInf(float64(resScal), 0) || math.IsNaN(float64(resScal)) {
+					resScal = float32(noData)
+				}
 				for i := range outRaster.Data {
-					if noDataMasks[i] {
+					if validMasks[i] {
 						outRaster.Data[i] = resScal
 					} else {
 						outRaster.Data[i] = float32(noData)
@@ -527,7 +696,7 @@ func (enc *RasterMerger) Run(polyLimiter *ConcLimiter, bandExpr *utils.BandExpre
 			resArr, isArr := result.([]float32)
 			if isArr {
 				for i := range outRaster.Data {
-					if noDataMasks[i] {
+					if validMasks[i] {
 						if math.IsInf(float64(resArr[i]), 0) || math.IsNaN(float64(resArr[i])) {
 							outRaster.Data[i] = float32(noData)
 						} else {
@@ -555,3 +724,21 @@ func (enc *RasterMerger) sendError(err error) {
 	default:
 	}
 }
+
+func isScoreNameSpace(r *FlexRaster) bool {
+	for _, ns := range r.Score.NameSpaces {
+		if ns == r.NameSpace {
+			return true
+		}
+	}
+	return false
+}
+
+func isBandNameSpace(r *FlexRaster) bool {
+	for _, ns := range r.NameSpaces {
+		if ns == r.NameSpace {
+			return true
+		}
+	}
+	return false
+}
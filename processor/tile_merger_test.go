@@ -0,0 +1,198 @@
+package processor
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/nci/gsky/utils"
+)
+
+func TestProcessRasterStackScore(t *testing.T) {
+	score := &utils.QualityScore{Expression: "clear"}
+	if err := utils.ParseQualityScore(score); err != nil {
+		t.Fatalf("failed to parse score: %v", err)
+	}
+
+	newRaster := func(ns string, day int, values ...uint16) *FlexRaster {
+		data := make([]byte, SizeofUint16*len(values))
+		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&data))
+		headr.Len /= SizeofUint16
+		headr.Cap /= SizeofUint16
+		copy(*(*[]uint16)(unsafe.Pointer(&headr)), values)
+		return &FlexRaster{ConfigPayLoad: ConfigPayLoad{NameSpaces: []string{"red"}, Score: score},
+			Data: data, Height: 1, Width: len(values), Type: "UInt16", NoData: 0, NameSpace: ns,
+			TimeStamp: time.Date(2018, time.January, day, 0, 0, 0, 0, time.UTC), Polygon: "a"}
+	}
+
+	// The last date has no auxiliary band and only fills
+	// the pixels without a scored observation
+	rasters := [][]*FlexRaster{
+		{newRaster("red", 1, 10, 11, 12, 0), newRaster("clear", 1, 90, 20, 0, 0)},
+		{newRaster("red", 2, 20, 21, 22, 0), newRaster("clear", 2, 50, 80, 0, 0)},
+		{newRaster("red", 3, 30, 31, 32, 33)},
+	}
+
+	rasterStack := map[int64][]*FlexRaster{}
+	scoreMap := map[int64][]float32{}
+	for _, rs := range rasters {
+		aux := map[string]*FlexRaster{}
+		for _, r := range rs {
+			aux[r.NameSpace] = r
+		}
+		geoStamp := rs[0].TimeStamp.UnixNano()
+		s, err := ComputeScore(score, aux, rs[0].TimeStamp, rs[0].TimeStamp, 4)
+		if err != nil {
+			t.Fatalf("failed to compute score: %v", err)
+		}
+		scoreMap[geoStamp] = s
+		rasterStack[geoStamp] = []*FlexRaster{rs[0]}
+	}
+	if scoreMap[rasters[2][0].TimeStamp.UnixNano()] != nil {
+		t.Errorf("expected no score without the auxiliary band")
+	}
+
+	canvasMap, err := ProcessRasterStack(rasterStack, map[int64][]bool{}, scoreMap, map[string]*FlexRaster{}, map[string][]float32{})
+	if err != nil {
+		t.Fatalf("failed to merge rasters: %v", err)
+	}
+
+	canvas := canvasMap["red"]
+	headr := *(*reflect.SliceHeader)(unsafe.Pointer(&canvas.Data))
+	headr.Len /= SizeofUint16
+	headr.Cap /= SizeofUint16
+	data := *(*[]uint16)(unsafe.Pointer(&headr))
+	if expected := []uint16{10, 21, 32, 33}; !reflect.DeepEqual(data, expected) {
+		t.Errorf("expected %v, got %v", expected, data)
+	}
+}
+
+func TestMergeMaskedRasterTypes(t *testing.T) {
+	for _, rType := range []string{"Int8", "Int32", "UInt32", "Float64"} {
+		newRaster := func(day int, values ...float64) *FlexRaster {
+			size, _ := utils.RasterTypeSize(rType)
+			data := make([]byte, size*len(values))
+			pixels, _ := utils.NewPixels(rType, data)
+			for i, val := range values {
+				pixels.SetValue(i, val)
+			}
+			return &FlexRaster{Data: data, Height: 1, Width: len(values), Type: rType, NoData: 1,
+				NameSpace: "tas", TimeStamp: time.Date(2018, time.January, day, 0, 0, 0, 0, time.UTC)}
+		}
+
+		rasterStack := map[int64][]*FlexRaster{
+			1: {newRaster(1, 10, 11, 12)},
+			2: {newRaster(2, 20, 1, 22)},
+		}
+		maskMap := map[int64][]bool{2: {false, false, true}}
+
+		canvasMap, err := ProcessRasterStack(rasterStack, maskMap, map[int64][]float32{}, map[string]*FlexRaster{}, map[string][]float32{})
+		if err != nil {
+			t.Fatalf("%s: failed to merge rasters: %v", rType, err)
+		}
+
+		pixels, _ := utils.NewPixels(rType, canvasMap["tas"].Data)
+		for i, expected := range []float64{20, 11, 12} {
+			if pixels.Value(i) != expected {
+				t.Errorf("%s: pixel %d: expected %v, got %v", rType, i, expected, pixels.Value(i))
+			}
+		}
+	}
+}
+
+func TestComputeMask(t *testing.T) {
+	newRaster := func(ns string, rType string, values ...float64) *FlexRaster {
+		size, _ := utils.RasterTypeSize(rType)
+		data := make([]byte, size*len(values))
+		pixels, _ := utils.NewPixels(rType, data)
+		for i, val := range values {
+			pixels.SetValue(i, val)
+		}
+		return &FlexRaster{Data: data, Height: 1, Width: len(values), Type: rType, NameSpace: ns}
+	}
+
+	tests := []struct {
+		mask     utils.Mask
+		rasters  []*FlexRaster
+		expected []bool
+	}{
+		{
+			utils.Mask{ID: "qa", Value: "1100"},
+			[]*FlexRaster{newRaster("qa", "UInt16", 0, 4, 8, 3)},
+			[]bool{false, true, true, false},
+		},
+		{
+			utils.Mask{ID: "qa", BitTests: []string{"11", "01"}},
+			[]*FlexRaster{newRaster("qa", "UInt32", 1, 5, 3, 1<<31|1)},
+			[]bool{true, true, false, true},
+		},
+		{
+			utils.Mask{Expression: "cloud_prob > 50 || qa < 0"},
+			[]*FlexRaster{newRaster("cloud_prob", "Float32", 10, 80, 10, 20), newRaster("qa", "Int8", 0, 0, -1, 0)},
+			[]bool{false, true, true, false},
+		},
+	}
+
+	for _, test := range tests {
+		mask := test.mask
+		if err := utils.ParseMask(&mask); err != nil {
+			t.Fatalf("failed to parse mask: %v", err)
+		}
+		maskRasters := map[string]*FlexRaster{}
+		for _, r := range test.rasters {
+			maskRasters[r.NameSpace] = r
+		}
+
+		out, err := ComputeMask(&mask, maskRasters, 4)
+		if err != nil {
+			t.Fatalf("failed to compute mask %s: %v", mask.Expression, err)
+		}
+		if !reflect.DeepEqual(out, test.expected) {
+			t.Errorf("mask %s: expected %v, got %v", mask.Expression, test.expected, out)
+		}
+	}
+
+	// A missing namespace leaves the pixels unmasked
+	mask := utils.Mask{Expression: "cloud_prob > 50 || qa < 0"}
+	utils.ParseMask(&mask)
+	out, err := ComputeMask(&mask, map[string]*FlexRaster{"qa": newRaster("qa", "Int8", -1, 0, 0, 0)}, 4)
+	if err != nil || out != nil {
+		t.Errorf("expected no mask without cloud_prob, got %v, %v", out, err)
+	}
+}
+
+func TestRasterMergerNoDataExpressions(t *testing.T) {
+	bandExpr, err := utils.ParseBandExpressions([]string{"filled = where(isnodata(a), 0, a)", "root = sqrt(a)"})
+	if err != nil {
+		t.Fatalf("failed to parse expressions: %v", err)
+	}
+
+	data := make([]byte, SizeofFloat32*3)
+	pixels, _ := utils.NewPixels("Float32", data)
+	for i, val := range []float64{4, -1, 9} {
+		pixels.SetValue(i, val)
+	}
+
+	merger := NewRasterMerger(context.Background(), make(chan error, 1))
+	merger.In <- []*FlexRaster{{ConfigPayLoad: ConfigPayLoad{NameSpaces: []string{"a"}}, Data: data,
+		Height: 1, Width: 3, Type: "Float32", NoData: -1, NameSpace: "a"}}
+	close(merger.In)
+	go merger.Run(NewConcLimiter(1), bandExpr, false)
+
+	var out []utils.Raster
+	select {
+	case out = <-merger.Out:
+	case err := <-merger.Error:
+		t.Fatalf("failed to merge rasters: %v", err)
+	}
+
+	expected := [][]float32{{4, 0, 9}, {2, -1, 3}}
+	for i, raster := range out {
+		res := raster.(*utils.Float32Raster).Data
+		if !reflect.DeepEqual(res, expected[i]) {
+			t.Errorf("%s: expected %v, got %v", bandExpr.ExprNames[i], expected[i], res)
+		}
+	}
+}
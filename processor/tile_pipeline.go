@@ -47,6 +47,13 @@ func InitTilePipeline(ctx context.Context, masAddr string, rpcAddr []string, max
 }
 
 func (dp *TilePipeline) Process(geoReq *GeoTileRequest, verbose bool) chan []utils.Raster {
+	// Terrain tiles are read with a one pixel halo so that
+	// the gradients at their edges match their neighbours
+	tileReq := geoReq
+	if geoReq.Terrain != nil {
+		geoReq = haloRequest(geoReq)
+	}
+
 	grpcTiler := NewRasterGRPC(dp.Context, dp.RPCAddress, dp.MaxGrpcRecvMsgSize, dp.PolygonShardConcLimit, dp.MaxGrpcBufferSize, dp.Error)
 	i := NewTileIndexer(dp.Context, dp.MASAddress, dp.Error)
 	go func() {
@@ -67,6 +74,13 @@ func (dp *TilePipeline) Process(geoReq *GeoTileRequest, verbose bool) chan []uti
 	go i.Run(verbose)
 	go grpcTiler.Run(polyLimiter, geoReq.BandExpr.VarList, verbose)
 	go m.Run(polyLimiter, geoReq.BandExpr, verbose)
+
+	if tileReq.Terrain != nil {
+		ts := NewTerrainShader(dp.Context, dp.Error)
+		ts.In = m.Out
+		go ts.Run(tileReq, verbose)
+		return ts.Out
+	}
 //fmt.Println("------------------")
 //fmt.Println(m.Out)
 	return m.Out
@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"context"
+	"log"
+
+	"github.com/nci/gsky/utils"
+)
+
+// TerrainShader renders the terrain of the elevation tiles
+// merged with a one pixel halo and crops the halo off
+type TerrainShader struct {
+	Context context.Context
+	In      chan []utils.Raster
+	Out     chan []utils.Raster
+	Error   chan error
+}
+
+func NewTerrainShader(ctx context.Context, errChan chan error) *TerrainShader {
+	return &TerrainShader{
+		Context: ctx,
+		In:      make(chan []utils.Raster, 100),
+		Out:     make(chan []utils.Raster, 100),
+		Error:   errChan,
+	}
+}
+
+// haloRequest returns a copy of a tile request grown by one
+// pixel on each side
+func haloRequest(geoReq *GeoTileRequest) *GeoTileRequest {
+	xRes := (geoReq.BBox[2] - geoReq.BBox[0]) / float64(geoReq.Width)
+	yRes := (geoReq.BBox[3] - geoReq.BBox[1]) / float64(geoReq.Height)
+
+	haloReq := *geoReq
+	haloReq.BBox = []float64{geoReq.BBox[0] - xRes, geoReq.BBox[1] - yRes, geoReq.BBox[2] + xRes, geoReq.BBox[3] + yRes}
+	haloReq.Width = geoReq.Width + 2
+	haloReq.Height = geoReq.Height + 2
+	return &haloReq
+}
+
+// Run shades the tiles of a request, whose bbox and size are
+// those of the tile without the halo. Empty tiles are
+// cropped and rasters of other sizes passed through.
+func (ts *TerrainShader) Run(geoReq *GeoTileRequest, verbose bool) {
+	if verbose {
+		defer log.Printf("terrain shader done")
+	}
+	defer close(ts.Out)
+
+	for rasters := range ts.In {
+		select {
+		case <-ts.Context.Done():
+			return
+		default:
+		}
+
+		if len(rasters) == 0 {
+			ts.Out <- rasters
+			continue
+		}
+		nameSpace, height, width := utils.RasterInfo(rasters[0])
+		if width != geoReq.Width+2 || height != geoReq.Height+2 {
+			ts.Out <- rasters
+			continue
+		}
+		if nameSpace == "EmptyTile" {
+			ts.Out <- []utils.Raster{&utils.ByteRaster{NameSpace: nameSpace, NoData: rasters[0].GetNoData(),
+				Data: make([]uint8, geoReq.Width*geoReq.Height), Width: geoReq.Width, Height: geoReq.Height}}
+			continue
+		}
+
+		out, err := utils.ComputeTerrain(rasters[0], geoReq.Terrain, geoReq.CRS, geoReq.BBox)
+		if err != nil {
+			ts.Error <- err
+			return
+		}
+		ts.Out <- out
+	}
+}
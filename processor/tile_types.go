@@ -7,9 +7,10 @@ import (
 )
 
 type ScaleParams struct {
-	Offset float64
-	Scale  float64
-	Clip   float64
+	Offset  float64
+	Scale   float64
+	Clip    float64
+	Stretch *utils.Stretch
 }
 
 type ConfigPayLoad struct {
@@ -23,6 +24,12 @@ type ConfigPayLoad struct {
 	GrpcConcLimit         int
 	PolygonSharcConcLimit int
 	QueryLimit            int
+	Resampling            string
+	Composite             string
+	Score                 *utils.QualityScore
+	TargetDate            time.Time
+	Cutline               string
+	Terrain               *utils.Terrain
 }
 
 type GeoTileRequest struct {
@@ -38,16 +45,19 @@ type GeoTileRequest struct {
 
 type GeoTileGranule struct {
 	ConfigPayLoad
-	Path          string
-	CRS           string
-	BBox          []float64
-	Height, Width int
-	OffX, OffY    int
-	NameSpace     string
-	TimeStamps    []time.Time
-	TimeStamp     time.Time
-	Polygon       string
-	RasterType    string
+	Path           string
+	CRS            string
+	BBox           []float64
+	Height, Width  int
+	OffX, OffY     int
+	NameSpace      string
+	TimeStamps     []time.Time
+	TimeStamp      time.Time
+	Polygon        string
+	RasterType     string
+	GeoTransform   []float64
+	ProjWKT        string
+	OverviewFactor float64
 }
 
 type FlexRaster struct {
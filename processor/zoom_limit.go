@@ -0,0 +1,42 @@
+package processor
+
+import (
+	"context"
+
+	"github.com/nci/gsky/utils"
+)
+
+// ZoomedOut tells whether a request is too coarse to be served
+// and, if so, whether it intersects any data. Requests coarser
+// than the zoom limit of their layer are still served from the
+// cache level of the style matching their resolution, or from
+// the overviews of the granules if they are coarse enough. The
+// overviews are those of the first granule of the request.
+func ZoomedOut(ctx context.Context, masAddress string, styleLayer *utils.Layer, zoomLimit float64, geoReq *GeoTileRequest, reqRes float64, verbose bool) (bool, bool) {
+	if zoomLimit == 0.0 || reqRes <= zoomLimit || styleLayer.CacheLevel(reqRes) != nil {
+		return false, true
+	}
+
+	errChan := make(chan error, 100)
+	indexer := NewTileIndexer(ctx, masAddress, errChan)
+	go func() {
+		zoomReq := *geoReq
+		zoomReq.Mask = nil
+		zoomReq.QueryLimit = 1
+		indexer.In <- &zoomReq
+		close(indexer.In)
+	}()
+
+	go indexer.Run(verbose)
+
+	hasData := false
+	hasOverviews := false
+	for geo := range indexer.Out {
+		if geo.NameSpace != "EmptyTile" && !hasData {
+			hasData = true
+			hasOverviews = reqRes <= zoomLimit*geo.OverviewFactor
+		}
+	}
+
+	return !hasOverviews, hasData
+}
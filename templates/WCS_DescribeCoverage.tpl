@@ -68,6 +68,9 @@
     </supportedFormats>
     <supportedInterpolations>
       <interpolationMethod>none</interpolationMethod>
+      <interpolationMethod>nearest neighbor</interpolationMethod>
+      <interpolationMethod>bilinear</interpolationMethod>
+      <interpolationMethod>bicubic</interpolationMethod>
     </supportedInterpolations>
   </CoverageOffering>
 </CoverageDescription>
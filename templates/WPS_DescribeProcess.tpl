@@ -59,6 +59,17 @@
 						<MimeType>application/vnd.terriajs.catalog-member+json</MimeType>
 						<Schema>https://tools.ietf.org/html/rfc7159</Schema>
 					</Format>
+					<Format>
+						<MimeType>text/csv</MimeType>
+					</Format>
+					<Format>
+						<MimeType>application/json</MimeType>
+						<Schema>https://tools.ietf.org/html/rfc7159</Schema>
+					</Format>
+					<Format>
+						<MimeType>application/x-netcdf</MimeType>
+						<Encoding>base64</Encoding>
+					</Format>
 				</Supported>
 			</ComplexOutput>
 		</Output>
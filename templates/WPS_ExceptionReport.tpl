@@ -0,0 +1,6 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<ows:ExceptionReport xmlns:ows="http://www.opengis.net/ows/1.1" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.opengis.net/ows/1.1 http://schemas.opengis.net/ows/1.1.0/owsExceptionReport.xsd" version="1.0.0" xml:lang="en-US">
+<ows:Exception exceptionCode="{{ .Code }}"{{ if .Locator }} locator="{{ .Locator | html }}"{{ end }}>
+<ows:ExceptionText>{{ .Text | html }}</ows:ExceptionText>
+</ows:Exception>
+</ows:ExceptionReport>
@@ -1,14 +1,23 @@
-<wps:ExecuteResponse xmlns:ows="http://www.opengis.net/ows/1.1" xmlns:wps="http://www.opengis.net/wps/1.0.0" xmlns:xlink="http://www.w3.org/1999/xlink" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.opengis.net/wps/1.0.0 http://schemas.opengis.net/wps/1.0.0/wpsExecute_response.xsd" service="WPS" version="1.0.0" xml:lang="en-US" serviceInstance="http://gsky.nci.org.au/ows" statusLocation="http://gsky.nci.org.au/ows?to_be_implemented">
+<wps:ExecuteResponse xmlns:ows="http://www.opengis.net/ows/1.1" xmlns:wps="http://www.opengis.net/wps/1.0.0" xmlns:xlink="http://www.w3.org/1999/xlink" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:schemaLocation="http://www.opengis.net/wps/1.0.0 http://schemas.opengis.net/wps/1.0.0/wpsExecute_response.xsd" service="WPS" version="1.0.0" xml:lang="en-US" serviceInstance="http://gsky.nci.org.au/ows"{{ if .StatusLocation }} statusLocation="{{ .StatusLocation | html }}"{{ end }}>
 <wps:Process wps:processVersion="1.0.0">
 <ows:Identifier>timeSeries</ows:Identifier>
 <ows:Identifier>geometryDrill</ows:Identifier>
 <ows:Title>Geometry Drill</ows:Title>
 <ows:Abstract>Extract a time series from a dataset.</ows:Abstract>
 </wps:Process>
-<wps:Status creationTime="2000-01-01T00:00:00Z">
-<wps:ProcessSucceeded>The service "timeSeries" ran successfully.</wps:ProcessSucceeded>
-</wps:Status>
-<wps:ProcessOutputs>
-{{ . }}
+<wps:Status creationTime="{{ .CreationTime }}">
+{{ if eq .Status "ProcessAccepted" }}<wps:ProcessAccepted>{{ .Message | html }}</wps:ProcessAccepted>
+{{ else if eq .Status "ProcessStarted" }}<wps:ProcessStarted percentCompleted="{{ .PercentComplete }}">{{ .Message | html }}</wps:ProcessStarted>
+{{ else if eq .Status "ProcessFailed" }}<wps:ProcessFailed>
+<ows:ExceptionReport version="1.0.0">
+<ows:Exception exceptionCode="NoApplicableCode">
+<ows:ExceptionText>{{ .Message | html }}</ows:ExceptionText>
+</ows:Exception>
+</ows:ExceptionReport>
+</wps:ProcessFailed>
+{{ else }}<wps:ProcessSucceeded>{{ .Message | html }}</wps:ProcessSucceeded>
+{{ end }}</wps:Status>
+{{ if .Outputs }}<wps:ProcessOutputs>
+{{ .Outputs }}
 </wps:ProcessOutputs>
-</wps:ExecuteResponse>
+{{ end }}</wps:ExecuteResponse>
@@ -0,0 +1,10 @@
+<wps:Output>
+<ows:Identifier>class_histogram</ows:Identifier>
+<ows:Title>Class Histogram</ows:Title>
+<ows:Abstract>Pixel count and area in square kilometres of each class within the polygon.</ows:Abstract>
+<wps:Data>
+<wps:ComplexData mimeType="application/vnd.terriajs.catalog-member+json" schema="https://tools.ietf.org/html/rfc7159">
+<![CDATA[{ "data": "{{ .Header }}\n{{ .Rows }}", "isEnabled": true, "type": "csv", "name": "Class Histogram%s" }]]>
+</wps:ComplexData>
+</wps:Data>
+</wps:Output>
@@ -0,0 +1,10 @@
+<wps:Output>
+<ows:Identifier>profile</ows:Identifier>
+<ows:Title>Line Profile</ows:Title>
+<ows:Abstract>Values sampled along the line with the distance in metres from its start and the coordinates of every sample.</ows:Abstract>
+<wps:Data>
+<wps:ComplexData mimeType="application/vnd.terriajs.catalog-member+json" schema="https://tools.ietf.org/html/rfc7159">
+<![CDATA[{ "data": "{{ .Header }}\n{{ .Rows }}", "isEnabled": true, "type": "csv", "name": "Profile%s" }]]>
+</wps:ComplexData>
+</wps:Data>
+</wps:Output>
@@ -0,0 +1,197 @@
+package utils
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"time"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Animation formats of WMS GetMap requests over a time range
+const (
+	AnimationGIF  = "image/gif"
+	AnimationAPNG = "image/apng"
+)
+
+// AnimationFrameDelay is the display time of each frame
+const AnimationFrameDelay = 500 * time.Millisecond
+
+var pngSignature = []byte{0x89, 'P', 'N', 'G', '\r', '\n', 0x1a, '\n'}
+
+// EncodeAnimation encodes frames of the same size as a looping
+// GIF or APNG animation. Each frame is labelled with its
+// label, such as the date of the frame, in its top left corner.
+func EncodeAnimation(frames []image.Image, labels []string, format string, delay time.Duration) ([]byte, error) {
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("animation has no frames")
+	}
+	if len(labels) != len(frames) {
+		return nil, fmt.Errorf("animation has %d frames but %d labels", len(frames), len(labels))
+	}
+
+	bounds := frames[0].Bounds()
+	labelled := make([]*image.NRGBA, len(frames))
+	for i, frame := range frames {
+		if frame.Bounds().Size() != bounds.Size() {
+			return nil, fmt.Errorf("animation frame %d is %v, expecting %v", i, frame.Bounds().Size(), bounds.Size())
+		}
+		img := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+		draw.Draw(img, img.Bounds(), frame, frame.Bounds().Min, draw.Src)
+		drawFrameLabel(img, labels[i])
+		labelled[i] = img
+	}
+
+	switch format {
+	case AnimationGIF:
+		return encodeGIF(labelled, delay)
+	case AnimationAPNG:
+		return encodeAPNG(labelled, delay)
+	default:
+		return nil, fmt.Errorf("unsupported animation format: %s", format)
+	}
+}
+
+// drawFrameLabel writes a label in black over a white box
+func drawFrameLabel(img *image.NRGBA, label string) {
+	if len(label) == 0 {
+		return
+	}
+	face := basicfont.Face7x13
+	drawer := &font.Drawer{Dst: img, Src: image.Black, Face: face}
+	width := drawer.MeasureString(label).Ceil()
+	draw.Draw(img, image.Rect(0, 0, width+8, face.Height+4), image.White, image.ZP, draw.Src)
+	drawer.Dot = fixed.P(4, face.Ascent+2)
+	drawer.DrawString(label)
+}
+
+// encodeGIF encodes the frames with their own colours if they
+// fit a GIF palette, which is the case of the frames rendered
+// with a palette. Other frames are dithered to the web safe
+// palette.
+func encodeGIF(frames []*image.NRGBA, delay time.Duration) ([]byte, error) {
+	plt := color.Palette{color.Transparent}
+	index := map[color.NRGBA]bool{}
+	for _, frame := range frames {
+		for i := 0; i < len(frame.Pix) && len(plt) <= 256; i += 4 {
+			c := color.NRGBA{frame.Pix[i], frame.Pix[i+1], frame.Pix[i+2], frame.Pix[i+3]}
+			if c.A == 0 || index[c] {
+				continue
+			}
+			index[c] = true
+			plt = append(plt, c)
+		}
+	}
+	drawer := draw.Drawer(draw.Src)
+	if len(plt) > 256 {
+		plt = append(color.Palette{color.Transparent}, palette.WebSafe...)
+		drawer = draw.FloydSteinberg
+	}
+
+	anim := &gif.GIF{}
+	for _, frame := range frames {
+		paletted := image.NewPaletted(frame.Bounds(), plt)
+		drawer.Draw(paletted, paletted.Bounds(), frame, image.ZP)
+		anim.Image = append(anim.Image, paletted)
+		anim.Delay = append(anim.Delay, int(delay/(10*time.Millisecond)))
+		anim.Disposal = append(anim.Disposal, gif.DisposalBackground)
+	}
+
+	buf := new(bytes.Buffer)
+	err := gif.EncodeAll(buf, anim)
+	return buf.Bytes(), err
+}
+
+// encodeAPNG encodes the frames as 8 bit RGBA images. The first
+// frame is the default image shown by viewers without APNG
+// support.
+func encodeAPNG(frames []*image.NRGBA, delay time.Duration) ([]byte, error) {
+	width := frames[0].Bounds().Dx()
+	height := frames[0].Bounds().Dy()
+
+	buf := new(bytes.Buffer)
+	buf.Write(pngSignature)
+
+	header := make([]byte, 13)
+	binary.BigEndian.PutUint32(header[0:], uint32(width))
+	binary.BigEndian.PutUint32(header[4:], uint32(height))
+	header[8] = 8 // bit depth
+	header[9] = 6 // RGBA
+	writePNGChunk(buf, "IHDR", header)
+
+	control := make([]byte, 8)
+	binary.BigEndian.PutUint32(control[0:], uint32(len(frames)))
+	writePNGChunk(buf, "acTL", control)
+
+	seq := uint32(0)
+	for i, frame := range frames {
+		frameControl := make([]byte, 26)
+		binary.BigEndian.PutUint32(frameControl[0:], seq)
+		binary.BigEndian.PutUint32(frameControl[4:], uint32(width))
+		binary.BigEndian.PutUint32(frameControl[8:], uint32(height))
+		binary.BigEndian.PutUint16(frameControl[20:], uint16(delay/time.Millisecond))
+		binary.BigEndian.PutUint16(frameControl[22:], 1000)
+		frameControl[24] = 1 // dispose to background
+		writePNGChunk(buf, "fcTL", frameControl)
+		seq++
+
+		data, err := pngImageData(frame)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			writePNGChunk(buf, "IDAT", data)
+			continue
+		}
+		frameData := make([]byte, 4+len(data))
+		binary.BigEndian.PutUint32(frameData, seq)
+		copy(frameData[4:], data)
+		writePNGChunk(buf, "fdAT", frameData)
+		seq++
+	}
+
+	writePNGChunk(buf, "IEND", nil)
+	return buf.Bytes(), nil
+}
+
+// pngImageData compresses the rows of an image, each starting
+// with the PNG filter type 0
+func pngImageData(img *image.NRGBA) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	zw := zlib.NewWriter(buf)
+	rowSize := 4 * img.Bounds().Dx()
+	for y := 0; y < img.Bounds().Dy(); y++ {
+		row := img.Pix[y*img.Stride : y*img.Stride+rowSize]
+		if _, err := zw.Write(append([]byte{0}, row...)); err != nil {
+			return nil, err
+		}
+	}
+	err := zw.Close()
+	return buf.Bytes(), err
+}
+
+func writePNGChunk(buf *bytes.Buffer, chunkType string, data []byte) {
+	length := make([]byte, 4)
+	binary.BigEndian.PutUint32(length, uint32(len(data)))
+	buf.Write(length)
+
+	crc := crc32.NewIEEE()
+	crc.Write([]byte(chunkType))
+	crc.Write(data)
+	buf.WriteString(chunkType)
+	buf.Write(data)
+
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc.Sum32())
+	buf.Write(sum)
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"testing"
+)
+
+func animationFrames() ([]image.Image, []string) {
+	var frames []image.Image
+	for _, c := range []color.RGBA{{0xFF, 0, 0, 0xFF}, {0, 0, 0xFF, 0xFF}} {
+		img := image.NewRGBA(image.Rect(0, 0, 40, 40))
+		for y := 30; y < 40; y++ {
+			for x := 0; x < 40; x++ {
+				img.SetRGBA(x, y, c)
+			}
+		}
+		frames = append(frames, img)
+	}
+	return frames, []string{"2018-01-01", "2018-01-02"}
+}
+
+func TestEncodeAnimationGIF(t *testing.T) {
+	frames, labels := animationFrames()
+	out, err := EncodeAnimation(frames, labels, AnimationGIF, AnimationFrameDelay)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	anim, err := gif.DecodeAll(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(anim.Image) != 2 || anim.Delay[0] != 50 {
+		t.Fatalf("unexpected animation of %d frames with delay %v", len(anim.Image), anim.Delay)
+	}
+
+	// The frames keep their exact colours and transparency
+	// and their labels are drawn over a white box
+	if _, _, _, a := anim.Image[0].At(39, 39).RGBA(); a != 0xFFFF {
+		t.Errorf("expected an opaque pixel")
+	}
+	if r, _, b, _ := anim.Image[1].At(39, 39).RGBA(); r != 0 || b != 0xFFFF {
+		t.Errorf("expected a blue pixel")
+	}
+	if _, _, _, a := anim.Image[0].At(39, 20).RGBA(); a != 0 {
+		t.Errorf("expected a transparent pixel")
+	}
+	if r, g, b, _ := anim.Image[0].At(1, 1).RGBA(); r != 0xFFFF || g != 0xFFFF || b != 0xFFFF {
+		t.Errorf("expected the white label box")
+	}
+}
+
+func TestEncodeAnimationAPNG(t *testing.T) {
+	frames, labels := animationFrames()
+	out, err := EncodeAnimation(frames, labels, AnimationAPNG, AnimationFrameDelay)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	for _, chunk := range []string{"acTL", "fcTL", "IDAT", "fdAT", "IEND"} {
+		if !bytes.Contains(out, []byte(chunk)) {
+			t.Errorf("missing %s chunk", chunk)
+		}
+	}
+
+	// Viewers without APNG support show the first frame
+	img, err := png.Decode(bytes.NewReader(out))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if r, _, b, a := img.At(39, 39).RGBA(); r != 0xFFFF || b != 0 || a != 0xFFFF {
+		t.Errorf("expected a red pixel")
+	}
+}
+
+func TestEncodeAnimationErrors(t *testing.T) {
+	frames, labels := animationFrames()
+	if _, err := EncodeAnimation(frames, labels, "image/png", AnimationFrameDelay); err == nil {
+		t.Errorf("expected an unsupported format error")
+	}
+	if _, err := EncodeAnimation(frames, labels[:1], AnimationGIF, AnimationFrameDelay); err == nil {
+		t.Errorf("expected a label count error")
+	}
+	frames[1] = image.NewRGBA(image.Rect(0, 0, 10, 10))
+	if _, err := EncodeAnimation(frames, labels, AnimationGIF, AnimationFrameDelay); err == nil {
+		t.Errorf("expected a frame size error")
+	}
+}
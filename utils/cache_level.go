@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseCacheLevels validates the cache levels of a layer. The
+// levels must have a path and a non empty resolution range.
+func ParseCacheLevels(levels []CacheLevel) error {
+	for i := range levels {
+		levels[i].Path = strings.TrimSpace(levels[i].Path)
+		if len(levels[i].Path) == 0 {
+			return fmt.Errorf("cache level %d has no path", i)
+		}
+		if levels[i].MinRes < 0 || levels[i].MaxRes <= levels[i].MinRes {
+			return fmt.Errorf("cache level %s must have 0 <= min_res < max_res: [%v, %v]", levels[i].Path, levels[i].MinRes, levels[i].MaxRes)
+		}
+	}
+	return nil
+}
+
+// MetresPerDegree approximately converts the resolution of
+// requests in geographic CRSs to metres
+const MetresPerDegree = 100000
+
+// RequestResolution returns the coarsest pixel size of a request
+// over a bounding box. This is the resolution compared to the
+// zoom limit and the cache levels of layers by all the services,
+// in metres per pixel for requests in geographic CRSs and in the
+// units of the CRS otherwise.
+func RequestResolution(crs string, bbox []float64, width int, height int) float64 {
+	res := (bbox[2] - bbox[0]) / float64(width)
+	if yRes := (bbox[3] - bbox[1]) / float64(height); yRes > res {
+		res = yRes
+	}
+
+	crs = strings.ToUpper(strings.TrimSpace(crs))
+	if crs == "CRS:84" {
+		return res * MetresPerDegree
+	}
+	if strings.HasPrefix(crs, "EPSG:") {
+		if epsg, err := ExtractEPSGCode(crs); err == nil && geographicEPSG[epsg] {
+			return res * MetresPerDegree
+		}
+	}
+	return res
+}
+
+// CacheLevel returns the first cache level of a layer serving
+// a resolution within [min_res, max_res], or nil if the layer
+// has none. The resolution is a RequestResolution.
+func (layer *Layer) CacheLevel(res float64) *CacheLevel {
+	for i := range layer.CacheLevels {
+		if res >= layer.CacheLevels[i].MinRes && res <= layer.CacheLevels[i].MaxRes {
+			return &layer.CacheLevels[i]
+		}
+	}
+	return nil
+}
+
+// ResolutionDataSource returns the data source of a layer at a
+// resolution, which is the path of its cache level if any
+func (layer *Layer) ResolutionDataSource(res float64) string {
+	if level := layer.CacheLevel(res); level != nil {
+		return level.Path
+	}
+	return layer.DataSource
+}
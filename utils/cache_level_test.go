@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCacheLevel(t *testing.T) {
+	layer := &Layer{DataSource: "/full", CacheLevels: []CacheLevel{
+		{Path: " /coarse ", MinRes: 1000, MaxRes: 10000},
+		{Path: "/coarser", MinRes: 10000, MaxRes: 100000},
+	}}
+	if err := ParseCacheLevels(layer.CacheLevels); err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	for _, test := range []struct {
+		res  float64
+		path string
+	}{
+		{30, "/full"},
+		{1000, "/coarse"},
+		{5000, "/coarse"},
+		{10000, "/coarse"},
+		{50000, "/coarser"},
+		{200000, "/full"},
+	} {
+		if path := layer.ResolutionDataSource(test.res); path != test.path {
+			t.Errorf("resolution %v: expected %s, got %s", test.res, test.path, path)
+		}
+	}
+	if layer.CacheLevel(30) != nil {
+		t.Errorf("expected no cache level at full resolution")
+	}
+
+	for _, invalid := range [][]CacheLevel{
+		{{Path: "", MinRes: 0, MaxRes: 10}},
+		{{Path: "/coarse", MinRes: 10, MaxRes: 10}},
+		{{Path: "/coarse", MinRes: -1, MaxRes: 10}},
+	} {
+		if err := ParseCacheLevels(invalid); err == nil {
+			t.Errorf("expected an error for %+v", invalid)
+		}
+	}
+}
+
+func TestRequestResolution(t *testing.T) {
+	layer := &Layer{DataSource: "/full", CacheLevels: []CacheLevel{
+		{Path: "/coarse", MinRes: 500, MaxRes: 100000},
+	}}
+
+	// The same view of 0.1 degree pixels requested by each
+	// service selects the same collection
+	getMap, err := WMSParamsChecker(map[string][]string{
+		"version": {"1.1.1"}, "request": {"GetMap"}, "srs": {"EPSG:4326"},
+		"bbox": {"110,-45,155,-10"}, "width": {"450"}, "height": {"350"},
+	}, CompileWMSRegexMap())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	featureInfo, err := WMSParamsChecker(map[string][]string{
+		"version": {"1.3.0"}, "request": {"GetFeatureInfo"}, "crs": {"EPSG:4326"},
+		"bbox": {"-45,110,-10,155"}, "width": {"450"}, "height": {"350"},
+	}, CompileWMSRegexMap())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	getCoverage, err := WCSParamsChecker(map[string][]string{
+		"version": {"1.0.0"}, "request": {"GetCoverage"}, "crs": {"EPSG:4326"},
+		"bbox": {"110,-45,155,-10"}, "width": {"450"}, "height": {"350"},
+	}, CompileWCSRegexMap())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// WMS 1.3.0 bounding boxes in EPSG:4326 are in latitude,
+	// longitude order
+	fiBBox := []float64{featureInfo.BBox[1], featureInfo.BBox[0], featureInfo.BBox[3], featureInfo.BBox[2]}
+	resolutions := map[string]float64{
+		"GetMap":         RequestResolution(*getMap.CRS, getMap.BBox, *getMap.Width, *getMap.Height),
+		"GetFeatureInfo": RequestResolution(*featureInfo.CRS, fiBBox, *featureInfo.Width, *featureInfo.Height),
+		"GetCoverage":    RequestResolution(*getCoverage.CRS, getCoverage.BBox, *getCoverage.Width, *getCoverage.Height),
+	}
+	for service, res := range resolutions {
+		if math.Abs(res-10000) > 1e-6 {
+			t.Errorf("%s: expected a resolution of 10000, got %v", service, res)
+		}
+		if path := layer.ResolutionDataSource(res); path != "/coarse" {
+			t.Errorf("%s: expected /coarse, got %s", service, path)
+		}
+	}
+
+	// Projected CRSs keep their units
+	if res := RequestResolution("EPSG:3857", []float64{0, 0, 2560, 2560}, 256, 256); res != 10 {
+		t.Errorf("expected a resolution of 10, got %v", res)
+	}
+	if path := layer.ResolutionDataSource(10); path != "/full" {
+		t.Errorf("expected /full, got %s", path)
+	}
+}
@@ -0,0 +1,199 @@
+package utils
+
+// #include "gdal.h"
+// #include "ogr_api.h"
+// #include "ogr_srs_api.h"
+// #cgo pkg-config: gdal
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unsafe"
+)
+
+// ClipGeometry contains a polygon used to clip
+// the output of a WCS GetCoverage request.
+type ClipGeometry struct {
+	// WKT is the polygon in the CRS of the request
+	WKT string
+	// BBox is the envelope of the polygon in the CRS
+	// of the request: xMin, yMin, xMax, yMax
+	BBox []float64
+	// Area is computed in WGS84 as done by GetArea
+	// so that it can be compared to MaxArea limits
+	Area float64
+}
+
+var clipIDRegexp = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// clipFileExts lists the file extensions searched when
+// resolving a clip geometry reference ID.
+var clipFileExts = []string{".geojson", ".json", ".wkt"}
+
+// LoadClipGeometry returns the content of the geometry
+// file referenced by id inside dir.
+func LoadClipGeometry(dir string, id string) (string, error) {
+	if len(dir) == 0 {
+		return "", fmt.Errorf("clip geometry references are not enabled")
+	}
+
+	if !clipIDRegexp.MatchString(id) {
+		return "", fmt.Errorf("invalid clip geometry id: %s", id)
+	}
+
+	for _, ext := range clipFileExts {
+		fileName := filepath.Join(dir, id+ext)
+		if _, err := os.Stat(fileName); err != nil {
+			continue
+		}
+
+		geomText, err := ioutil.ReadFile(fileName)
+		if err != nil {
+			return "", fmt.Errorf("failed to read clip geometry %s: %v", id, err)
+		}
+		return string(geomText), nil
+	}
+
+	return "", fmt.Errorf("clip geometry %s not found", id)
+}
+
+// ParseClipGeometry parses a polygon given either as GeoJSON
+// or as WKT. GeoJSON coordinates are WGS84 as mandated by the
+// GeoJSON specification whereas WKT coordinates are expected
+// in the CRS of the request given by epsg. Features and
+// feature collections are merged into a single geometry.
+func ParseClipGeometry(geomText string, epsg int) (*ClipGeometry, error) {
+	geomText = strings.TrimSpace(geomText)
+	if len(geomText) == 0 {
+		return nil, fmt.Errorf("empty clip geometry")
+	}
+
+	var hGeom C.OGRGeometryH
+	isGeoJSON := strings.HasPrefix(geomText, "{")
+	if isGeoJSON {
+		geoms, err := extractGeoJSONGeometries(geomText)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, g := range geoms {
+			geomC := C.CString(string(g))
+			hPart := C.OGR_G_CreateGeometryFromJson(geomC)
+			C.free(unsafe.Pointer(geomC))
+			if hPart == nil {
+				if hGeom != nil {
+					C.OGR_G_DestroyGeometry(hGeom)
+				}
+				return nil, fmt.Errorf("invalid GeoJSON geometry")
+			}
+
+			if hGeom == nil {
+				hGeom = hPart
+				continue
+			}
+
+			hUnion := C.OGR_G_Union(hGeom, hPart)
+			C.OGR_G_DestroyGeometry(hPart)
+			C.OGR_G_DestroyGeometry(hGeom)
+			if hUnion == nil {
+				return nil, fmt.Errorf("failed to merge GeoJSON geometries")
+			}
+			hGeom = hUnion
+		}
+	} else {
+		geomC := C.CString(geomText)
+		defer C.free(unsafe.Pointer(geomC))
+		wktPtr := geomC
+		if C.OGR_G_CreateFromWkt(&wktPtr, nil, &hGeom) != C.OGRERR_NONE || hGeom == nil {
+			return nil, fmt.Errorf("invalid WKT geometry")
+		}
+	}
+	defer C.OGR_G_DestroyGeometry(hGeom)
+
+	geomType := C.OGR_GT_Flatten(C.OGR_G_GetGeometryType(hGeom))
+	if geomType != C.wkbPolygon && geomType != C.wkbMultiPolygon {
+		return nil, fmt.Errorf("clip geometry must be a Polygon or MultiPolygon")
+	}
+
+	dstSRS := C.OSRNewSpatialReference(nil)
+	defer C.OSRRelease(dstSRS)
+	if C.OSRImportFromEPSG(dstSRS, C.int(epsg)) != C.OGRERR_NONE {
+		return nil, fmt.Errorf("invalid EPSG code: %v", epsg)
+	}
+
+	wgs84C := C.CString(WGS84WKT)
+	defer C.free(unsafe.Pointer(wgs84C))
+	wgs84SRS := C.OSRNewSpatialReference(wgs84C)
+	defer C.OSRRelease(wgs84SRS)
+
+	srcSRS := dstSRS
+	if isGeoJSON {
+		srcSRS = wgs84SRS
+	}
+	C.OGR_G_AssignSpatialReference(hGeom, srcSRS)
+
+	hWGS84Geom := C.OGR_G_Clone(hGeom)
+	defer C.OGR_G_DestroyGeometry(hWGS84Geom)
+	if C.OGR_G_TransformTo(hWGS84Geom, wgs84SRS) != C.OGRERR_NONE {
+		return nil, fmt.Errorf("failed to transform clip geometry to WGS84")
+	}
+
+	if C.OGR_G_TransformTo(hGeom, dstSRS) != C.OGRERR_NONE {
+		return nil, fmt.Errorf("failed to transform clip geometry to EPSG:%v", epsg)
+	}
+
+	var wktC *C.char
+	if C.OGR_G_ExportToWkt(hGeom, &wktC) != C.OGRERR_NONE {
+		return nil, fmt.Errorf("failed to export clip geometry")
+	}
+	defer C.free(unsafe.Pointer(wktC))
+
+	var env C.OGREnvelope
+	C.OGR_G_GetEnvelope(hGeom, &env)
+
+	return &ClipGeometry{
+		WKT:  C.GoString(wktC),
+		BBox: []float64{float64(env.MinX), float64(env.MinY), float64(env.MaxX), float64(env.MaxY)},
+		Area: float64(C.OGR_G_Area(hWGS84Geom)),
+	}, nil
+}
+
+// extractGeoJSONGeometries returns the geometries contained in a
+// GeoJSON geometry, feature or feature collection document.
+func extractGeoJSONGeometries(geoJSON string) ([]json.RawMessage, error) {
+	var doc struct {
+		Type     string          `json:"type"`
+		Geometry json.RawMessage `json:"geometry"`
+		Features []struct {
+			Geometry json.RawMessage `json:"geometry"`
+		} `json:"features"`
+	}
+
+	if err := json.Unmarshal([]byte(geoJSON), &doc); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON: %v", err)
+	}
+
+	var geoms []json.RawMessage
+	switch doc.Type {
+	case "FeatureCollection":
+		for _, feat := range doc.Features {
+			geoms = append(geoms, feat.Geometry)
+		}
+	case "Feature":
+		geoms = append(geoms, doc.Geometry)
+	default:
+		geoms = append(geoms, json.RawMessage(geoJSON))
+	}
+
+	if len(geoms) == 0 {
+		return nil, fmt.Errorf("GeoJSON contains no geometries")
+	}
+
+	return geoms, nil
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// colormapStop is a colour at a position within [0, 1]
+type colormapStop struct {
+	Pos    float64
+	Colour color.RGBA
+}
+
+// evenStops spreads hex colours evenly over [0, 1]
+func evenStops(hexColours ...string) []colormapStop {
+	stops := make([]colormapStop, len(hexColours))
+	for i, hex := range hexColours {
+		colour, err := ParseHexColour(hex)
+		if err != nil {
+			panic(err)
+		}
+		stops[i] = colormapStop{Pos: float64(i) / float64(len(hexColours)-1), Colour: colour}
+	}
+	return stops
+}
+
+// Colormaps are the built-in named colormaps. The perceptual
+// maps are sampled from matplotlib and the diverging and
+// sequential ones from ColorBrewer.
+var Colormaps = map[string][]colormapStop{
+	"viridis":  evenStops("#440154", "#482475", "#414487", "#355f8d", "#2a788e", "#21918c", "#22a884", "#44bf70", "#7ad151", "#bddf26", "#fde725"),
+	"magma":    evenStops("#000004", "#140e36", "#3b0f70", "#641a80", "#8c2981", "#b73779", "#de4968", "#f7705c", "#fe9f6d", "#fecf92", "#fcfdbf"),
+	"inferno":  evenStops("#000004", "#160b39", "#420a68", "#6a176e", "#932667", "#bc3754", "#dd513a", "#f37819", "#fca50a", "#f6d746", "#fcffa4"),
+	"plasma":   evenStops("#0d0887", "#41049d", "#6a00a8", "#8f0da4", "#b12a90", "#cc4778", "#e16462", "#f2844b", "#fca636", "#fcce25", "#f0f921"),
+	"cividis":  evenStops("#00224e", "#123570", "#3b496c", "#575d6d", "#707173", "#8a8779", "#a69d75", "#c4b56c", "#e4cf5b", "#fee838"),
+	"rdylbu":   evenStops("#a50026", "#d73027", "#f46d43", "#fdae61", "#fee090", "#ffffbf", "#e0f3f8", "#abd9e9", "#74add1", "#4575b4", "#313695"),
+	"rdylgn":   evenStops("#a50026", "#d73027", "#f46d43", "#fdae61", "#fee08b", "#ffffbf", "#d9ef8b", "#a6d96a", "#66bd63", "#1a9850", "#006837"),
+	"spectral": evenStops("#9e0142", "#d53e4f", "#f46d43", "#fdae61", "#fee08b", "#ffffbf", "#e6f598", "#abdda4", "#66c2a5", "#3288bd", "#5e4fa2"),
+	"brbg":     evenStops("#543005", "#8c510a", "#bf812d", "#dfc27d", "#f6e8c3", "#f5f5f5", "#c7eae5", "#80cdc1", "#35978f", "#01665e", "#003c30"),
+	"blues":    evenStops("#f7fbff", "#deebf7", "#c6dbef", "#9ecae1", "#6baed6", "#4292c6", "#2171b5", "#08519c", "#08306b"),
+	"greens":   evenStops("#f7fcf5", "#e5f5e0", "#c7e9c0", "#a1d99b", "#74c476", "#41ab5d", "#238b45", "#006d2c", "#00441b"),
+	"greys":    evenStops("#ffffff", "#000000"),
+	"terrain": {
+		{0, color.RGBA{0x33, 0x33, 0x99, 0xFF}},
+		{0.15, color.RGBA{0x00, 0x99, 0xFF, 0xFF}},
+		{0.25, color.RGBA{0x00, 0xCC, 0x66, 0xFF}},
+		{0.5, color.RGBA{0xFF, 0xFF, 0x99, 0xFF}},
+		{0.75, color.RGBA{0x80, 0x5C, 0x54, 0xFF}},
+		{1, color.RGBA{0xFF, 0xFF, 0xFF, 0xFF}},
+	},
+}
+
+// ParseHexColour parses a #RRGGBB or #RRGGBBAA colour
+func ParseHexColour(hex string) (color.RGBA, error) {
+	hex = strings.TrimPrefix(strings.TrimSpace(hex), "#")
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.RGBA{}, fmt.Errorf("invalid colour %s, expecting #RRGGBB or #RRGGBBAA", hex)
+	}
+
+	val, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid colour %s, expecting #RRGGBB or #RRGGBBAA", hex)
+	}
+	return color.RGBA{uint8(val >> 24), uint8(val >> 16), uint8(val >> 8), uint8(val)}, nil
+}
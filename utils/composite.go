@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Composite reducers merging the rasters of a time window
+// into a single raster. The latest reducer keeps the most
+// recent valid pixel and is the default.
+const (
+	CompositeLatest = "latest"
+	CompositeMedian = "median"
+	CompositeMean   = "mean"
+	CompositeMin    = "min"
+	CompositeMax    = "max"
+	CompositeCount  = "count"
+	CompositeSum    = "sum"
+)
+
+// CompositeMaxBytes bounds the memory of the per-pixel
+// stacks of a composite tile
+const CompositeMaxBytes = 512 * 1024 * 1024
+
+var compositeReducers = map[string]bool{
+	CompositeLatest: true,
+	CompositeMedian: true,
+	CompositeMean:   true,
+	CompositeMin:    true,
+	CompositeMax:    true,
+	CompositeCount:  true,
+	CompositeSum:    true,
+}
+
+// ParseComposite validates the name of a composite reducer.
+// An empty name is valid and selects the latest reducer.
+func ParseComposite(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) == 0 {
+		return "", nil
+	}
+
+	if !compositeReducers[name] {
+		return "", fmt.Errorf("unsupported composite: %s", name)
+	}
+	return name, nil
+}
@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestParseComposite(t *testing.T) {
+	cases := map[string]string{
+		"":        "",
+		"latest":  CompositeLatest,
+		"Median":  CompositeMedian,
+		" count ": CompositeCount,
+		"sum":     CompositeSum,
+	}
+
+	for name, expected := range cases {
+		composite, err := ParseComposite(name)
+		if err != nil {
+			t.Errorf("failed to parse composite %q: %v", name, err)
+			continue
+		}
+		if composite != expected {
+			t.Errorf("composite %q: expected %q, got %q", name, expected, composite)
+		}
+	}
+
+	if _, err := ParseComposite("mode"); err == nil {
+		t.Errorf("expected error for unsupported composite")
+	}
+}
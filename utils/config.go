@@ -26,13 +26,16 @@ var DataDir = "."
 const ReservedMemorySize = 1.5 * 1024 * 1024 * 1024
 
 type ServiceConfig struct {
-	OWSHostname       string `json:"ows_hostname"`
-	NameSpace         string
-	MASAddress        string   `json:"mas_address"`
-	WorkerNodes       []string `json:"worker_nodes"`
-	OWSClusterNodes   []string `json:"ows_cluster_nodes"`
-	TempDir           string   `json:"temp_dir"`
-	MaxGrpcBufferSize int      `json:"max_grpc_buffer_size"`
+	OWSHostname        string `json:"ows_hostname"`
+	NameSpace          string
+	MASAddress         string   `json:"mas_address"`
+	WorkerNodes        []string `json:"worker_nodes"`
+	OWSClusterNodes    []string `json:"ows_cluster_nodes"`
+	TempDir            string   `json:"temp_dir"`
+	MaxGrpcBufferSize  int      `json:"max_grpc_buffer_size"`
+	ClipDir            string   `json:"clip_dir"`
+	WpsResultDir       string   `json:"wps_result_dir"`
+	WpsResultRetention int      `json:"wps_result_retention"`
 }
 
 // CacheLevel contains the source files of one layer as well as the
@@ -47,17 +50,31 @@ type CacheLevel struct {
 	MaxRes float64 `json:"max_res"`
 }
 
+// Mask masks out the pixels of a layer. Expression is
+// evaluated over one or more mask namespaces and pixels where
+// it is true are masked out. Value and BitTests are binary
+// tests of the ID namespace compiled into an expression.
 type Mask struct {
 	ID         string   `json:"id"`
 	Value      string   `json:"value"`
 	DataSource string   `json:"data_source"`
 	Inclusive  bool     `json:"inclusive"`
 	BitTests   []string `json:"bit_tests"`
+	Expression string   `json:"expression"`
+	Expr       *goeval.EvaluableExpression
+	NameSpaces []string
 }
 
+// Palette colours single band images. The colours are either
+// a list of RGBA stops spread over the scaled values, a named
+// colormap or classified breaks in data units.
 type Palette struct {
+	Name        string       `json:"name"`
 	Interpolate bool         `json:"interpolate"`
 	Colours     []color.RGBA `json:"colours"`
+	Breaks      []ClassBreak `json:"breaks"`
+	Reverse     bool         `json:"reverse"`
+	Alpha       float64      `json:"alpha"`
 }
 
 type BandExpressions struct {
@@ -66,6 +83,10 @@ type BandExpressions struct {
 	VarList     []string
 	ExprNames   []string
 	ExprVarRef  [][]string
+
+	// HandlesNoData tells whether each expression calls
+	// isnodata and sees nodata inputs as NaN
+	HandlesNoData []bool
 }
 
 // Layer contains all the details that a layer needs
@@ -78,7 +99,7 @@ type Layer struct {
 	Abstract    string `json:"abstract"`
 	MetadataURL string `json:"metadata_url"`
 	DataURL     string `json:"data_url"`
-	//CacheLevels  []CacheLevel `json:"cache_levels"`
+	CacheLevels              []CacheLevel `json:"cache_levels"`
 	DataSource               string `json:"data_source"`
 	StartISODate             string `json:"start_isodate"`
 	EndISODate               string `json:"end_isodate"`
@@ -95,9 +116,12 @@ type Layer struct {
 	RGBProducts              []string `json:"rgb_products"`
 	RGBExpressions           *BandExpressions
 	Mask                     *Mask    `json:"mask"`
+	Score                    *QualityScore `json:"score"`
 	OffsetValue              float64  `json:"offset_value"`
 	ClipValue                float64  `json:"clip_value"`
 	ScaleValue               float64  `json:"scale_value"`
+	Stretch                  *Stretch `json:"stretch"`
+	Terrain                  *Terrain `json:"terrain"`
 	Palette                  *Palette `json:"palette"`
 	LegendPath               string   `json:"legend_path"`
 	LegendHeight             int      `json:"legend_height"`
@@ -116,6 +140,9 @@ type Layer struct {
 	BandStrides              int      `json:"band_strides"`
 	WmsMaxWidth              int      `json:"wms_max_width"`
 	WmsMaxHeight             int      `json:"wms_max_height"`
+	WmsMaxAnimationFrames    int      `json:"wms_max_animation_frames"`
+	WmsMaxAnimationPixels    int      `json:"wms_max_animation_pixels"`
+	WmsAnimationConcLimit    int      `json:"wms_animation_conc_limit"`
 	WcsMaxWidth              int      `json:"wcs_max_width"`
 	WcsMaxHeight             int      `json:"wcs_max_height"`
 	WcsMaxTileWidth          int      `json:"wcs_max_tile_width"`
@@ -124,24 +151,53 @@ type Layer struct {
 	FeatureInfoDataLinkUrl   string   `json:"feature_info_data_link_url"`
 	FeatureInfoBands         []string `json:"feature_info_bands"`
 	FeatureInfoExpressions   *BandExpressions
-	NoDataLegendPath         string `json:"nodata_legend_path"`
+	NoDataLegendPath         string       `json:"nodata_legend_path"`
+	Resampling               string       `json:"resampling"`
+	Composite                string       `json:"composite"`
+	WcsMaxClipArea           float64      `json:"wcs_max_clip_area"`
+	ClassLabels              []ClassLabel `json:"class_labels"`
+}
+
+// ClassLabel names a class of a categorical data source
+type ClassLabel struct {
+	Value float64 `json:"value"`
+	Label string  `json:"label"`
 }
 
 // Process contains all the details that a WPS needs
 // to be published and processed
 type Process struct {
-	DataSources []Layer    `json:"data_sources"`
-	Identifier  string     `json:"identifier"`
-	Title       string     `json:"title"`
-	Abstract    string     `json:"abstract"`
-	MaxArea     float64    `json:"max_area"`
-	LiteralData []LitData  `json:"literal_data"`
-	ComplexData []CompData `json:"complex_data"`
-	IdentityTol float64    `json:"identity_tol"`
-	DpTol       float64    `json:"dp_tol"`
-	Approx      *bool      `json:"approx,omitempty"`
+	DataSources       []Layer    `json:"data_sources"`
+	Identifier        string     `json:"identifier"`
+	Title             string     `json:"title"`
+	Abstract          string     `json:"abstract"`
+	MaxArea           float64    `json:"max_area"`
+	LiteralData       []LitData  `json:"literal_data"`
+	ComplexData       []CompData `json:"complex_data"`
+	IdentityTol       float64    `json:"identity_tol"`
+	DpTol             float64    `json:"dp_tol"`
+	Approx            *bool      `json:"approx,omitempty"`
+	MaxFeatures       int        `json:"max_features"`
+	FeatureConcLimit  int        `json:"feature_conc_limit"`
+	ProcessType       string     `json:"process_type"`
+	ProfileSpacing    float64    `json:"profile_spacing"`
+	MaxProfileSamples int        `json:"max_profile_samples"`
+	BaselineStart     string     `json:"baseline_start"`
+	BaselineEnd       string     `json:"baseline_end"`
+	WpsTimeout        int        `json:"wps_timeout"`
+	Exact             bool       `json:"exact"`
 }
 
+// WPS process types. Drill processes compute zonal
+// statistics whereas histogram processes count the
+// pixels of each class of categorical data. Profile
+// processes sample the data along lines.
+const (
+	WPSProcessDrill     = "drill"
+	WPSProcessHistogram = "histogram"
+	WPSProcessProfile   = "profile"
+)
+
 // LitData contains the description of a variable used to compute a
 // WPS operation
 type LitData struct {
@@ -407,6 +463,52 @@ func GenerateDatesMas(start, end string, masAddress string, collection string, n
 	return timestamps.Timestamps, timestamps.Token
 }
 
+// GetMasStatistics returns the band statistics of a collection
+// by namespace
+func GetMasStatistics(masAddress string, collection string, namespaces []string, verbose bool) (map[string]BandStatistics, error) {
+	ns := strings.Join(namespaces, ",")
+	url := strings.Replace(fmt.Sprintf("http://%s%s?statistics&namespace=%s", masAddress, collection, ns), " ", "%20", -1)
+	if verbose {
+		log.Printf("config querying MAS for statistics: %v", url)
+	}
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("MAS http error: %v,%v", url, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("MAS http error: %v,%v", url, err)
+	}
+
+	type MasStatistics struct {
+		Error      string           `json:"error"`
+		Statistics []BandStatistics `json:"statistics"`
+	}
+
+	var masStats MasStatistics
+	err = json.Unmarshal(body, &masStats)
+	if err != nil {
+		return nil, fmt.Errorf("MAS json response error: %v", err)
+	}
+
+	if len(masStats.Error) > 0 {
+		return nil, fmt.Errorf("MAS returned error: %v", masStats.Error)
+	}
+
+	if verbose {
+		log.Printf("MAS returned statistics of %v bands", len(masStats.Statistics))
+	}
+
+	stats := make(map[string]BandStatistics)
+	for _, bandStats := range masStats.Statistics {
+		stats[bandStats.NameSpace] = bandStats
+	}
+	return stats, nil
+}
+
 func GenerateDates(name string, start, end time.Time, stepMins time.Duration) []string {
 	dateGen := make(map[string]func(time.Time, time.Time, time.Duration) []string)
 	dateGen["aux"] = GenerateDatesAux
@@ -454,6 +556,15 @@ func LoadAllConfigFiles(rootDir string, verbose bool) (map[string]*Config, error
 					if len(config.Layers[i].Styles[j].DataSource) == 0 {
 						config.Layers[i].Styles[j].DataSource = config.Layers[i].DataSource
 					}
+					if len(config.Layers[i].Styles[j].Resampling) == 0 {
+						config.Layers[i].Styles[j].Resampling = config.Layers[i].Resampling
+					}
+					if len(config.Layers[i].Styles[j].Composite) == 0 {
+						config.Layers[i].Styles[j].Composite = config.Layers[i].Composite
+					}
+					if len(config.Layers[i].Styles[j].CacheLevels) == 0 && config.Layers[i].Styles[j].DataSource == config.Layers[i].DataSource {
+						config.Layers[i].Styles[j].CacheLevels = config.Layers[i].CacheLevels
+					}
 					if config.Layers[i].Styles[j].LegendWidth <= 0 {
 						config.Layers[i].Styles[j].LegendWidth = DefaultLegendWidth
 					}
@@ -475,6 +586,8 @@ func LoadAllConfigFiles(rootDir string, verbose bool) (map[string]*Config, error
 						config.Layers[i].Styles[j].FeatureInfoExpressions = featureInfoExpr
 					}
 				}
+
+				config.GetLayerStatistics(i, verbose)
 			}
 		}
 		return nil
@@ -537,14 +650,50 @@ const DefaultWcsPolygonShardConcLimit = 2
 
 const DefaultWmsMaxWidth = 512
 const DefaultWmsMaxHeight = 512
+const DefaultWmsMaxAnimationFrames = 24
+const DefaultWmsMaxAnimationPixels = 512 * 512 * 24
+const DefaultWmsAnimationConcLimit = 4
 const DefaultWcsMaxWidth = 50000
 const DefaultWcsMaxHeight = 30000
 const DefaultWcsMaxTileWidth = 1024
 const DefaultWcsMaxTileHeight = 1024
 
+const DefaultWpsResultRetention = 24
+
+const DefaultWpsMaxFeatures = 1000
+const DefaultWpsFeatureConcLimit = 4
+
+// Default spacing in metres between the samples of profiles
+const DefaultWpsProfileSpacing = 1000.0
+const DefaultWpsMaxProfileSamples = 10000
+
+// Default time limit in seconds of WPS Execute requests
+const DefaultWpsTimeout = 300
+
 const DefaultLegendWidth = 160
 const DefaultLegendHeight = 320
 
+// GetLayerStatistics loads the band statistics of the ith layer
+// and its styles which are stretched by the statistics range
+func (config *Config) GetLayerStatistics(iLayer int, verbose bool) {
+	layers := []*Layer{&config.Layers[iLayer]}
+	for j := range config.Layers[iLayer].Styles {
+		layers = append(layers, &config.Layers[iLayer].Styles[j])
+	}
+
+	for _, layer := range layers {
+		if layer.Stretch == nil || layer.Stretch.Range != StretchRangeStatistics || layer.RGBExpressions == nil {
+			continue
+		}
+		stats, err := GetMasStatistics(config.ServiceConfig.MASAddress, layer.DataSource, layer.RGBExpressions.VarList, verbose)
+		if err != nil {
+			log.Printf("Layer %v failed to get MAS statistics: %v", layer.Name, err)
+			continue
+		}
+		layer.Stretch.Statistics = stats
+	}
+}
+
 // GetLayerDates loads dates for the ith layer
 func (config *Config) GetLayerDates(iLayer int, verbose bool) {
 	layer := config.Layers[iLayer]
@@ -658,7 +807,7 @@ func ParseBandExpressions(bands []string) (*BandExpressions, error) {
 	varFound := make(map[string]bool)
 	hasExprAll := false
 	for ib, bandRaw := range bands {
-		parts := strings.Split(bandRaw, "=")
+		parts := splitBandExpression(bandRaw)
 		if len(parts) == 0 {
 			return nil, fmt.Errorf("invalid expression: %v", bandRaw)
 		}
@@ -677,11 +826,12 @@ func ParseBandExpressions(bands []string) (*BandExpressions, error) {
 			return nil, fmt.Errorf("invalid expression: %v", bandRaw)
 		}
 
-		expr, err := goeval.NewEvaluableExpression(band)
+		expr, err := goeval.NewEvaluableExpressionWithFunctions(band, ExprFunctions)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("invalid expression %v: %v", bandRaw, err)
 		}
 		bandExpr.Expressions = append(bandExpr.Expressions, expr)
+		bandExpr.HandlesNoData = append(bandExpr.HandlesNoData, HandlesNoData(band))
 
 		bandExpr.ExprVarRef = append(bandExpr.ExprVarRef, []string{})
 		bandVarFound := make(map[string]bool)
@@ -717,9 +867,49 @@ func ParseBandExpressions(bands []string) (*BandExpressions, error) {
 	if !hasExprAll {
 		bandExpr.Expressions = nil
 	}
+
+	// Expressions are evaluated once over dummy values to
+	// report errors such as wrong function arguments early
+	for ie, expr := range bandExpr.Expressions {
+		parameters := make(map[string]interface{}, len(bandExpr.ExprVarRef[ie]))
+		for _, varName := range bandExpr.ExprVarRef[ie] {
+			parameters[varName] = 1.0
+		}
+		if _, err := expr.Evaluate(parameters); err != nil {
+			return nil, fmt.Errorf("invalid expression %v: %v", bands[ie], err)
+		}
+	}
 	return bandExpr, nil
 }
 
+// splitBandExpression splits a band into its name and its
+// expression at the assignment sign. Comparison operators
+// such as == or <= are part of the expression.
+func splitBandExpression(bandRaw string) []string {
+	var assign []int
+	for i := 0; i < len(bandRaw); i++ {
+		if bandRaw[i] != '=' {
+			continue
+		}
+		if i+1 < len(bandRaw) && bandRaw[i+1] == '=' {
+			i++
+			continue
+		}
+		if i > 0 && strings.IndexByte("<>!", bandRaw[i-1]) >= 0 {
+			continue
+		}
+		assign = append(assign, i)
+	}
+
+	parts := []string{}
+	start := 0
+	for _, i := range assign {
+		parts = append(parts, bandRaw[start:i])
+		start = i + 1
+	}
+	return append(parts, bandRaw[start:])
+}
+
 // LoadConfigFileTemplate parses the config as a Jet
 // template and escapes any GSKY here docs (i.e. $gdoc$)
 // into valid one-line JSON strings.
@@ -809,6 +999,19 @@ func (config *Config) LoadConfigFile(configFile string, verbose bool) error {
 	}
 
 	config.ServiceConfig.MaxGrpcBufferSize = config.ServiceConfig.MaxGrpcBufferSize * 1024 * 1024
+
+	if len(config.ServiceConfig.WpsResultDir) == 0 {
+		tempDir := config.ServiceConfig.TempDir
+		if len(tempDir) == 0 {
+			tempDir = os.TempDir()
+		}
+		config.ServiceConfig.WpsResultDir = filepath.Join(tempDir, "gsky_wps_results")
+	}
+
+	if config.ServiceConfig.WpsResultRetention <= 0 {
+		config.ServiceConfig.WpsResultRetention = DefaultWpsResultRetention
+	}
+
 	for i, layer := range config.Layers {
 		bandExpr, err := ParseBandExpressions(layer.RGBProducts)
 		if err != nil {
@@ -822,6 +1025,114 @@ func (config *Config) LoadConfigFile(configFile string, verbose bool) error {
 		}
 		config.Layers[i].FeatureInfoExpressions = featureInfoExpr
 
+		resampling, err := ParseResampling(layer.Resampling)
+		if err != nil {
+			return fmt.Errorf("Layer %v resampling error: %v", layer.Name, err)
+		}
+		config.Layers[i].Resampling = resampling
+
+		for j, style := range layer.Styles {
+			resampling, err := ParseResampling(style.Resampling)
+			if err != nil {
+				return fmt.Errorf("Layer %v, style %v, resampling error: %v", layer.Name, style.Name, err)
+			}
+			config.Layers[i].Styles[j].Resampling = resampling
+		}
+
+		if layer.Score != nil {
+			if err := ParseQualityScore(layer.Score); err != nil {
+				return fmt.Errorf("Layer %v score error: %v", layer.Name, err)
+			}
+		}
+
+		for _, style := range layer.Styles {
+			if style.Score != nil {
+				if err := ParseQualityScore(style.Score); err != nil {
+					return fmt.Errorf("Layer %v, style %v, score error: %v", layer.Name, style.Name, err)
+				}
+			}
+		}
+
+		if layer.Mask != nil {
+			if err := ParseMask(layer.Mask); err != nil {
+				return fmt.Errorf("Layer %v mask error: %v", layer.Name, err)
+			}
+		}
+
+		for _, style := range layer.Styles {
+			if style.Mask != nil {
+				if err := ParseMask(style.Mask); err != nil {
+					return fmt.Errorf("Layer %v, style %v, mask error: %v", layer.Name, style.Name, err)
+				}
+			}
+		}
+
+		if layer.Palette != nil {
+			if err := ParsePalette(layer.Palette); err != nil {
+				return fmt.Errorf("Layer %v palette error: %v", layer.Name, err)
+			}
+		}
+
+		for _, style := range layer.Styles {
+			if style.Palette != nil {
+				if err := ParsePalette(style.Palette); err != nil {
+					return fmt.Errorf("Layer %v, style %v, palette error: %v", layer.Name, style.Name, err)
+				}
+			}
+		}
+
+		if layer.Stretch != nil {
+			if err := ParseStretch(layer.Stretch); err != nil {
+				return fmt.Errorf("Layer %v stretch error: %v", layer.Name, err)
+			}
+		}
+
+		for _, style := range layer.Styles {
+			if style.Stretch != nil {
+				if err := ParseStretch(style.Stretch); err != nil {
+					return fmt.Errorf("Layer %v, style %v, stretch error: %v", layer.Name, style.Name, err)
+				}
+			}
+		}
+
+		if err := ParseCacheLevels(layer.CacheLevels); err != nil {
+			return fmt.Errorf("Layer %v cache level error: %v", layer.Name, err)
+		}
+
+		for _, style := range layer.Styles {
+			if err := ParseCacheLevels(style.CacheLevels); err != nil {
+				return fmt.Errorf("Layer %v, style %v, cache level error: %v", layer.Name, style.Name, err)
+			}
+		}
+
+		if layer.Terrain != nil {
+			if err := parseLayerTerrain(&config.Layers[i]); err != nil {
+				return fmt.Errorf("Layer %v terrain error: %v", layer.Name, err)
+			}
+		}
+
+		for j, style := range layer.Styles {
+			if style.Terrain != nil {
+				if err := parseLayerTerrain(&config.Layers[i].Styles[j]); err != nil {
+					return fmt.Errorf("Layer %v, style %v, terrain error: %v", layer.Name, style.Name, err)
+				}
+			}
+		}
+
+		composite, err := ParseComposite(layer.Composite)
+		if err != nil {
+			return fmt.Errorf("Layer %v composite error: %v", layer.Name, err)
+		}
+		config.Layers[i].Composite = composite
+
+		for j, style := range layer.Styles {
+			composite, err := ParseComposite(style.Composite)
+			if err != nil {
+				return fmt.Errorf("Layer %v, style %v, composite error: %v", layer.Name, style.Name, err)
+			}
+			config.Layers[i].Styles[j].Composite = composite
+		}
+
 		config.GetLayerDates(i, verbose)
 
 		config.Layers[i].OWSHostname = config.ServiceConfig.OWSHostname
@@ -874,6 +1185,18 @@ func (config *Config) LoadConfigFile(configFile string, verbose bool) error {
 			config.Layers[i].WmsMaxHeight = DefaultWmsMaxHeight
 		}
 
+		if config.Layers[i].WmsMaxAnimationFrames <= 0 {
+			config.Layers[i].WmsMaxAnimationFrames = DefaultWmsMaxAnimationFrames
+		}
+
+		if config.Layers[i].WmsMaxAnimationPixels <= 0 {
+			config.Layers[i].WmsMaxAnimationPixels = DefaultWmsMaxAnimationPixels
+		}
+
+		if config.Layers[i].WmsAnimationConcLimit <= 0 {
+			config.Layers[i].WmsAnimationConcLimit = DefaultWmsAnimationConcLimit
+		}
+
 		if config.Layers[i].WcsMaxWidth <= 0 {
 			config.Layers[i].WcsMaxWidth = DefaultWcsMaxWidth
 		}
@@ -905,6 +1228,42 @@ func (config *Config) LoadConfigFile(configFile string, verbose bool) error {
 			config.Processes[i].Approx = &approx
 		}
 
+		switch proc.ProcessType {
+		case "":
+			config.Processes[i].ProcessType = WPSProcessDrill
+		case WPSProcessDrill, WPSProcessHistogram, WPSProcessProfile:
+		default:
+			return fmt.Errorf("Process %v, invalid process type: %v", proc.Identifier, proc.ProcessType)
+		}
+
+		if proc.MaxFeatures <= 0 {
+			config.Processes[i].MaxFeatures = DefaultWpsMaxFeatures
+		}
+
+		if proc.FeatureConcLimit <= 0 {
+			config.Processes[i].FeatureConcLimit = DefaultWpsFeatureConcLimit
+		}
+
+		if proc.ProfileSpacing <= 0 {
+			config.Processes[i].ProfileSpacing = DefaultWpsProfileSpacing
+		}
+
+		if proc.MaxProfileSamples <= 0 {
+			config.Processes[i].MaxProfileSamples = DefaultWpsMaxProfileSamples
+		}
+
+		if proc.WpsTimeout <= 0 {
+			config.Processes[i].WpsTimeout = DefaultWpsTimeout
+		}
+
+		for _, baseline := range []string{proc.BaselineStart, proc.BaselineEnd} {
+			if len(baseline) > 0 {
+				if _, err := ParseWPSDate(baseline); err != nil {
+					return fmt.Errorf("Process %v, invalid baseline date: %v", proc.Identifier, baseline)
+				}
+			}
+		}
+
 		for ids, ds := range proc.DataSources {
 			bandExpr, err := ParseBandExpressions(ds.RGBProducts)
 			if err != nil {
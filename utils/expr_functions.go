@@ -0,0 +1,205 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	goeval "github.com/edisonguo/govaluate"
+)
+
+// ExprFunctions are the functions available to band, mask
+// and score expressions. They apply element-wise to bands and
+// to scalars. Nodata pixels are seen as NaN by the functions
+// of expressions calling isnodata, and NaN propagates through
+// every function except isnodata and where.
+var ExprFunctions = map[string]goeval.ExpressionFunction{
+	"sqrt":  exprFunc("sqrt", 1, func(v []float64) float64 { return math.Sqrt(v[0]) }),
+	"log":   exprFunc("log", 1, func(v []float64) float64 { return math.Log(v[0]) }),
+	"log10": exprFunc("log10", 1, func(v []float64) float64 { return math.Log10(v[0]) }),
+	"exp":   exprFunc("exp", 1, func(v []float64) float64 { return math.Exp(v[0]) }),
+	"abs":   exprFunc("abs", 1, func(v []float64) float64 { return math.Abs(v[0]) }),
+	"floor": exprFunc("floor", 1, func(v []float64) float64 { return math.Floor(v[0]) }),
+	"ceil":  exprFunc("ceil", 1, func(v []float64) float64 { return math.Ceil(v[0]) }),
+	"round": exprFunc("round", 1, func(v []float64) float64 { return math.Round(v[0]) }),
+	"min":   exprFunc("min", 2, func(v []float64) float64 { return math.Min(v[0], v[1]) }),
+	"max":   exprFunc("max", 2, func(v []float64) float64 { return math.Max(v[0], v[1]) }),
+	"clamp": exprFunc("clamp", 3, func(v []float64) float64 { return math.Max(v[1], math.Min(v[2], v[0])) }),
+	"where": exprFunc("where", 3, func(v []float64) float64 {
+		if v[0] != 0 && !math.IsNaN(v[0]) {
+			return v[1]
+		}
+		return v[2]
+	}),
+	"isnodata": exprFunc("isnodata", 1, func(v []float64) float64 {
+		if math.IsNaN(v[0]) {
+			return 1
+		}
+		return 0
+	}),
+	"bits": exprFunc("bits", 3, func(v []float64) float64 {
+		if math.IsNaN(v[0]) || v[1] < 0 || v[2] < 1 || v[1]+v[2] > 64 {
+			return math.NaN()
+		}
+		return float64((uint64(int64(v[0])) >> uint(v[1])) & (1<<uint(v[2]) - 1))
+	}),
+	"convert": convertUnits,
+}
+
+// exprNoDataFunc matches the expressions handling nodata
+// pixels themselves
+var exprNoDataFunc = regexp.MustCompile(`\bisnodata\s*\(`)
+
+// HandlesNoData tells whether an expression calls isnodata.
+// Its nodata inputs are evaluated as NaN instead of making
+// the output nodata.
+func HandlesNoData(expression string) bool {
+	return exprNoDataFunc.MatchString(expression)
+}
+
+// exprFunc returns an expression function with nArgs numeric
+// arguments. The function is applied to each element if any
+// argument is a band, in which case it returns a band.
+func exprFunc(name string, nArgs int, f func([]float64) float64) goeval.ExpressionFunction {
+	return func(args ...interface{}) (interface{}, error) {
+		if len(args) != nArgs {
+			return nil, fmt.Errorf("%s() takes %d arguments, got %d", name, nArgs, len(args))
+		}
+
+		size := -1
+		for _, arg := range args {
+			n, err := exprArgLen(name, arg)
+			if err != nil {
+				return nil, err
+			}
+			if n < 0 {
+				continue
+			}
+			if size >= 0 && n != size {
+				return nil, fmt.Errorf("%s() arguments have different sizes %d and %d", name, size, n)
+			}
+			size = n
+		}
+
+		v := make([]float64, nArgs)
+		if size < 0 {
+			for j, arg := range args {
+				v[j] = exprArgValue(arg, 0)
+			}
+			return float32(f(v)), nil
+		}
+
+		out := make([]float32, size)
+		for i := range out {
+			for j, arg := range args {
+				v[j] = exprArgValue(arg, i)
+			}
+			out[i] = float32(f(v))
+		}
+		return out, nil
+	}
+}
+
+// exprArgLen returns the length of a band argument or -1 for
+// a scalar
+func exprArgLen(name string, arg interface{}) (int, error) {
+	switch a := arg.(type) {
+	case []float32:
+		return len(a), nil
+	case []float64:
+		return len(a), nil
+	case []bool:
+		return len(a), nil
+	case float32, float64, bool:
+		return -1, nil
+	default:
+		return -1, fmt.Errorf("%s() argument '%v' is not a number", name, arg)
+	}
+}
+
+func exprArgValue(arg interface{}, i int) float64 {
+	switch a := arg.(type) {
+	case []float32:
+		return float64(a[i])
+	case []float64:
+		return a[i]
+	case []bool:
+		if a[i] {
+			return 1
+		}
+		return 0
+	case float32:
+		return float64(a)
+	case float64:
+		return a
+	case bool:
+		if a {
+			return 1
+		}
+		return 0
+	}
+	return math.NaN()
+}
+
+type unitScale struct {
+	Dimension string
+	Scale     float64
+	Offset    float64
+}
+
+// unitScales maps units to the scale and offset converting
+// them to the base unit of their dimension, i.e. base =
+// value * scale + offset
+var unitScales = map[string]unitScale{
+	"K":    {"temperature", 1, 0},
+	"degC": {"temperature", 1, 273.15},
+	"degF": {"temperature", 5.0 / 9, 459.67 * 5 / 9},
+
+	"m":  {"length", 1, 0},
+	"km": {"length", 1000, 0},
+	"cm": {"length", 0.01, 0},
+	"mm": {"length", 0.001, 0},
+	"ft": {"length", 0.3048, 0},
+	"mi": {"length", 1609.344, 0},
+
+	"Pa":  {"pressure", 1, 0},
+	"hPa": {"pressure", 100, 0},
+	"kPa": {"pressure", 1000, 0},
+
+	"m/s":  {"speed", 1, 0},
+	"km/h": {"speed", 1 / 3.6, 0},
+
+	"1": {"ratio", 1, 0},
+	"%": {"ratio", 0.01, 0},
+}
+
+// convertUnits converts a value between two units of the
+// same dimension, e.g. convert(tas, 'K', 'degC')
+func convertUnits(args ...interface{}) (interface{}, error) {
+	if len(args) != 3 {
+		return nil, fmt.Errorf("convert() takes 3 arguments, got %d", len(args))
+	}
+	from, okFrom := args[1].(string)
+	to, okTo := args[2].(string)
+	if !okFrom || !okTo {
+		return nil, fmt.Errorf("convert() units must be quoted strings such as 'K'")
+	}
+
+	src, found := unitScales[strings.TrimSpace(from)]
+	if !found {
+		return nil, fmt.Errorf("convert() unknown unit '%s'", from)
+	}
+	dst, found := unitScales[strings.TrimSpace(to)]
+	if !found {
+		return nil, fmt.Errorf("convert() unknown unit '%s'", to)
+	}
+	if src.Dimension != dst.Dimension {
+		return nil, fmt.Errorf("convert() cannot convert '%s' to '%s'", from, to)
+	}
+
+	convert := exprFunc("convert", 1, func(v []float64) float64 {
+		return (v[0]*src.Scale + src.Offset - dst.Offset) / dst.Scale
+	})
+	return convert(args[0])
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestExprFunctions(t *testing.T) {
+	nan := float32(math.NaN())
+	tests := []struct {
+		name     string
+		args     []interface{}
+		expected interface{}
+	}{
+		{"sqrt", []interface{}{[]float32{4, 9}}, []float32{2, 3}},
+		{"round", []interface{}{[]float32{-2.5, -1.4, 2.5}}, []float32{-3, -1, 3}},
+		{"clamp", []interface{}{float64(12), float64(0), float64(10)}, float32(10)},
+		{"where", []interface{}{[]bool{true, false}, []float32{1, 2}, float64(0)}, []float32{1, 0}},
+		{"isnodata", []interface{}{[]float32{nan, 3}}, []float32{1, 0}},
+		{"bits", []interface{}{float64(44), float64(2), float64(3)}, float32(3)},
+		{"convert", []interface{}{[]float32{300}, "K", "degC"}, []float32{26.85}},
+		{"convert", []interface{}{float64(50), "%", "1"}, float32(0.5)},
+	}
+
+	for _, test := range tests {
+		out, err := ExprFunctions[test.name](test.args...)
+		if err != nil {
+			t.Errorf("%s%v: %v", test.name, test.args, err)
+			continue
+		}
+		if res, ok := out.([]float32); ok {
+			expected := test.expected.([]float32)
+			for i := range res {
+				if math.Abs(float64(res[i]-expected[i])) > 1e-4 {
+					t.Errorf("%s%v: expected %v, got %v", test.name, test.args, expected, res)
+					break
+				}
+			}
+		} else if !reflect.DeepEqual(out, test.expected) {
+			t.Errorf("%s%v: expected %v, got %v", test.name, test.args, test.expected, out)
+		}
+	}
+
+	if out, _ := ExprFunctions["log"]([]float32{nan}); !math.IsNaN(float64(out.([]float32)[0])) {
+		t.Errorf("expected log to propagate NaN, got %v", out)
+	}
+
+	for _, args := range [][]interface{}{
+		{float64(1), "K", "m"},
+		{float64(1), "K", "furlong"},
+		{float64(1), float64(2), "K"},
+	} {
+		if _, err := ExprFunctions["convert"](args...); err == nil {
+			t.Errorf("expected error for convert%v", args)
+		}
+	}
+	if _, err := ExprFunctions["clamp"](float64(1)); err == nil {
+		t.Errorf("expected error for clamp with one argument")
+	}
+}
+
+func TestParseBandExpressionsFunctions(t *testing.T) {
+	bandExpr, err := ParseBandExpressions([]string{"celsius = convert(tas, 'K', 'degC')", "where(qa == 1 || isnodata(tas), 0, tas)"})
+	if err != nil {
+		t.Fatalf("failed to parse expressions: %v", err)
+	}
+	if expected := []string{"celsius", "where(qa == 1 || isnodata(tas), 0, tas)"}; !reflect.DeepEqual(bandExpr.ExprNames, expected) {
+		t.Errorf("expected names %v, got %v", expected, bandExpr.ExprNames)
+	}
+	if expected := []string{"tas", "qa"}; !reflect.DeepEqual(bandExpr.VarList, expected) {
+		t.Errorf("expected variables %v, got %v", expected, bandExpr.VarList)
+	}
+	if expected := []bool{false, true}; !reflect.DeepEqual(bandExpr.HandlesNoData, expected) {
+		t.Errorf("expected nodata handling %v, got %v", expected, bandExpr.HandlesNoData)
+	}
+
+	for _, band := range []string{"sqrt(a, b)", "convert(a, 'K', 'm')", "a = b = c"} {
+		if _, err := ParseBandExpressions([]string{band}); err == nil {
+			t.Errorf("expected error for %s", band)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/png"
+	"math"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+const legendMargin = 10
+const legendSwatchWidth = 20
+const legendRowHeight = 18
+
+// EncodeLegend draws the legend of a palette as a PNG image.
+// Classified palettes are drawn as a swatch per class with its
+// label, or its value if it has none. Other palettes are drawn
+// as a colour bar with the data values of its ends and middle
+// when the scale parameters allow to compute them.
+func EncodeLegend(palette *Palette, params ScaleParams, width, height int) ([]byte, error) {
+	ramp, err := GradientRGBAPalette(palette)
+	if err != nil {
+		return nil, err
+	}
+	if width <= 0 {
+		width = DefaultLegendWidth
+	}
+	if height <= 0 {
+		height = DefaultLegendHeight
+	}
+	if palette.IsClassified() {
+		if rowsHeight := 2*legendMargin + len(palette.Breaks)*legendRowHeight; rowsHeight > height {
+			height = rowsHeight
+		}
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.ZP, draw.Src)
+	drawer := &font.Drawer{Dst: canvas, Src: image.Black, Face: basicfont.Face7x13}
+	textX := legendMargin + legendSwatchWidth + 6
+
+	if palette.IsClassified() {
+		for i, b := range palette.Breaks {
+			y := legendMargin + i*legendRowHeight
+			swatch := image.Rect(legendMargin, y, legendMargin+legendSwatchWidth, y+legendRowHeight-4)
+			draw.Draw(canvas, swatch, image.NewUniform(ramp[i]), image.ZP, draw.Over)
+
+			label := b.Label
+			if len(label) == 0 {
+				label = formatLegendValue(b.Value)
+			}
+			drawer.Dot = fixed.P(textX, y+legendRowHeight-7)
+			drawer.DrawString(label)
+		}
+	} else {
+		// The highest values are at the top of the bar
+		barHeight := height - 2*legendMargin
+		for y := 0; y < barHeight; y++ {
+			index := 254 - y*254/int(math.Max(1, float64(barHeight-1)))
+			row := image.Rect(legendMargin, legendMargin+y, legendMargin+legendSwatchWidth, legendMargin+y+1)
+			draw.Draw(canvas, row, image.NewUniform(ramp[index]), image.ZP, draw.Over)
+		}
+
+		for _, index := range []int{254, 127, 0} {
+			val, ok := legendValue(params, index)
+			if !ok {
+				break
+			}
+			y := legendMargin + (254-index)*(barHeight-1)/254
+			drawer.Dot = fixed.P(textX, y+5)
+			drawer.DrawString(formatLegendValue(val))
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	err = png.Encode(buf, canvas)
+	return buf.Bytes(), err
+}
+
+// legendValue returns the data value of a scaled value. The
+// values of stretches computed from the rendered images are
+// unknown.
+func legendValue(params ScaleParams, index int) (float64, bool) {
+	t := float64(index) / 254
+	s := params.Stretch
+	if !s.isDefault() {
+		if s.Range != StretchRangeFixed || s.Mode == StretchEqualise || params.Clip <= 0 {
+			return 0, false
+		}
+		switch s.Mode {
+		case StretchLog:
+			t = (math.Pow(10, t) - 1) / 9
+		case StretchSqrt:
+			t = t * t
+		case StretchGamma:
+			t = math.Pow(t, s.Gamma)
+		}
+		return t*params.Clip - params.Offset, true
+	}
+
+	scale := params.Scale
+	if scale <= 0 {
+		if params.Clip <= 0 {
+			scale = 1
+		} else {
+			scale = 254 / params.Clip
+		}
+	}
+	return float64(index)/scale - params.Offset, true
+}
+
+func formatLegendValue(val float64) string {
+	return strconv.FormatFloat(val, 'g', 4, 64)
+}
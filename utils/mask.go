@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	goeval "github.com/edisonguo/govaluate"
+)
+
+// ParseMask compiles the expression of a mask and lists the
+// namespaces it reads. A mask without an expression has its
+// Value compiled into ([ID] & value) > 0 and its BitTests
+// into ([ID] & filter) == value || ... over the ID namespace.
+func ParseMask(mask *Mask) error {
+	exprText := strings.TrimSpace(mask.Expression)
+	if len(exprText) == 0 {
+		legacy, err := legacyMaskExpression(mask)
+		if err != nil {
+			return err
+		}
+		exprText = legacy
+	}
+
+	expr, err := goeval.NewEvaluableExpressionWithFunctions(exprText, ExprFunctions)
+	if err != nil {
+		return fmt.Errorf("invalid mask expression %s: %v", exprText, err)
+	}
+
+	mask.NameSpaces = nil
+	varFound := make(map[string]bool)
+	for _, token := range expr.Tokens() {
+		if token.Kind != goeval.VARIABLE {
+			continue
+		}
+		varName, ok := token.Value.(string)
+		if !ok {
+			return fmt.Errorf("variable token '%v' failed to cast string for mask expression %s", token.Value, exprText)
+		}
+		if !varFound[varName] {
+			varFound[varName] = true
+			mask.NameSpaces = append(mask.NameSpaces, varName)
+		}
+	}
+	if len(mask.NameSpaces) == 0 {
+		return fmt.Errorf("mask expression %s has no variable", exprText)
+	}
+
+	mask.Expression = exprText
+	mask.Expr = expr
+	return nil
+}
+
+func legacyMaskExpression(mask *Mask) (string, error) {
+	if len(strings.TrimSpace(mask.ID)) == 0 {
+		return "", fmt.Errorf("Please specify either mask.expression or mask.id")
+	}
+	nameSpace := "[" + mask.ID + "]"
+
+	if len(mask.Value) > 0 {
+		value, err := strconv.ParseUint(mask.Value, 2, 64)
+		if err != nil {
+			return "", fmt.Errorf("mask.Value %s is not a binary number", mask.Value)
+		}
+		return fmt.Sprintf("(%s & %d) > 0", nameSpace, value), nil
+	}
+
+	if len(mask.BitTests) == 0 {
+		return "", fmt.Errorf("Please specify either mask.Value or mask.BitTests")
+	} else if len(mask.BitTests)%2 != 0 {
+		return "", fmt.Errorf("The entries in mask.BitTests must be in pairs")
+	}
+
+	var tests []string
+	for j := 0; j < len(mask.BitTests); j += 2 {
+		filter, err := strconv.ParseUint(mask.BitTests[j], 2, 64)
+		if err != nil {
+			return "", fmt.Errorf("mask.BitTests filter %s is not a binary number", mask.BitTests[j])
+		}
+		value, err := strconv.ParseUint(mask.BitTests[j+1], 2, 64)
+		if err != nil {
+			return "", fmt.Errorf("mask.BitTests value %s is not a binary number", mask.BitTests[j+1])
+		}
+		tests = append(tests, fmt.Sprintf("(%s & %d) == %d", nameSpace, filter, value))
+	}
+	return strings.Join(tests, " || "), nil
+}
+
+// IsNameSpace tells whether a namespace is read by a mask
+func (mask *Mask) IsNameSpace(nameSpace string) bool {
+	for _, ns := range mask.NameSpaces {
+		if ns == nameSpace {
+			return true
+		}
+	}
+	return false
+}
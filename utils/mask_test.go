@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMask(t *testing.T) {
+	tests := []struct {
+		mask       Mask
+		expression string
+		nameSpaces []string
+	}{
+		{Mask{ID: "pixelquality", Value: "1100"}, "([pixelquality] & 12) > 0", []string{"pixelquality"}},
+		{Mask{ID: "qa", BitTests: []string{"11", "01", "100", "100"}}, "([qa] & 3) == 1 || ([qa] & 4) == 4", []string{"qa"}},
+		{Mask{ID: "qa", Expression: "cloud_prob > 50 || qa == 0"}, "cloud_prob > 50 || qa == 0", []string{"cloud_prob", "qa"}},
+	}
+
+	for _, test := range tests {
+		mask := test.mask
+		if err := ParseMask(&mask); err != nil {
+			t.Errorf("failed to parse mask %v: %v", test.mask, err)
+			continue
+		}
+		if mask.Expression != test.expression {
+			t.Errorf("expected expression %s, got %s", test.expression, mask.Expression)
+		}
+		if !reflect.DeepEqual(mask.NameSpaces, test.nameSpaces) {
+			t.Errorf("expected namespaces %v, got %v", test.nameSpaces, mask.NameSpaces)
+		}
+	}
+
+	for _, mask := range []Mask{
+		{Value: "1"},
+		{ID: "qa"},
+		{ID: "qa", Value: "12"},
+		{ID: "qa", BitTests: []string{"11"}},
+		{Expression: "1 > 0"},
+	} {
+		if err := ParseMask(&mask); err == nil {
+			t.Errorf("expected error for mask %v", mask)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package utils
+
+// #include "gdal.h"
+// #include "ogr_api.h"
+// #include "ogr_srs_api.h"
+// #cgo pkg-config: gdal
+import "C"
+
+import (
+	"fmt"
+	"math"
+	"unsafe"
+)
+
+// gridSnapTol absorbs floating point noise when
+// snapping coordinates onto a pixel grid.
+const gridSnapTol = 1e-6
+
+// NativeGrid describes a source pixel grid expressed
+// in the CRS of a request. OriginX and OriginY are the
+// coordinates of any pixel corner of the grid.
+type NativeGrid struct {
+	OriginX float64
+	OriginY float64
+	ResX    float64
+	ResY    float64
+}
+
+// SnapBBox expands bbox outwards so that its edges fall
+// on the pixel boundaries of the grid. It returns the
+// snapped bbox together with the width and height of
+// the output in pixels.
+func (g *NativeGrid) SnapBBox(bbox []float64) ([]float64, int, int) {
+	xMin := g.OriginX + math.Floor((bbox[0]-g.OriginX)/g.ResX+gridSnapTol)*g.ResX
+	yMin := g.OriginY + math.Floor((bbox[1]-g.OriginY)/g.ResY+gridSnapTol)*g.ResY
+	xMax := g.OriginX + math.Ceil((bbox[2]-g.OriginX)/g.ResX-gridSnapTol)*g.ResX
+	yMax := g.OriginY + math.Ceil((bbox[3]-g.OriginY)/g.ResY-gridSnapTol)*g.ResY
+
+	width := int(math.Round((xMax - xMin) / g.ResX))
+	if width < 1 {
+		width = 1
+		xMax = xMin + g.ResX
+	}
+
+	height := int(math.Round((yMax - yMin) / g.ResY))
+	if height < 1 {
+		height = 1
+		yMax = yMin + g.ResY
+	}
+
+	return []float64{xMin, yMin, xMax, yMax}, width, height
+}
+
+// ReprojectGrid expresses the pixel grid of a dataset,
+// described by its geotransform and projection WKT, in
+// the CRS given by epsg. If the CRSs differ, the grid is
+// linearised around the source pixel under the centre of
+// bbox, which is given in the destination CRS.
+func ReprojectGrid(geot []float64, projWKT string, epsg int, bbox []float64) (*NativeGrid, error) {
+	if len(geot) != 6 || geot[1] == 0 || geot[5] == 0 {
+		return nil, fmt.Errorf("invalid geotransform: %v", geot)
+	}
+
+	if len(projWKT) == 0 {
+		projWKT = WGS84WKT
+	}
+
+	projWKTC := C.CString(projWKT)
+	defer C.free(unsafe.Pointer(projWKTC))
+	srcSRS := C.OSRNewSpatialReference(projWKTC)
+	if srcSRS == nil {
+		return nil, fmt.Errorf("invalid source projection: %v", projWKT)
+	}
+	defer C.OSRDestroySpatialReference(srcSRS)
+
+	dstSRS := C.OSRNewSpatialReference(nil)
+	defer C.OSRDestroySpatialReference(dstSRS)
+	if C.OSRImportFromEPSG(dstSRS, C.int(epsg)) != C.OGRERR_NONE {
+		return nil, fmt.Errorf("invalid EPSG code: %v", epsg)
+	}
+
+	if C.OSRIsSame(srcSRS, dstSRS) != 0 {
+		return &NativeGrid{OriginX: geot[0], OriginY: geot[3], ResX: math.Abs(geot[1]), ResY: math.Abs(geot[5])}, nil
+	}
+
+	toSrc := C.OCTNewCoordinateTransformation(dstSRS, srcSRS)
+	if toSrc == nil {
+		return nil, fmt.Errorf("failed to create coordinate transformation to source projection")
+	}
+	defer C.OCTDestroyCoordinateTransformation(toSrc)
+
+	toDst := C.OCTNewCoordinateTransformation(srcSRS, dstSRS)
+	if toDst == nil {
+		return nil, fmt.Errorf("failed to create coordinate transformation to EPSG:%v", epsg)
+	}
+	defer C.OCTDestroyCoordinateTransformation(toDst)
+
+	cx := C.double((bbox[0] + bbox[2]) / 2)
+	cy := C.double((bbox[1] + bbox[3]) / 2)
+	cz := C.double(0)
+	if C.OCTTransform(toSrc, 1, &cx, &cy, &cz) == 0 {
+		return nil, fmt.Errorf("failed to transform bbox centre into source projection")
+	}
+
+	// Corner of the source pixel containing the centre of
+	// the request followed by its neighbours along both axes
+	col := math.Floor((float64(cx) - geot[0]) / geot[1])
+	row := math.Floor((float64(cy) - geot[3]) / geot[5])
+	x0 := geot[0] + col*geot[1]
+	y0 := geot[3] + row*geot[5]
+
+	xs := []C.double{C.double(x0), C.double(x0 + geot[1]), C.double(x0)}
+	ys := []C.double{C.double(y0), C.double(y0), C.double(y0 + geot[5])}
+	zs := []C.double{0, 0, 0}
+	if C.OCTTransform(toDst, C.int(len(xs)), &xs[0], &ys[0], &zs[0]) == 0 {
+		return nil, fmt.Errorf("failed to transform source pixel into EPSG:%v", epsg)
+	}
+
+	resX := math.Hypot(float64(xs[1])-float64(xs[0]), float64(ys[1])-float64(ys[0]))
+	resY := math.Hypot(float64(xs[2])-float64(xs[0]), float64(ys[2])-float64(ys[0]))
+	if resX == 0 || resY == 0 {
+		return nil, fmt.Errorf("degenerate pixel size after transformation to EPSG:%v", epsg)
+	}
+
+	return &NativeGrid{OriginX: float64(xs[0]), OriginY: float64(ys[0]), ResX: resX, ResY: resY}, nil
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSnapBBox(t *testing.T) {
+	grid := &NativeGrid{OriginX: 100, OriginY: -10, ResX: 0.25, ResY: 0.25}
+
+	bbox, width, height := grid.SnapBBox([]float64{130.1, -30.3, 131.05, -29.5})
+	expected := []float64{130, -30.5, 131.25, -29.5}
+	for i := range expected {
+		if math.Abs(bbox[i]-expected[i]) > 1e-9 {
+			t.Errorf("unexpected snapped bbox: expected %v, actual %v", expected, bbox)
+			break
+		}
+	}
+
+	if width != 5 || height != 4 {
+		t.Errorf("unexpected size: expected (width:5, height:4), actual (width:%v, height:%v)", width, height)
+	}
+
+	// Repeated extracts over the snapped bbox must not grow
+	bbox2, width2, height2 := grid.SnapBBox(bbox)
+	for i := range bbox {
+		if math.Abs(bbox2[i]-bbox[i]) > 1e-9 {
+			t.Errorf("snapping is not idempotent: %v, %v", bbox, bbox2)
+			break
+		}
+	}
+
+	if width2 != width || height2 != height {
+		t.Errorf("snapping is not idempotent: (%v, %v), (%v, %v)", width, height, width2, height2)
+	}
+}
@@ -0,0 +1,201 @@
+package utils
+
+// #include <stdlib.h>
+// #include "netcdf.h"
+// #cgo LDFLAGS: -lnetcdf
+import "C"
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"regexp"
+	"time"
+	"unsafe"
+)
+
+// netCDFFillValue is the default NetCDF fill value of doubles
+const netCDFFillValue = 9.9692099683868690e+36
+
+var netCDFInvalidNameChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// EncodeNetCDFTimeSeries writes time series following the CF
+// conventions for discrete sampling geometries into a NetCDF4
+// file and returns its content. values contains a slice per
+// variable holding len(featureIDs)*len(times) values ordered by
+// feature and then time, or len(times) values if featureIDs is
+// empty. NaN values are written as the fill value.
+func EncodeNetCDFTimeSeries(tempDir string, times []time.Time, featureName string, featureIDs []string, varNames []string, values [][]float64) ([]byte, error) {
+	if len(times) == 0 {
+		return nil, fmt.Errorf("no time series to encode")
+	}
+
+	nFeatures := len(featureIDs)
+	nValues := len(times)
+	if nFeatures > 0 {
+		nValues *= nFeatures
+	}
+	for iv := range values {
+		if len(values[iv]) != nValues {
+			return nil, fmt.Errorf("variable %s has %d values, expected %d", varNames[iv], len(values[iv]), nValues)
+		}
+	}
+
+	tmpFile, err := ioutil.TempFile(tempDir, "gsky_wps_")
+	if err != nil {
+		return nil, fmt.Errorf("error creating NetCDF file: %v", err)
+	}
+	tmpFile.Close()
+	defer os.Remove(tmpFile.Name())
+
+	var cStrings []*C.char
+	defer func() {
+		for _, str := range cStrings {
+			C.free(unsafe.Pointer(str))
+		}
+	}()
+	ncName := func(name string) *C.char {
+		nameC := C.CString(name)
+		cStrings = append(cStrings, nameC)
+		return nameC
+	}
+
+	fileNameC := ncName(tmpFile.Name())
+
+	var ncid C.int
+	if err = ncCheck(C.nc_create(fileNameC, C.NC_NETCDF4|C.NC_CLOBBER, &ncid), "create"); err != nil {
+		return nil, err
+	}
+	isOpen := true
+	defer func() {
+		if isOpen {
+			C.nc_close(ncid)
+		}
+	}()
+
+	if err = ncPutAttText(ncid, C.NC_GLOBAL, "Conventions", "CF-1.6"); err != nil {
+		return nil, err
+	}
+	if err = ncPutAttText(ncid, C.NC_GLOBAL, "featureType", "timeSeries"); err != nil {
+		return nil, err
+	}
+
+	usedNames := map[string]bool{"time": true, "feature_id": true}
+
+	var timeDim, timeVar C.int
+	if err = ncCheck(C.nc_def_dim(ncid, ncName("time"), C.size_t(len(times)), &timeDim), "define time dimension"); err != nil {
+		return nil, err
+	}
+	if err = ncCheck(C.nc_def_var(ncid, ncName("time"), C.NC_DOUBLE, 1, &timeDim, &timeVar), "define time variable"); err != nil {
+		return nil, err
+	}
+	timeAtts := [][]string{{"standard_name", "time"}, {"long_name", "time"}, {"units", "days since 1970-01-01 00:00:00"}, {"calendar", "standard"}, {"axis", "T"}}
+	for _, att := range timeAtts {
+		if err = ncPutAttText(ncid, timeVar, att[0], att[1]); err != nil {
+			return nil, err
+		}
+	}
+
+	dims := []C.int{timeDim}
+	var featVar C.int
+	if nFeatures > 0 {
+		var featDim C.int
+		if err = ncCheck(C.nc_def_dim(ncid, ncName("feature"), C.size_t(nFeatures), &featDim), "define feature dimension"); err != nil {
+			return nil, err
+		}
+		if err = ncCheck(C.nc_def_var(ncid, ncName("feature_id"), C.NC_STRING, 1, &featDim, &featVar), "define feature variable"); err != nil {
+			return nil, err
+		}
+		if err = ncPutAttText(ncid, featVar, "cf_role", "timeseries_id"); err != nil {
+			return nil, err
+		}
+		if err = ncPutAttText(ncid, featVar, "long_name", featureName); err != nil {
+			return nil, err
+		}
+		dims = []C.int{featDim, timeDim}
+	}
+
+	fillValue := C.double(netCDFFillValue)
+	dataVars := make([]C.int, len(varNames))
+	for iv, name := range varNames {
+		varName := netCDFInvalidNameChars.ReplaceAllString(name, "_")
+		if len(varName) == 0 || (varName[0] >= '0' && varName[0] <= '9') {
+			varName = "_" + varName
+		}
+		uniqueName := varName
+		for i := 2; usedNames[uniqueName]; i++ {
+			uniqueName = fmt.Sprintf("%s_%d", varName, i)
+		}
+		usedNames[uniqueName] = true
+
+		if err = ncCheck(C.nc_def_var(ncid, ncName(uniqueName), C.NC_DOUBLE, C.int(len(dims)), &dims[0], &dataVars[iv]), "define variable "+name); err != nil {
+			return nil, err
+		}
+		if err = ncCheck(C.nc_put_att_double(ncid, dataVars[iv], ncName("_FillValue"), C.NC_DOUBLE, 1, &fillValue), "set fill value"); err != nil {
+			return nil, err
+		}
+		if err = ncPutAttText(ncid, dataVars[iv], "long_name", name); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = ncCheck(C.nc_enddef(ncid), "end definitions"); err != nil {
+		return nil, err
+	}
+
+	epoch := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+	timeVals := make([]C.double, len(times))
+	for it, t := range times {
+		timeVals[it] = C.double(t.Sub(epoch).Hours() / 24)
+	}
+	if err = ncCheck(C.nc_put_var_double(ncid, timeVar, &timeVals[0]), "write time variable"); err != nil {
+		return nil, err
+	}
+
+	if nFeatures > 0 {
+		featIDsC := make([]*C.char, nFeatures)
+		for i, id := range featureIDs {
+			featIDsC[i] = ncName(id)
+		}
+		if err = ncCheck(C.nc_put_var_string(ncid, featVar, &featIDsC[0]), "write feature variable"); err != nil {
+			return nil, err
+		}
+	}
+
+	for iv, vals := range values {
+		data := make([]C.double, len(vals))
+		for i, val := range vals {
+			if math.IsNaN(val) {
+				data[i] = fillValue
+			} else {
+				data[i] = C.double(val)
+			}
+		}
+		if err = ncCheck(C.nc_put_var_double(ncid, dataVars[iv], &data[0]), "write variable "+varNames[iv]); err != nil {
+			return nil, err
+		}
+	}
+
+	isOpen = false
+	if err = ncCheck(C.nc_close(ncid), "close"); err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadFile(tmpFile.Name())
+}
+
+func ncCheck(status C.int, operation string) error {
+	if status != C.NC_NOERR {
+		return fmt.Errorf("NetCDF %s error: %s", operation, C.GoString(C.nc_strerror(status)))
+	}
+	return nil
+}
+
+func ncPutAttText(ncid C.int, varid C.int, name string, text string) error {
+	nameC := C.CString(name)
+	defer C.free(unsafe.Pointer(nameC))
+	textC := C.CString(text)
+	defer C.free(unsafe.Pointer(textC))
+	return ncCheck(C.nc_put_att_text(ncid, varid, nameC, C.size_t(len(text)), textC), "write attribute "+name)
+}
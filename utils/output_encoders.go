@@ -66,6 +66,21 @@ func (r *Float32Raster) GetNoData() float64 {
 	return r.NoData
 }
 
+// TypedRaster holds the pixels of the raster types without
+// a dedicated raster, such as Int32 or Float64, as bytes.
+// Its pixels are accessed with NewPixels.
+type TypedRaster struct {
+	NameSpace     string
+	Type          string
+	Data          []uint8
+	Height, Width int
+	NoData        float64
+}
+
+func (r *TypedRaster) GetNoData() float64 {
+	return r.NoData
+}
+
 func EncodePNG(br []*ByteRaster, palette *Palette) ([]byte, error) {
 //fmt.Println(palette)
 	buf := new(bytes.Buffer)
@@ -192,6 +207,28 @@ func ValidateRasterSlice(rs []Raster) (int, int, string, error) {
 				err = fmt.Errorf("Mixed width sizes")
 			}
 
+			if height == 0 {
+				height = t.Height
+			} else if height != t.Height {
+				err = fmt.Errorf("Mixed height sizes")
+			}
+		case *TypedRaster:
+			if _, typeErr := RasterTypeSize(t.Type); typeErr != nil {
+				err = typeErr
+			}
+
+			if rasterType == "" {
+				rasterType = t.Type
+			} else if rasterType != t.Type {
+				err = fmt.Errorf("Mixed types")
+			}
+
+			if width == 0 {
+				width = t.Width
+			} else if width != t.Width {
+				err = fmt.Errorf("Mixed width sizes")
+			}
+
 			if height == 0 {
 				height = t.Height
 			} else if height != t.Height {
@@ -209,7 +246,9 @@ func ValidateRasterSlice(rs []Raster) (int, int, string, error) {
 	return width, height, rasterType, err
 }
 
-var GDALTypes = map[string]C.GDALDataType{"Unkown": 0, "Byte": 1, "UInt16": 2, "Int16": 3,
+// GDALTypes maps raster types to GDAL data types. GDAL
+// stores Int8 as Byte with PIXELTYPE=SIGNEDBYTE.
+var GDALTypes = map[string]C.GDALDataType{"Unkown": 0, "Byte": 1, "Int8": 1, "UInt16": 2, "Int16": 3,
 	"UInt32": 4, "Int32": 5, "Float32": 6, "Float64": 7,
 	"CInt16": 8, "CInt32": 9, "CFloat32": 10, "CFloat64": 11,
 	"TypeCount": 12}
@@ -259,6 +298,9 @@ func EncodeGdalOpen(tempDir string, blockXSize int, blockYSize int, format strin
 	default:
 		return nil, "", fmt.Errorf("Unsupported encoding format: %v", format)
 	}
+	if rType == "Int8" {
+		driverOptions = append(driverOptions, C.CString("PIXELTYPE=SIGNEDBYTE"))
+	}
 
 	for _, opt := range driverOptions {
 		defer C.free(unsafe.Pointer(opt))
@@ -340,6 +382,13 @@ func EncodeGdal(hDstDS C.GDALDatasetH, rs []Raster, xOff int, yOff int) error {
 			C.GDALSetRasterNoDataValue(hBand, C.double(t.NoData))
 			gerr = C.GDALRasterIO(hBand, C.GF_Write, C.int(xOff), C.int(yOff), C.int(t.Width), C.int(t.Height), unsafe.Pointer(&t.Data[0]), C.int(t.Width), C.int(t.Height), C.GDT_Float32, 0, 0)
 
+		case *TypedRaster:
+			if t.NameSpace == "EmptyTile" {
+				continue
+			}
+			C.GDALSetRasterNoDataValue(hBand, C.double(t.NoData))
+			gerr = C.GDALRasterIO(hBand, C.GF_Write, C.int(xOff), C.int(yOff), C.int(t.Width), C.int(t.Height), unsafe.Pointer(&t.Data[0]), C.int(t.Width), C.int(t.Height), GDALTypes[t.Type], 0, 0)
+
 		default:
 			C.GDALClose(hDstDS)
 			return fmt.Errorf("Unsupported gdal data type")
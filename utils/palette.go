@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"encoding/json"
+	"fmt"
 	"image/color"
+	"math"
+	"sort"
+	"strings"
 )
 
 // InterpolateUint8 interpolates the value of a
@@ -22,8 +27,102 @@ func InterpolateColor(a, b color.RGBA, i, sectionLength int) color.RGBA {
 		255}
 }
 
+// ClassBreak is a class of a classified palette. Pixels from
+// Value up to the value of the next break are coloured with
+// Colour. It is written [value, colour, label] in the config,
+// where colour is #RRGGBB, #RRGGBBAA or an RGBA object.
+type ClassBreak struct {
+	Value  float64
+	Colour color.RGBA
+	Label  string
+}
+
+// UnmarshalJSON parses a [value, colour, label] break
+func (b *ClassBreak) UnmarshalJSON(data []byte) error {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("palette break %s must be [value, colour, label]", data)
+	}
+	if len(fields) < 2 || len(fields) > 3 {
+		return fmt.Errorf("palette break %s must be [value, colour, label]", data)
+	}
+
+	if err := json.Unmarshal(fields[0], &b.Value); err != nil {
+		return fmt.Errorf("palette break %s: invalid value", data)
+	}
+
+	var hex string
+	if err := json.Unmarshal(fields[1], &hex); err == nil {
+		colour, err := ParseHexColour(hex)
+		if err != nil {
+			return fmt.Errorf("palette break %s: %v", data, err)
+		}
+		b.Colour = colour
+	} else if err := json.Unmarshal(fields[1], &b.Colour); err != nil {
+		return fmt.Errorf("palette break %s: invalid colour", data)
+	}
+
+	b.Label = ""
+	if len(fields) == 3 {
+		if err := json.Unmarshal(fields[2], &b.Label); err != nil {
+			return fmt.Errorf("palette break %s: invalid label", data)
+		}
+	}
+	return nil
+}
+
+// ParsePalette validates a palette and sets its defaults
+func ParsePalette(palette *Palette) error {
+	palette.Name = strings.ToLower(strings.TrimSpace(palette.Name))
+
+	sources := 0
+	for _, set := range []bool{len(palette.Name) > 0, len(palette.Colours) > 0, len(palette.Breaks) > 0} {
+		if set {
+			sources++
+		}
+	}
+	if sources != 1 {
+		return fmt.Errorf("please specify one of name, colours or breaks")
+	}
+
+	if _, found := Colormaps[palette.Name]; len(palette.Name) > 0 && !found {
+		return fmt.Errorf("unknown colormap: %s", palette.Name)
+	}
+	if palette.Interpolate && len(palette.Colours) == 1 {
+		return fmt.Errorf("interpolated palettes need at least two colours")
+	}
+	if len(palette.Colours) > 256 {
+		return fmt.Errorf("palettes have at most 256 colours")
+	}
+
+	if len(palette.Breaks) > 255 {
+		return fmt.Errorf("classified palettes have at most 255 breaks")
+	}
+	for i := 1; i < len(palette.Breaks); i++ {
+		if palette.Breaks[i].Value <= palette.Breaks[i-1].Value {
+			return fmt.Errorf("palette break values must increase: %v, %v", palette.Breaks[i-1].Value, palette.Breaks[i].Value)
+		}
+	}
+
+	if palette.Alpha == 0 {
+		palette.Alpha = 1
+	}
+	if palette.Alpha < 0 || palette.Alpha > 1 {
+		return fmt.Errorf("palette alpha must be within [0, 1]: %v", palette.Alpha)
+	}
+	return nil
+}
+
+// IsClassified tells whether a palette colours the pixels by
+// classes of data values rather than by scaled values
+func (palette *Palette) IsClassified() bool {
+	return palette != nil && len(palette.Breaks) > 0
+}
+
 // GradientRGBAPalette returns a palette of 256 colors creating an
 // interpolation that goes though a list of provided colours.
+// Named colormaps are always interpolated. The colours of a
+// classified palette are at the indices of their classes.
 func GradientRGBAPalette(palette *Palette) ([]color.RGBA, error) {
 	if palette == nil {
 		return nil, nil
@@ -31,8 +130,44 @@ func GradientRGBAPalette(palette *Palette) ([]color.RGBA, error) {
 
 	ramp := make([]color.RGBA, 256)
 
+	if len(palette.Breaks) > 0 {
+		for i, b := range palette.Breaks {
+			if palette.Reverse {
+				b = palette.Breaks[len(palette.Breaks)-1-i]
+			}
+			ramp[i] = b.Colour
+		}
+		return applyAlpha(ramp, palette.Alpha), nil
+	}
+
+	if len(palette.Name) > 0 {
+		stops, found := Colormaps[strings.ToLower(palette.Name)]
+		if !found {
+			return nil, fmt.Errorf("unknown colormap: %s", palette.Name)
+		}
+		for i := range ramp {
+			pos := float64(i) / float64(len(ramp)-1)
+			if palette.Reverse {
+				pos = 1 - pos
+			}
+			ramp[i] = colormapColour(stops, pos)
+		}
+		return applyAlpha(ramp, palette.Alpha), nil
+	}
+
+	colours := palette.Colours
+	if len(colours) == 0 || palette.Interpolate && len(colours) < 2 {
+		return nil, fmt.Errorf("palette has not enough colours")
+	}
+	if palette.Reverse {
+		colours = make([]color.RGBA, len(palette.Colours))
+		for i, c := range palette.Colours {
+			colours[len(colours)-1-i] = c
+		}
+	}
+
 	if palette.Interpolate {
-		bins := len(palette.Colours) - 1
+		bins := len(colours) - 1
 		sectionLength := 256 / bins
 		bonus := 256 - (sectionLength * bins)
 		bonusArr := make([]int, bins)
@@ -41,14 +176,14 @@ func GradientRGBAPalette(palette *Palette) ([]color.RGBA, error) {
 		}
 
 		index := 0
-		for section, upperColour := range palette.Colours[1:] {
+		for section, upperColour := range colours[1:] {
 			for i := 0; i < sectionLength+bonusArr[section]; i++ {
-				ramp[index] = InterpolateColor(palette.Colours[section], upperColour, i, sectionLength)
+				ramp[index] = InterpolateColor(colours[section], upperColour, i, sectionLength)
 				index++
 			}
 		}
 	} else {
-		bins := len(palette.Colours)
+		bins := len(colours)
 		sectionLength := 256 / bins
 		bonus := 256 - (sectionLength * bins)
 		bonusArr := make([]int, bins)
@@ -57,7 +192,7 @@ func GradientRGBAPalette(palette *Palette) ([]color.RGBA, error) {
 		}
 
 		index := 0
-		for section, colour := range palette.Colours {
+		for section, colour := range colours {
 			for i := 0; i < sectionLength+bonusArr[section]; i++ {
 				ramp[index] = colour
 				index++
@@ -65,5 +200,61 @@ func GradientRGBAPalette(palette *Palette) ([]color.RGBA, error) {
 		}
 	}
 
-	return ramp, nil
+	return applyAlpha(ramp, palette.Alpha), nil
+}
+
+// colormapColour interpolates the colour of a position
+// within the stops of a colormap
+func colormapColour(stops []colormapStop, pos float64) color.RGBA {
+	for i := 1; i < len(stops); i++ {
+		if pos <= stops[i].Pos {
+			lower, upper := stops[i-1], stops[i]
+			t := (pos - lower.Pos) / (upper.Pos - lower.Pos)
+			mix := func(a, b uint8) uint8 { return uint8(float64(a) + t*(float64(b)-float64(a)) + 0.5) }
+			return color.RGBA{mix(lower.Colour.R, upper.Colour.R), mix(lower.Colour.G, upper.Colour.G),
+				mix(lower.Colour.B, upper.Colour.B), 255}
+		}
+	}
+	return stops[len(stops)-1].Colour
+}
+
+// applyAlpha scales the colours of a ramp by the opacity of
+// the palette. The colours are alpha-premultiplied like the
+// images they are drawn into.
+func applyAlpha(ramp []color.RGBA, alpha float64) []color.RGBA {
+	if alpha <= 0 || alpha >= 1 {
+		return ramp
+	}
+	for i, c := range ramp {
+		ramp[i] = color.RGBA{uint8(float64(c.R) * alpha), uint8(float64(c.G) * alpha),
+			uint8(float64(c.B) * alpha), uint8(float64(c.A) * alpha)}
+	}
+	return ramp
+}
+
+// Classify maps the pixels of rasters in data units to the
+// classes of a classified palette. Pixels below the first
+// break are not classified and, like nodata, are 0xFF.
+func Classify(rs []Raster, palette *Palette) ([]*ByteRaster, error) {
+	out := make([]*ByteRaster, len(rs))
+	for ir, r := range rs {
+		pixels, noData, err := rasterPixels(r)
+		if err != nil {
+			return out, err
+		}
+
+		br := &ByteRaster{NoData: r.GetNoData(), Data: make([]uint8, pixels.Len())}
+		br.NameSpace, br.Height, br.Width = RasterInfo(r)
+		for i := range br.Data {
+			val := pixels.Value(i)
+			class := sort.Search(len(palette.Breaks), func(j int) bool { return palette.Breaks[j].Value > val }) - 1
+			if val == noData || math.IsNaN(val) || class < 0 {
+				br.Data[i] = 0xFF
+			} else {
+				br.Data[i] = uint8(class)
+			}
+		}
+		out[ir] = br
+	}
+	return out, nil
 }
@@ -0,0 +1,127 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/color"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestParsePalette(t *testing.T) {
+	var palette Palette
+	err := json.Unmarshal([]byte(`{"breaks": [[0, "#0000ff", "water"], [10, {"R": 0, "G": 255, "B": 0, "A": 255}], [20.5, "#ff000080", "urban"]], "reverse": true}`), &palette)
+	if err != nil {
+		t.Fatalf("failed to unmarshal palette: %v", err)
+	}
+	if err := ParsePalette(&palette); err != nil {
+		t.Fatalf("failed to parse palette: %v", err)
+	}
+
+	expected := []ClassBreak{
+		{0, color.RGBA{0, 0, 255, 255}, "water"},
+		{10, color.RGBA{0, 255, 0, 255}, ""},
+		{20.5, color.RGBA{255, 0, 0, 128}, "urban"},
+	}
+	if !reflect.DeepEqual(palette.Breaks, expected) || palette.Alpha != 1 {
+		t.Errorf("expected breaks %v, got %v", expected, palette.Breaks)
+	}
+
+	ramp, _ := GradientRGBAPalette(&palette)
+	if ramp[0] != expected[2].Colour || ramp[2] != expected[0].Colour {
+		t.Errorf("expected reversed class colours, got %v", ramp[:3])
+	}
+
+	for _, p := range []Palette{
+		{},
+		{Name: "nonexistent"},
+		{Name: "viridis", Colours: []color.RGBA{{0, 0, 0, 255}}},
+		{Colours: []color.RGBA{{0, 0, 0, 255}}, Interpolate: true},
+		{Breaks: []ClassBreak{{Value: 2}, {Value: 1}}},
+		{Name: "viridis", Alpha: 2},
+	} {
+		if err := ParsePalette(&p); err == nil {
+			t.Errorf("expected error for palette %+v", p)
+		}
+	}
+
+	if err := json.Unmarshal([]byte(`{"breaks": [[0, "blue"]]}`), &palette); err == nil {
+		t.Errorf("expected error for an invalid break colour")
+	}
+}
+
+func TestNamedPalette(t *testing.T) {
+	palette := &Palette{Name: "Viridis", Alpha: 0.5}
+	if err := ParsePalette(palette); err != nil {
+		t.Fatalf("failed to parse palette: %v", err)
+	}
+
+	ramp, err := GradientRGBAPalette(palette)
+	if err != nil {
+		t.Fatalf("failed to build ramp: %v", err)
+	}
+	if expected := (color.RGBA{0x22, 0x00, 0x2A, 0x7F}); ramp[0] != expected {
+		t.Errorf("expected %v, got %v", expected, ramp[0])
+	}
+
+	palette.Reverse = true
+	palette.Alpha = 1
+	ramp, _ = GradientRGBAPalette(palette)
+	if expected := (color.RGBA{0xFD, 0xE7, 0x25, 0xFF}); ramp[0] != expected {
+		t.Errorf("expected %v, got %v", expected, ramp[0])
+	}
+}
+
+func TestClassify(t *testing.T) {
+	palette := &Palette{Breaks: []ClassBreak{{Value: 0}, {Value: 10}, {Value: 20}}}
+	in := []Raster{&Float32Raster{Data: []float32{-5, 0, 9.5, 10, 100, -9999}, NoData: -9999, Height: 2, Width: 3}}
+
+	out, err := Classify(in, palette)
+	if err != nil {
+		t.Fatalf("failed to classify: %v", err)
+	}
+	if expected := []uint8{0xFF, 0, 0, 1, 2, 0xFF}; !reflect.DeepEqual(out[0].Data, expected) {
+		t.Errorf("expected %v, got %v", expected, out[0].Data)
+	}
+	if out[0].Width != 3 || out[0].Height != 2 {
+		t.Errorf("unexpected size %dx%d", out[0].Width, out[0].Height)
+	}
+}
+
+func TestEncodeLegend(t *testing.T) {
+	palettes := []*Palette{
+		{Name: "terrain"},
+		{Breaks: make([]ClassBreak, 30)},
+	}
+	for i := range palettes[1].Breaks {
+		palettes[1].Breaks[i].Value = float64(i)
+	}
+
+	for _, palette := range palettes {
+		b, err := EncodeLegend(palette, ScaleParams{Clip: 1000}, 160, 320)
+		if err != nil {
+			t.Fatalf("failed to encode legend: %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("failed to decode legend: %v", err)
+		}
+
+		// The classified legend grows to fit its classes
+		height := 320
+		if palette.IsClassified() {
+			height = 2*legendMargin + 30*legendRowHeight
+		}
+		if bounds := img.Bounds(); bounds.Dx() != 160 || bounds.Dy() != height {
+			t.Errorf("unexpected legend size %v", bounds)
+		}
+	}
+
+	if val, ok := legendValue(ScaleParams{Offset: 10, Clip: 1000}, 254); !ok || val != 990 {
+		t.Errorf("expected legend value 990, got %v", val)
+	}
+	if _, ok := legendValue(ScaleParams{Stretch: &Stretch{Mode: StretchLinear, Range: StretchRangeMinMax}}, 254); ok {
+		t.Errorf("expected no legend value for data-dependent stretches")
+	}
+}
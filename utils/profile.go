@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+)
+
+// earthMeanRadius is the mean radius of the Earth in metres
+const earthMeanRadius = 6371008.8
+
+// ProfileSample is a point sampled along a line. Distance
+// is measured in metres from the start of the line.
+type ProfileSample struct {
+	Distance float64
+	Lon      float64
+	Lat      float64
+}
+
+// LineStringCoordinates returns the longitude and latitude
+// of the vertices of a GeoJSON LineString geometry
+func LineStringCoordinates(geomJSON []byte) ([][]float64, error) {
+	var line struct {
+		Type        string      `json:"type"`
+		Coordinates [][]float64 `json:"coordinates"`
+	}
+	if err := json.Unmarshal(geomJSON, &line); err != nil {
+		return nil, fmt.Errorf("invalid LineString: %v", err)
+	}
+	if line.Type != "LineString" {
+		return nil, fmt.Errorf("expected a LineString, got %s", line.Type)
+	}
+	if len(line.Coordinates) < 2 {
+		return nil, fmt.Errorf("a LineString requires at least two positions")
+	}
+	for _, pos := range line.Coordinates {
+		if len(pos) < 2 {
+			return nil, fmt.Errorf("invalid LineString position: %v", pos)
+		}
+	}
+	return line.Coordinates, nil
+}
+
+// SampleLineString returns points spaced every spacing metres
+// along a line made of longitude and latitude vertices. The
+// first and last vertices are always sampled. Positions are
+// interpolated linearly within each segment whereas distances
+// are great circle distances.
+func SampleLineString(coords [][]float64, spacing float64) ([]ProfileSample, error) {
+	if spacing <= 0 {
+		return nil, fmt.Errorf("invalid profile spacing: %v", spacing)
+	}
+	if len(coords) == 0 {
+		return nil, fmt.Errorf("empty line")
+	}
+
+	samples := []ProfileSample{{Distance: 0, Lon: coords[0][0], Lat: coords[0][1]}}
+	segStart := 0.0
+	next := spacing
+	for i := 1; i < len(coords); i++ {
+		lon0, lat0 := coords[i-1][0], coords[i-1][1]
+		lon1, lat1 := coords[i][0], coords[i][1]
+		segLen := haversineDistance(lon0, lat0, lon1, lat1)
+
+		for ; next < segStart+segLen; next += spacing {
+			alpha := (next - segStart) / segLen
+			samples = append(samples, ProfileSample{Distance: next, Lon: lon0 + alpha*(lon1-lon0), Lat: lat0 + alpha*(lat1-lat0)})
+		}
+		segStart += segLen
+	}
+
+	last := coords[len(coords)-1]
+	if segStart > samples[len(samples)-1].Distance {
+		samples = append(samples, ProfileSample{Distance: segStart, Lon: last[0], Lat: last[1]})
+	}
+	return samples, nil
+}
+
+// ProfileSampleCount returns the number of samples taken by
+// SampleLineString without allocating them
+func ProfileSampleCount(coords [][]float64, spacing float64) int {
+	if spacing <= 0 || len(coords) == 0 {
+		return 0
+	}
+
+	length := 0.0
+	for i := 1; i < len(coords); i++ {
+		length += haversineDistance(coords[i-1][0], coords[i-1][1], coords[i][0], coords[i][1])
+	}
+	count := int(math.Ceil(length/spacing)) + 1
+	if length == 0 {
+		count = 1
+	}
+	return count
+}
+
+func haversineDistance(lon0, lat0, lon1, lat1 float64) float64 {
+	const rad = math.Pi / 180
+	dLat := (lat1 - lat0) * rad
+	dLon := (lon1 - lon0) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat0*rad)*math.Cos(lat1*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthMeanRadius * math.Asin(math.Min(1, math.Sqrt(a)))
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSampleLineString(t *testing.T) {
+	coords, err := LineStringCoordinates([]byte(`{"type":"LineString","coordinates":[[149,-35],[149,-34.99],[149.01,-34.99]]}`))
+	if err != nil {
+		t.Fatalf("failed to parse LineString: %v", err)
+	}
+
+	samples, err := SampleLineString(coords, 250)
+	if err != nil {
+		t.Fatalf("failed to sample LineString: %v", err)
+	}
+
+	length := haversineDistance(149, -35, 149, -34.99) + haversineDistance(149, -34.99, 149.01, -34.99)
+	if len(samples) != ProfileSampleCount(coords, 250) {
+		t.Errorf("expected %d samples, got %d", ProfileSampleCount(coords, 250), len(samples))
+	}
+
+	for i, s := range samples[:len(samples)-1] {
+		if math.Abs(s.Distance-float64(i)*250) > 1e-6 {
+			t.Errorf("sample %d: expected distance %f, got %f", i, float64(i)*250, s.Distance)
+		}
+	}
+
+	last := samples[len(samples)-1]
+	if math.Abs(last.Distance-length) > 1e-6 || last.Lon != 149.01 || last.Lat != -34.99 {
+		t.Errorf("unexpected last sample: %+v", last)
+	}
+
+	// The fifth sample lies on the first segment, 1000m north of its start
+	if samples[4].Lon != 149 || math.Abs(haversineDistance(149, -35, samples[4].Lon, samples[4].Lat)-1000) > 1 {
+		t.Errorf("unexpected sample position: %+v", samples[4])
+	}
+
+	if _, err := LineStringCoordinates([]byte(`{"type":"Point","coordinates":[149,-35]}`)); err == nil {
+		t.Errorf("expected error for a Point geometry")
+	}
+	if _, err := SampleLineString(coords, 0); err == nil {
+		t.Errorf("expected error for a zero spacing")
+	}
+}
@@ -0,0 +1,73 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QualityScoreDays is the variable of a score expression
+// holding the number of days between an observation and
+// the target date
+const QualityScoreDays = "days"
+
+// QualityScore ranks the observations of a pixel to build
+// best pixel mosaics. Expression is evaluated over the
+// namespaces of an auxiliary band of the granules, such as
+// a cloud probability, and over days. The observation with
+// the highest score wins. TargetDate defaults to the date
+// of the request.
+type QualityScore struct {
+	Expression string `json:"expression"`
+	DataSource string `json:"data_source"`
+	TargetDate string `json:"target_date"`
+	Expr       *BandExpressions
+	NameSpaces []string
+	Target     *time.Time
+}
+
+// ParseQualityScore parses the expression and the target
+// date of a quality score
+func ParseQualityScore(score *QualityScore) error {
+	if len(strings.TrimSpace(score.Expression)) == 0 {
+		return fmt.Errorf("score expression is empty")
+	}
+
+	expr, err := ParseBandExpressions([]string{score.Expression})
+	if err != nil {
+		return fmt.Errorf("invalid score expression %s: %v", score.Expression, err)
+	}
+	if len(expr.VarList) == 0 {
+		return fmt.Errorf("score expression %s has no variable", score.Expression)
+	}
+	score.Expr = expr
+
+	score.NameSpaces = nil
+	for _, v := range expr.VarList {
+		if v != QualityScoreDays {
+			score.NameSpaces = append(score.NameSpaces, v)
+		}
+	}
+
+	score.Target = nil
+	if len(strings.TrimSpace(score.TargetDate)) > 0 {
+		target, err := ParseWPSDate(score.TargetDate)
+		if err != nil {
+			return fmt.Errorf("invalid score target date: %s", score.TargetDate)
+		}
+		score.Target = &target
+	}
+	return nil
+}
+
+// TargetTime returns the target date of a score or the
+// requested date if the score has none
+func (score *QualityScore) TargetTime(requested *time.Time) time.Time {
+	if score != nil && score.Target != nil {
+		return *score.Target
+	}
+	if requested == nil {
+		return time.Time{}
+	}
+	return *requested
+}
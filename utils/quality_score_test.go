@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseQualityScore(t *testing.T) {
+	score := &QualityScore{Expression: "100 - cloud_prob - days", TargetDate: "2018-01-15"}
+	if err := ParseQualityScore(score); err != nil {
+		t.Fatalf("failed to parse score: %v", err)
+	}
+	if !reflect.DeepEqual(score.NameSpaces, []string{"cloud_prob"}) {
+		t.Errorf("unexpected score namespaces: %v", score.NameSpaces)
+	}
+
+	requested := time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC)
+	if target := score.TargetTime(&requested); !target.Equal(time.Date(2018, time.January, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected target date: %v", target)
+	}
+
+	score = &QualityScore{Expression: "days * -1"}
+	if err := ParseQualityScore(score); err != nil {
+		t.Fatalf("failed to parse score: %v", err)
+	}
+	if len(score.NameSpaces) != 0 || !score.TargetTime(&requested).Equal(requested) {
+		t.Errorf("unexpected score: %+v", score)
+	}
+
+	for _, invalid := range []*QualityScore{{}, {Expression: "1 + 2"}, {Expression: "days", TargetDate: "soon"}} {
+		if err := ParseQualityScore(invalid); err == nil {
+			t.Errorf("expected error for score %+v", invalid)
+		}
+	}
+}
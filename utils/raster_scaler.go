@@ -5,12 +5,17 @@ import (
 )
 
 type ScaleParams struct {
-	Offset float64
-	Scale  float64
-	Clip   float64
+	Offset  float64
+	Scale   float64
+	Clip    float64
+	Stretch *Stretch
 }
 
 func scale(r Raster, params ScaleParams) (*ByteRaster, error) {
+	if !params.Stretch.isDefault() {
+		return stretch(r, params)
+	}
+
 	scale := float32(params.Scale)
 	if scale <= 0.0 {
 		if params.Clip <= 0.0 {
@@ -105,6 +110,33 @@ func scale(r Raster, params ScaleParams) (*ByteRaster, error) {
 		}
 		return out, nil
 
+	case *TypedRaster:
+		pixels, err := NewPixels(t.Type, t.Data)
+		if err != nil {
+			return &ByteRaster{}, err
+		}
+
+		out := &ByteRaster{NameSpace: t.NameSpace, NoData: t.NoData, Data: make([]uint8, t.Height*t.Width), Width: t.Width, Height: t.Height}
+		noData := CastValue(t.Type, t.NoData)
+		offset := params.Offset
+		clip := params.Clip
+		for i := 0; i < pixels.Len(); i++ {
+			value := pixels.Value(i)
+			if value == noData {
+				out.Data[i] = 0xFF
+			} else {
+				value += offset
+				if value > clip {
+					value = clip
+				}
+				if value < 0 {
+					value = 0
+				}
+				out.Data[i] = uint8(float32(value) * scale)
+			}
+		}
+		return out, nil
+
 	default:
 		return &ByteRaster{}, fmt.Errorf("Raster type not implemented")
 	}
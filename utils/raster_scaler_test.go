@@ -143,9 +143,34 @@ func testFloat32Raster(t *testing.T) {
 	assert(t, out[0], expOut, err)
 }
 
+func testTypedRaster(t *testing.T) {
+	inRaster := make([]Raster, 1)
+
+	data, _ := NewNoDataBuffer("Float64", -9999, 3)
+	pixels, _ := NewPixels("Float64", data)
+	pixels.SetValue(0, 1)
+	pixels.SetValue(1, 2)
+	inRaster[0] = &TypedRaster{Type: "Float64", Data: data, Height: 3, Width: 1, NoData: -9999}
+	sp := ScaleParams{Offset: 3, Scale: 2, Clip: 1000}
+	expOut := &ByteRaster{Data: []uint8{uint8(8), uint8(10), uint8(0xFF)}}
+	out, err := Scale(inRaster, sp)
+	assert(t, out[0], expOut, err)
+
+	data, _ = NewNoDataBuffer("Int32", 0, 2)
+	pixels, _ = NewPixels("Int32", data)
+	pixels.SetValue(0, -100)
+	pixels.SetValue(1, 100000)
+	inRaster[0] = &TypedRaster{Type: "Int32", Data: data, Height: 2, Width: 1, NoData: 0}
+	sp = ScaleParams{Offset: 0, Scale: 0, Clip: 100000}
+	expOut = &ByteRaster{Data: []uint8{uint8(0), uint8(254)}}
+	out, err = Scale(inRaster, sp)
+	assert(t, out[0], expOut, err)
+}
+
 func TestScale(t *testing.T) {
 	testByteRaster(t)
 	testInt16Raster(t)
 	testUInt16Raster(t)
 	testFloat32Raster(t)
+	testTypedRaster(t)
 }
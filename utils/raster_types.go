@@ -0,0 +1,152 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// RasterTypeSizes maps the raster types supported by the
+// pipelines to the size in bytes of their pixels. Int8 is
+// carried by GDAL as Byte with PIXELTYPE=SIGNEDBYTE.
+var RasterTypeSizes = map[string]int{
+	"Byte":    1,
+	"Int8":    1,
+	"Int16":   2,
+	"UInt16":  2,
+	"Int32":   4,
+	"UInt32":  4,
+	"Float32": 4,
+	"Float64": 8,
+}
+
+// RasterTypeSize returns the size in bytes of the pixels
+// of a raster type
+func RasterTypeSize(rType string) (int, error) {
+	size, found := RasterTypeSizes[rType]
+	if !found {
+		return -1, fmt.Errorf("Unsupported raster type %s", rType)
+	}
+	return size, nil
+}
+
+// IsIntegerRasterType tells whether the pixels of a raster
+// type are integers, which can hold bit masks
+func IsIntegerRasterType(rType string) bool {
+	switch rType {
+	case "Float32", "Float64":
+		return false
+	}
+	_, found := RasterTypeSizes[rType]
+	return found
+}
+
+// Pixels gives typed access to the pixels of a raster of any
+// supported type. Values are exchanged as float64, which
+// holds every value of the supported types exactly.
+type Pixels interface {
+	Len() int
+	Value(i int) float64
+	SetValue(i int, value float64)
+}
+
+type bytePixels []uint8
+type int8Pixels []int8
+type int16Pixels []int16
+type uint16Pixels []uint16
+type int32Pixels []int32
+type uint32Pixels []uint32
+type float32Pixels []float32
+type float64Pixels []float64
+
+func (p bytePixels) Len() int                      { return len(p) }
+func (p bytePixels) Value(i int) float64           { return float64(p[i]) }
+func (p bytePixels) SetValue(i int, value float64) { p[i] = uint8(value) }
+
+func (p int8Pixels) Len() int                      { return len(p) }
+func (p int8Pixels) Value(i int) float64           { return float64(p[i]) }
+func (p int8Pixels) SetValue(i int, value float64) { p[i] = int8(value) }
+
+func (p int16Pixels) Len() int                      { return len(p) }
+func (p int16Pixels) Value(i int) float64           { return float64(p[i]) }
+func (p int16Pixels) SetValue(i int, value float64) { p[i] = int16(value) }
+
+func (p uint16Pixels) Len() int                      { return len(p) }
+func (p uint16Pixels) Value(i int) float64           { return float64(p[i]) }
+func (p uint16Pixels) SetValue(i int, value float64) { p[i] = uint16(value) }
+
+func (p int32Pixels) Len() int                      { return len(p) }
+func (p int32Pixels) Value(i int) float64           { return float64(p[i]) }
+func (p int32Pixels) SetValue(i int, value float64) { p[i] = int32(value) }
+
+func (p uint32Pixels) Len() int                      { return len(p) }
+func (p uint32Pixels) Value(i int) float64           { return float64(p[i]) }
+func (p uint32Pixels) SetValue(i int, value float64) { p[i] = uint32(value) }
+
+func (p float32Pixels) Len() int                      { return len(p) }
+func (p float32Pixels) Value(i int) float64           { return float64(p[i]) }
+func (p float32Pixels) SetValue(i int, value float64) { p[i] = float32(value) }
+
+func (p float64Pixels) Len() int                      { return len(p) }
+func (p float64Pixels) Value(i int) float64           { return p[i] }
+func (p float64Pixels) SetValue(i int, value float64) { p[i] = value }
+
+// NewPixels views the bytes of a raster as the pixels of its
+// type. The pixels share the memory of data.
+func NewPixels(rType string, data []byte) (Pixels, error) {
+	size, err := RasterTypeSize(rType)
+	if err != nil {
+		return nil, err
+	}
+
+	header := *(*reflect.SliceHeader)(unsafe.Pointer(&data))
+	header.Len /= size
+	header.Cap /= size
+
+	switch rType {
+	case "Byte":
+		return bytePixels(data), nil
+	case "Int8":
+		return int8Pixels(*(*[]int8)(unsafe.Pointer(&header))), nil
+	case "Int16":
+		return int16Pixels(*(*[]int16)(unsafe.Pointer(&header))), nil
+	case "UInt16":
+		return uint16Pixels(*(*[]uint16)(unsafe.Pointer(&header))), nil
+	case "Int32":
+		return int32Pixels(*(*[]int32)(unsafe.Pointer(&header))), nil
+	case "UInt32":
+		return uint32Pixels(*(*[]uint32)(unsafe.Pointer(&header))), nil
+	case "Float32":
+		return float32Pixels(*(*[]float32)(unsafe.Pointer(&header))), nil
+	default:
+		return float64Pixels(*(*[]float64)(unsafe.Pointer(&header))), nil
+	}
+}
+
+// NewNoDataBuffer returns the bytes of size pixels of a
+// raster type set to the nodata value
+func NewNoDataBuffer(rType string, noData float64, size int) ([]byte, error) {
+	pixelSize, err := RasterTypeSize(rType)
+	if err != nil {
+		return []byte{}, err
+	}
+
+	data := make([]byte, size*pixelSize)
+	pixels, _ := NewPixels(rType, data)
+	for i := 0; i < size; i++ {
+		pixels.SetValue(i, noData)
+	}
+	return data, nil
+}
+
+// CastValue returns a value as stored in a pixel of a raster
+// type, such as the nodata value of Float32 rasters
+func CastValue(rType string, value float64) float64 {
+	size, err := RasterTypeSize(rType)
+	if err != nil {
+		return value
+	}
+	pixels, _ := NewPixels(rType, make([]byte, size))
+	pixels.SetValue(0, value)
+	return pixels.Value(0)
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestPixels(t *testing.T) {
+	values := []float64{-3, 0, 42}
+	for rType, size := range RasterTypeSizes {
+		data, err := NewNoDataBuffer(rType, 7, len(values))
+		if err != nil {
+			t.Fatalf("%s: failed to create buffer: %v", rType, err)
+		}
+		if len(data) != size*len(values) {
+			t.Fatalf("%s: expected %d bytes, got %d", rType, size*len(values), len(data))
+		}
+
+		pixels, err := NewPixels(rType, data)
+		if err != nil {
+			t.Fatalf("%s: failed to view pixels: %v", rType, err)
+		}
+		if pixels.Len() != len(values) || pixels.Value(1) != 7 {
+			t.Errorf("%s: unexpected nodata pixels", rType)
+		}
+
+		for i, val := range values {
+			pixels.SetValue(i, CastValue(rType, val))
+		}
+		if pixels.Value(2) != 42 {
+			t.Errorf("%s: expected 42, got %v", rType, pixels.Value(2))
+		}
+	}
+
+	if IsIntegerRasterType("Float64") || !IsIntegerRasterType("Int32") || IsIntegerRasterType("CInt16") {
+		t.Errorf("unexpected integer raster types")
+	}
+	if _, err := RasterTypeSize("CFloat32"); err == nil {
+		t.Errorf("expected error for unsupported raster type")
+	}
+	if val := CastValue("Float32", 1e20); val == 1e20 || float32(val) != float32(1e20) {
+		t.Errorf("expected 1e20 rounded to float32, got %v", val)
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ResamplingAliases maps the resampling names accepted in the
+// configuration files and OWS requests to the names understood
+// by the GDAL workers. The WCS 1.0.0 interpolation method names
+// are accepted as aliases of their GDAL counterparts.
+var ResamplingAliases = map[string]string{
+	"none":              "near",
+	"near":              "near",
+	"nearest":           "near",
+	"nearest neighbor":  "near",
+	"nearest neighbour": "near",
+	"bilinear":          "bilinear",
+	"cubic":             "cubic",
+	"bicubic":           "cubic",
+	"average":           "average",
+	"mode":              "mode",
+	"min":               "min",
+	"max":               "max",
+	"median":            "median",
+}
+
+// ParseResampling validates a resampling name and returns
+// the name of the corresponding GDAL warp kernel. An empty
+// name is valid and leaves the choice to the worker.
+func ParseResampling(name string) (string, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) == 0 {
+		return "", nil
+	}
+
+	alg, found := ResamplingAliases[name]
+	if !found {
+		return "", fmt.Errorf("unsupported resampling method: %s", name)
+	}
+	return alg, nil
+}
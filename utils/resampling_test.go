@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestParseResampling(t *testing.T) {
+	cases := map[string]string{
+		"":                 "",
+		"near":             "near",
+		"nearest neighbor": "near",
+		"Bilinear":         "bilinear",
+		"bicubic":          "cubic",
+		"median":           "median",
+	}
+
+	for name, expected := range cases {
+		alg, err := ParseResampling(name)
+		if err != nil {
+			t.Errorf("failed to parse resampling %q: %v", name, err)
+			continue
+		}
+		if alg != expected {
+			t.Errorf("resampling %q: expected %q, got %q", name, expected, alg)
+		}
+	}
+
+	if _, err := ParseResampling("lanczos2"); err == nil {
+		t.Errorf("expected error for unsupported resampling method")
+	}
+}
@@ -0,0 +1,243 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Stretch modes mapping the pixel values within the stretch
+// range to the [0-254] range of the rendered images
+const (
+	StretchLinear   = "linear"
+	StretchLog      = "log"
+	StretchSqrt     = "sqrt"
+	StretchGamma    = "gamma"
+	StretchEqualise = "equalise"
+)
+
+// Stretch ranges. The fixed range is set by the offset and
+// clip values of the layer. The minmax and percentile ranges
+// are computed from the valid pixels of each band of the
+// rendered image. The statistics range is computed from the
+// band statistics of the whole collection, so all the tiles
+// of a layer are stretched alike.
+const (
+	StretchRangeFixed      = "fixed"
+	StretchRangeMinMax     = "minmax"
+	StretchRangePercentile = "percentile"
+	StretchRangeStatistics = "statistics"
+)
+
+var stretchModes = map[string]bool{
+	StretchLinear:   true,
+	StretchLog:      true,
+	StretchSqrt:     true,
+	StretchGamma:    true,
+	StretchEqualise: true,
+}
+
+var stretchRanges = map[string]bool{
+	StretchRangeFixed:      true,
+	StretchRangeMinMax:     true,
+	StretchRangePercentile: true,
+	StretchRangeStatistics: true,
+}
+
+// Stretch configures the contrast stretch of a layer. Gamma
+// defaults to 1 and Percentiles to [2, 98]. Statistics are the
+// band statistics of the statistics range, loaded from MAS.
+type Stretch struct {
+	Mode        string                    `json:"mode"`
+	Range       string                    `json:"range"`
+	Gamma       float64                   `json:"gamma"`
+	Percentiles []float64                 `json:"percentiles"`
+	Statistics  map[string]BandStatistics `json:"-"`
+}
+
+// BandStatistics are the statistics of the valid pixels of a
+// band over a collection, aggregated by MAS from the statistics
+// of each dataset recorded by the crawler
+type BandStatistics struct {
+	NameSpace string  `json:"namespace"`
+	Min       float64 `json:"min"`
+	Max       float64 `json:"max"`
+	Mean      float64 `json:"mean"`
+	StdDev    float64 `json:"stddev"`
+}
+
+// ParseStretch validates a stretch and sets its defaults
+func ParseStretch(s *Stretch) error {
+	s.Mode = strings.ToLower(strings.TrimSpace(s.Mode))
+	if len(s.Mode) == 0 {
+		s.Mode = StretchLinear
+	}
+	if !stretchModes[s.Mode] {
+		return fmt.Errorf("unsupported stretch mode: %s", s.Mode)
+	}
+
+	s.Range = strings.ToLower(strings.TrimSpace(s.Range))
+	if len(s.Range) == 0 {
+		s.Range = StretchRangeFixed
+	}
+	if !stretchRanges[s.Range] {
+		return fmt.Errorf("unsupported stretch range: %s", s.Range)
+	}
+
+	if s.Gamma == 0 {
+		s.Gamma = 1
+	}
+	if s.Gamma < 0 {
+		return fmt.Errorf("stretch gamma must be positive: %v", s.Gamma)
+	}
+
+	if len(s.Percentiles) == 0 {
+		s.Percentiles = []float64{2, 98}
+	}
+	if len(s.Percentiles) != 2 || s.Percentiles[0] < 0 || s.Percentiles[1] > 100 || s.Percentiles[0] >= s.Percentiles[1] {
+		return fmt.Errorf("stretch percentiles must be two increasing values within [0, 100]: %v", s.Percentiles)
+	}
+	return nil
+}
+
+// isDefault tells whether a stretch is the linear stretch of
+// the fixed range, which is done by scale
+func (s *Stretch) isDefault() bool {
+	return s == nil || s.Mode == StretchLinear && s.Range == StretchRangeFixed
+}
+
+// rasterPixels returns the pixels of a raster and its nodata
+// value as stored in the pixels
+func rasterPixels(r Raster) (Pixels, float64, error) {
+	switch t := r.(type) {
+	case *ByteRaster:
+		return bytePixels(t.Data), CastValue("Byte", t.NoData), nil
+	case *Int16Raster:
+		return int16Pixels(t.Data), CastValue("Int16", t.NoData), nil
+	case *UInt16Raster:
+		return uint16Pixels(t.Data), CastValue("UInt16", t.NoData), nil
+	case *Float32Raster:
+		return float32Pixels(t.Data), CastValue("Float32", t.NoData), nil
+	case *TypedRaster:
+		pixels, err := NewPixels(t.Type, t.Data)
+		return pixels, CastValue(t.Type, t.NoData), err
+	default:
+		return nil, 0, fmt.Errorf("Raster type not implemented")
+	}
+}
+
+// stretch maps the pixels of a raster to bytes. The values
+// are normalised to [0, 1] within the stretch range, then
+// transformed by the stretch mode and scaled to [0-254].
+// Nodata pixels are 0xFF.
+func stretch(r Raster, params ScaleParams) (*ByteRaster, error) {
+	pixels, noData, err := rasterPixels(r)
+	if err != nil {
+		return &ByteRaster{}, err
+	}
+
+	var valid []float64
+	for i := 0; i < pixels.Len(); i++ {
+		if val := pixels.Value(i); val != noData && !math.IsNaN(val) && !math.IsInf(val, 0) {
+			valid = append(valid, val)
+		}
+	}
+	sort.Float64s(valid)
+
+	s := params.Stretch
+	nameSpace, height, width := RasterInfo(r)
+	stats, hasStats := s.Statistics[nameSpace]
+
+	var lo, hi float64
+	switch {
+	case len(valid) == 0:
+	case s.Range == StretchRangeStatistics && hasStats:
+		lo = stats.percentile(s.Percentiles[0])
+		hi = stats.percentile(s.Percentiles[1])
+	case s.Range == StretchRangePercentile:
+		lo = percentile(valid, s.Percentiles[0])
+		hi = percentile(valid, s.Percentiles[1])
+	case s.Range == StretchRangeFixed && params.Clip > 0:
+		lo = -params.Offset
+		hi = params.Clip - params.Offset
+	default:
+		lo = valid[0]
+		hi = valid[len(valid)-1]
+	}
+
+	// The equalisation maps the values to their rank among
+	// the valid values within the range
+	cdf := func(val float64) float64 {
+		return float64(sort.Search(len(valid), func(i int) bool { return valid[i] > val }))
+	}
+	cdfLo, cdfHi := cdf(lo), cdf(hi)
+
+	out := &ByteRaster{NoData: r.GetNoData(), Data: make([]uint8, pixels.Len())}
+	out.NameSpace, out.Height, out.Width = nameSpace, height, width
+	for i := range out.Data {
+		val := pixels.Value(i)
+		if val == noData || math.IsNaN(val) || math.IsInf(val, 0) {
+			out.Data[i] = 0xFF
+			continue
+		}
+
+		var t float64
+		if hi > lo {
+			t = (val - lo) / (hi - lo)
+		}
+		t = math.Max(0, math.Min(1, t))
+
+		switch s.Mode {
+		case StretchLog:
+			t = math.Log10(1 + 9*t)
+		case StretchSqrt:
+			t = math.Sqrt(t)
+		case StretchGamma:
+			t = math.Pow(t, 1/s.Gamma)
+		case StretchEqualise:
+			t = 0
+			if cdfHi > cdfLo {
+				t = (cdf(math.Max(lo, math.Min(hi, val))) - cdfLo) / (cdfHi - cdfLo)
+			}
+		}
+		out.Data[i] = uint8(t * 254)
+	}
+	return out, nil
+}
+
+// percentile returns the p-th percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	return sorted[int(p/100*float64(len(sorted)-1)+0.5)]
+}
+
+// percentile approximates the p-th percentile of a band from
+// its mean and standard deviation, assuming normally distributed
+// values, within the [min, max] range of the band
+func (stats BandStatistics) percentile(p float64) float64 {
+	if p <= 0 {
+		return stats.Min
+	}
+	if p >= 100 {
+		return stats.Max
+	}
+	val := stats.Mean + stats.StdDev*math.Sqrt2*math.Erfinv(2*p/100-1)
+	return math.Max(stats.Min, math.Min(stats.Max, val))
+}
+
+// RasterInfo returns the namespace, height and width of a raster
+func RasterInfo(r Raster) (string, int, int) {
+	switch t := r.(type) {
+	case *ByteRaster:
+		return t.NameSpace, t.Height, t.Width
+	case *Int16Raster:
+		return t.NameSpace, t.Height, t.Width
+	case *UInt16Raster:
+		return t.NameSpace, t.Height, t.Width
+	case *Float32Raster:
+		return t.NameSpace, t.Height, t.Width
+	case *TypedRaster:
+		return t.NameSpace, t.Height, t.Width
+	}
+	return "", 0, 0
+}
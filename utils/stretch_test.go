@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseStretch(t *testing.T) {
+	s := &Stretch{Mode: " Sqrt "}
+	if err := ParseStretch(s); err != nil {
+		t.Fatalf("failed to parse stretch: %v", err)
+	}
+	expected := &Stretch{Mode: StretchSqrt, Range: StretchRangeFixed, Gamma: 1, Percentiles: []float64{2, 98}}
+	if !reflect.DeepEqual(s, expected) {
+		t.Errorf("expected %+v, got %+v", expected, s)
+	}
+
+	for _, s := range []*Stretch{
+		{Mode: "cubic"},
+		{Range: "auto"},
+		{Gamma: -1},
+		{Percentiles: []float64{98, 2}},
+		{Percentiles: []float64{2, 98, 99}},
+	} {
+		if err := ParseStretch(s); err == nil {
+			t.Errorf("expected error for stretch %+v", s)
+		}
+	}
+}
+
+func TestStretch(t *testing.T) {
+	tests := []struct {
+		stretch  Stretch
+		params   ScaleParams
+		expected []uint8
+	}{
+		{Stretch{Range: StretchRangeMinMax}, ScaleParams{}, []uint8{0, 127, 254, 255}},
+		{Stretch{Mode: StretchSqrt}, ScaleParams{Offset: 10, Clip: 40}, []uint8{179, 219, 254, 255}},
+		{Stretch{Mode: StretchGamma, Gamma: 0.5, Range: StretchRangeMinMax}, ScaleParams{}, []uint8{0, 63, 254, 255}},
+		{Stretch{Mode: StretchLog, Range: StretchRangeMinMax}, ScaleParams{}, []uint8{0, 188, 254, 255}},
+		{Stretch{Range: StretchRangePercentile, Percentiles: []float64{50, 100}}, ScaleParams{}, []uint8{0, 0, 254, 255}},
+	}
+
+	for _, test := range tests {
+		s := test.stretch
+		if err := ParseStretch(&s); err != nil {
+			t.Fatalf("failed to parse stretch: %v", err)
+		}
+		test.params.Stretch = &s
+
+		in := &Float32Raster{Data: []float32{10, 20, 30, -1}, NoData: -1, Height: 1, Width: 4}
+		out, err := Scale([]Raster{in}, test.params)
+		if err != nil {
+			t.Fatalf("%+v: %v", s, err)
+		}
+		if !reflect.DeepEqual(out[0].Data, test.expected) {
+			t.Errorf("%+v: expected %v, got %v", s, test.expected, out[0].Data)
+		}
+	}
+}
+
+func TestStretchBands(t *testing.T) {
+	s := &Stretch{Mode: StretchEqualise, Range: StretchRangeMinMax}
+	if err := ParseStretch(s); err != nil {
+		t.Fatalf("failed to parse stretch: %v", err)
+	}
+
+	// Each band is equalised on its own values
+	in := []Raster{
+		&UInt16Raster{Data: []uint16{1, 2, 3, 100}, Height: 2, Width: 2},
+		&TypedRaster{Type: "Int8", Data: []uint8{0xFF, 0, 1, 2}, NoData: 2, Height: 2, Width: 2},
+	}
+	out, err := Scale(in, ScaleParams{Stretch: s})
+	if err != nil {
+		t.Fatalf("failed to stretch bands: %v", err)
+	}
+
+	expected := [][]uint8{{0, 84, 169, 254}, {0, 127, 254, 255}}
+	for i := range out {
+		if !reflect.DeepEqual(out[i].Data, expected[i]) {
+			t.Errorf("band %d: expected %v, got %v", i, expected[i], out[i].Data)
+		}
+	}
+}
+
+func TestStretchStatistics(t *testing.T) {
+	s := &Stretch{Range: StretchRangeStatistics}
+	if err := ParseStretch(s); err != nil {
+		t.Fatalf("failed to parse stretch: %v", err)
+	}
+	s.Statistics = map[string]BandStatistics{
+		"b1": {NameSpace: "b1", Min: 0, Max: 100, Mean: 50, StdDev: 10},
+	}
+
+	// The 2nd and 98th percentiles are about 2.054 standard
+	// deviations away from the mean
+	tiles := []*Float32Raster{
+		{NameSpace: "b1", Data: []float32{29.46, 50, 70.54, -1}, NoData: -1, Height: 1, Width: 4},
+		{NameSpace: "b1", Data: []float32{50, 90, 0, 100}, NoData: -1, Height: 1, Width: 4},
+	}
+	expected := [][]uint8{{0, 127, 254, 255}, {127, 254, 0, 254}}
+	for i, tile := range tiles {
+		out, err := Scale([]Raster{tile}, ScaleParams{Stretch: s})
+		if err != nil {
+			t.Fatalf("tile %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(out[0].Data, expected[i]) {
+			t.Errorf("tile %d: expected %v, got %v", i, expected[i], out[0].Data)
+		}
+	}
+
+	// Bands without statistics fall back to the minmax range
+	other := &Float32Raster{NameSpace: "b2", Data: []float32{10, 20, 30, -1}, NoData: -1, Height: 1, Width: 4}
+	out, err := Scale([]Raster{other}, ScaleParams{Stretch: s})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(out[0].Data, []uint8{0, 127, 254, 255}) {
+		t.Errorf("expected the minmax range, got %v", out[0].Data)
+	}
+}
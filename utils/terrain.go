@@ -0,0 +1,289 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+)
+
+// Terrain modes rendering the elevation of DEM layers
+const (
+	TerrainHillshade = "hillshade"
+	TerrainSlope     = "slope"
+	TerrainAspect    = "aspect"
+	TerrainRelief    = "relief"
+)
+
+var terrainModes = map[string]bool{
+	TerrainHillshade: true,
+	TerrainSlope:     true,
+	TerrainAspect:    true,
+	TerrainRelief:    true,
+}
+
+// TerrainNoData is the nodata value of the terrain rasters
+const TerrainNoData = -9999
+
+// reliefAmbient is the share of the relief colours kept in
+// the fully shaded areas
+const reliefAmbient = 0.35
+
+const earthRadius = 6378137.0
+
+// geographicEPSG lists the CRSs in degrees of latitude and
+// longitude and mercatorEPSG the web mercator CRSs
+var geographicEPSG = map[int]bool{4326: true, 4283: true, 4258: true, 4269: true, 7844: true}
+var mercatorEPSG = map[int]bool{3857: true, 3785: true, 900913: true, 102100: true}
+
+// Terrain configures the terrain rendering of DEM layers.
+// Hillshade shades the elevation lit from Azimuth, in
+// degrees clockwise from north, and Altitude, in degrees
+// above the horizon. Slope and aspect render the steepness
+// and facing direction in degrees. Relief renders the
+// elevation with the palette of the layer blended with its
+// hillshade. Azimuth defaults to 315, use 360 for north,
+// Altitude to 45 and ZFactor, the ratio of the elevation
+// units to metres, to 1.
+type Terrain struct {
+	Mode     string  `json:"mode"`
+	Azimuth  float64 `json:"azimuth"`
+	Altitude float64 `json:"altitude"`
+	ZFactor  float64 `json:"z_factor"`
+}
+
+// ParseTerrain validates a terrain and sets its defaults
+func ParseTerrain(t *Terrain) error {
+	t.Mode = strings.ToLower(strings.TrimSpace(t.Mode))
+	if len(t.Mode) == 0 {
+		t.Mode = TerrainHillshade
+	}
+	if !terrainModes[t.Mode] {
+		return fmt.Errorf("unsupported terrain mode: %s", t.Mode)
+	}
+
+	if t.Azimuth == 0 {
+		t.Azimuth = 315
+	}
+	if t.Azimuth < 0 || t.Azimuth > 360 {
+		return fmt.Errorf("terrain azimuth must be within [0, 360]: %v", t.Azimuth)
+	}
+
+	if t.Altitude == 0 {
+		t.Altitude = 45
+	}
+	if t.Altitude < 0 || t.Altitude > 90 {
+		return fmt.Errorf("terrain altitude must be within [0, 90]: %v", t.Altitude)
+	}
+
+	if t.ZFactor == 0 {
+		t.ZFactor = 1
+	}
+	if t.ZFactor < 0 {
+		return fmt.Errorf("terrain z_factor must be positive: %v", t.ZFactor)
+	}
+	return nil
+}
+
+// IsRelief tells whether a terrain renders the colour relief
+func (t *Terrain) IsRelief() bool {
+	return t != nil && t.Mode == TerrainRelief
+}
+
+// DefaultClip returns the largest value of a terrain mode,
+// used as the clip value of layers without one
+func (t *Terrain) DefaultClip() float64 {
+	switch t.Mode {
+	case TerrainSlope:
+		return 90
+	case TerrainAspect:
+		return 360
+	case TerrainHillshade:
+		return 255
+	}
+	return 0
+}
+
+// DefaultPalette returns the palette of the terrain layers
+// without one. Shades are rendered from black to white and
+// reliefs with the terrain colormap.
+func (t *Terrain) DefaultPalette() *Palette {
+	if t.Mode == TerrainRelief {
+		return &Palette{Name: "terrain", Interpolate: true}
+	}
+	return &Palette{Name: "greys", Interpolate: true, Reverse: true}
+}
+
+// ComputeTerrain renders the elevation raster of a tile read
+// with a one pixel halo around it, so that the pixels at the
+// edges of the tile have all their neighbours. The bbox and
+// CRS are those of the tile without the halo. The terrain is
+// returned as a Float32 raster of the tile without the halo.
+// Reliefs return the elevation of the tile followed by its
+// hillshade.
+//
+// The gradients are computed with the Horn method. Missing
+// neighbours are replaced by the centre pixel, which keeps
+// the edges of the data valid.
+func ComputeTerrain(r Raster, terrain *Terrain, crs string, bbox []float64) ([]Raster, error) {
+	pixels, noData, err := rasterPixels(r)
+	if err != nil {
+		return nil, err
+	}
+	nameSpace, haloHeight, haloWidth := RasterInfo(r)
+	width, height := haloWidth-2, haloHeight-2
+	if width <= 0 || height <= 0 || len(bbox) != 4 {
+		return nil, fmt.Errorf("invalid terrain tile of %dx%d pixels", haloWidth, haloHeight)
+	}
+
+	epsg := 0
+	if strings.HasPrefix(strings.ToUpper(crs), "EPSG:") {
+		fmt.Sscanf(crs[5:], "%d", &epsg)
+	}
+	xRes := (bbox[2] - bbox[0]) / float64(width)
+	yRes := (bbox[3] - bbox[1]) / float64(height)
+
+	valid := func(val float64) bool {
+		return val != noData && !math.IsNaN(val) && !math.IsInf(val, 0)
+	}
+
+	elevation := &Float32Raster{NameSpace: nameSpace, NoData: noData, Width: width, Height: height,
+		Data: make([]float32, width*height)}
+	shade := &Float32Raster{NameSpace: terrain.Mode, NoData: TerrainNoData, Width: width, Height: height,
+		Data: make([]float32, width*height)}
+
+	zenith := (90 - terrain.Altitude) * math.Pi / 180
+	azimuth := terrain.Azimuth * math.Pi / 180
+	window := make([]float64, 9)
+
+	for y := 0; y < height; y++ {
+		// The ground size of the pixels varies with the
+		// latitude of the row in geographic and mercator CRSs
+		rowY := bbox[3] - (float64(y)+0.5)*yRes
+		dx, dy := xRes, yRes
+		switch {
+		case geographicEPSG[epsg]:
+			dy = yRes * earthRadius * math.Pi / 180
+			dx = xRes * earthRadius * math.Pi / 180 * math.Cos(rowY*math.Pi/180)
+		case mercatorEPSG[epsg]:
+			scale := math.Cos(math.Atan(math.Sinh(rowY / earthRadius)))
+			dx, dy = xRes*scale, yRes*scale
+		}
+
+		for x := 0; x < width; x++ {
+			dst := y*width + x
+			centre := pixels.Value((y+1)*haloWidth + x + 1)
+			if !valid(centre) {
+				elevation.Data[dst] = float32(noData)
+				shade.Data[dst] = TerrainNoData
+				continue
+			}
+			elevation.Data[dst] = float32(centre)
+
+			for wy := 0; wy < 3; wy++ {
+				for wx := 0; wx < 3; wx++ {
+					val := pixels.Value((y+wy)*haloWidth + x + wx)
+					if !valid(val) {
+						val = centre
+					}
+					window[wy*3+wx] = val * terrain.ZFactor
+				}
+			}
+
+			// Gradients towards the east and the north
+			gx := ((window[2] + 2*window[5] + window[8]) - (window[0] + 2*window[3] + window[6])) / (8 * dx)
+			gy := ((window[0] + 2*window[1] + window[2]) - (window[6] + 2*window[7] + window[8])) / (8 * dy)
+			slope := math.Atan(math.Hypot(gx, gy))
+
+			// The aspect is the bearing of the downslope
+			// direction, undefined on flat ground
+			aspect := math.NaN()
+			if gx != 0 || gy != 0 {
+				aspect = math.Atan2(-gx, -gy)
+				if aspect < 0 {
+					aspect += 2 * math.Pi
+				}
+			}
+
+			switch terrain.Mode {
+			case TerrainSlope:
+				shade.Data[dst] = float32(slope * 180 / math.Pi)
+			case TerrainAspect:
+				if math.IsNaN(aspect) {
+					shade.Data[dst] = TerrainNoData
+				} else {
+					shade.Data[dst] = float32(aspect * 180 / math.Pi)
+				}
+			default:
+				lit := math.Cos(zenith) * math.Cos(slope)
+				if !math.IsNaN(aspect) {
+					lit += math.Sin(zenith) * math.Sin(slope) * math.Cos(azimuth-aspect)
+				}
+				shade.Data[dst] = float32(255 * math.Max(0, lit))
+			}
+		}
+	}
+
+	if terrain.Mode == TerrainRelief {
+		return []Raster{elevation, shade}, nil
+	}
+	return []Raster{shade}, nil
+}
+
+// EncodeReliefPNG colours a scaled elevation raster with a
+// palette and darkens the colours by the hillshade of the
+// elevation
+func EncodeReliefPNG(br *ByteRaster, shade Raster, palette *Palette) ([]byte, error) {
+	shadeRaster, ok := shade.(*Float32Raster)
+	if !ok || len(shadeRaster.Data) != len(br.Data) {
+		return nil, fmt.Errorf("invalid relief hillshade")
+	}
+	plt, err := GradientRGBAPalette(palette)
+	if err != nil {
+		return nil, err
+	}
+
+	canvas := image.NewRGBA(image.Rect(0, 0, br.Width, br.Height))
+	for i, val := range br.Data {
+		if val == 0xFF {
+			continue
+		}
+		factor := 1.0
+		if lit := float64(shadeRaster.Data[i]); lit != shadeRaster.NoData {
+			factor = reliefAmbient + (1-reliefAmbient)*lit/255
+		}
+		c := plt[val]
+		canvas.SetRGBA(i%br.Width, i/br.Width, color.RGBA{uint8(float64(c.R) * factor),
+			uint8(float64(c.G) * factor), uint8(float64(c.B) * factor), c.A})
+	}
+
+	buf := new(bytes.Buffer)
+	err = png.Encode(buf, canvas)
+	return buf.Bytes(), err
+}
+
+// parseLayerTerrain validates the terrain of a layer or
+// style, which renders its single band, and sets the clip
+// value and palette of the terrain mode if they are unset
+func parseLayerTerrain(layer *Layer) error {
+	if err := ParseTerrain(layer.Terrain); err != nil {
+		return err
+	}
+	if len(layer.RGBProducts) != 1 {
+		return fmt.Errorf("terrain layers must have one rgb product, got %d", len(layer.RGBProducts))
+	}
+
+	if layer.ClipValue == 0 && layer.ScaleValue == 0 {
+		layer.ClipValue = layer.Terrain.DefaultClip()
+	}
+	if layer.Palette == nil {
+		layer.Palette = layer.Terrain.DefaultPalette()
+		if err := ParsePalette(layer.Palette); err != nil {
+			return err
+		}
+	}
+	return nil
+}
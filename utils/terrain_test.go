@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+// slopeTile returns a haloed tile of 3x3 pixels rising
+// towards the east by rise per pixel
+func slopeTile(rise float32) *Float32Raster {
+	r := &Float32Raster{NoData: -1, Width: 5, Height: 5, Data: make([]float32, 25)}
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 5; x++ {
+			r.Data[y*5+x] = 100 + rise*float32(x)
+		}
+	}
+	return r
+}
+
+func TestParseTerrain(t *testing.T) {
+	terrain := &Terrain{}
+	if err := ParseTerrain(terrain); err != nil {
+		t.Fatalf("%v", err)
+	}
+	if terrain.Mode != TerrainHillshade || terrain.Azimuth != 315 || terrain.Altitude != 45 || terrain.ZFactor != 1 {
+		t.Errorf("unexpected defaults: %+v", terrain)
+	}
+
+	for _, invalid := range []*Terrain{{Mode: "contour"}, {Azimuth: 400}, {Altitude: 95}, {ZFactor: -1}} {
+		if err := ParseTerrain(invalid); err == nil {
+			t.Errorf("expected an error for %+v", invalid)
+		}
+	}
+}
+
+func TestComputeTerrain(t *testing.T) {
+	bbox := []float64{0, 0, 30, 30}
+
+	slope := &Terrain{Mode: TerrainSlope}
+	ParseTerrain(slope)
+	out, err := ComputeTerrain(slopeTile(10), slope, "EPSG:3577", bbox)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	res := out[0].(*Float32Raster)
+	if len(out) != 1 || res.Width != 3 || res.Height != 3 {
+		t.Fatalf("unexpected terrain raster %dx%d", res.Width, res.Height)
+	}
+	for i, val := range res.Data {
+		if math.Abs(float64(val)-45) > 1e-3 {
+			t.Errorf("slope[%d] = %v, expected 45", i, val)
+		}
+	}
+
+	// The ground faces west, away from the rising east
+	aspect := &Terrain{Mode: TerrainAspect}
+	ParseTerrain(aspect)
+	out, _ = ComputeTerrain(slopeTile(10), aspect, "EPSG:3577", bbox)
+	if val := out[0].(*Float32Raster).Data[4]; math.Abs(float64(val)-270) > 1e-3 {
+		t.Errorf("aspect = %v, expected 270", val)
+	}
+
+	out, _ = ComputeTerrain(slopeTile(0), aspect, "EPSG:3577", bbox)
+	if val := out[0].(*Float32Raster).Data[4]; val != TerrainNoData {
+		t.Errorf("flat aspect = %v, expected nodata", val)
+	}
+
+	// Slopes facing the sun are brighter than flat ground
+	lit := &Terrain{Mode: TerrainHillshade, Azimuth: 270}
+	ParseTerrain(lit)
+	out, _ = ComputeTerrain(slopeTile(0), lit, "EPSG:3577", bbox)
+	flat := out[0].(*Float32Raster).Data[4]
+	if math.Abs(float64(flat)-255*math.Cos(math.Pi/4)) > 1e-3 {
+		t.Errorf("flat hillshade = %v", flat)
+	}
+	out, _ = ComputeTerrain(slopeTile(10), lit, "EPSG:3577", bbox)
+	if val := out[0].(*Float32Raster).Data[4]; val <= flat {
+		t.Errorf("sunlit hillshade %v is not brighter than %v", val, flat)
+	}
+
+	// Reliefs return the elevation without the halo and the
+	// hillshade. Nodata pixels stay nodata.
+	relief := &Terrain{Mode: TerrainRelief}
+	ParseTerrain(relief)
+	tile := slopeTile(10)
+	tile.Data[6] = -1
+	out, err = ComputeTerrain(tile, relief, "EPSG:4326", []float64{140, -30, 140.001, -29.999})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if len(out) != 2 {
+		t.Fatalf("expected 2 relief rasters, got %d", len(out))
+	}
+	elevation := out[0].(*Float32Raster)
+	if elevation.Data[0] != -1 || elevation.Data[1] != 120 {
+		t.Errorf("unexpected relief elevation %v", elevation.Data)
+	}
+	if shade := out[1].(*Float32Raster); shade.Data[0] != TerrainNoData || shade.Data[1] < 0 || shade.Data[1] > 255 {
+		t.Errorf("unexpected relief hillshade %v", shade.Data)
+	}
+
+	if _, err := ComputeTerrain(&Float32Raster{Width: 2, Height: 2, Data: make([]float32, 4)}, slope, "EPSG:3577", bbox); err == nil {
+		t.Errorf("expected an error for a tile without pixels")
+	}
+}
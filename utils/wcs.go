@@ -11,18 +11,25 @@ import (
 // WCSParams contains the serialised version
 // of the parameters contained in a WCS request.
 type WCSParams struct {
-	Service   *string    `json:"service,omitempty"`
-	Version   *string    `json:"version,omitempty"`
-	Request   *string    `json:"request,omitempty"`
-	Coverages []string   `json:"coverage,omitempty"`
-	CRS       *string    `json:"crs,omitempty"`
-	ReqCRS    *string    `json:"req_crs,omitempty"`
-	BBox      []float64  `json:"bbox,omitempty"`
-	Time      *time.Time `json:"time,omitempty"`
-	Height    *int       `json:"height,omitempty"`
-	Width     *int       `json:"width,omitempty"`
-	Format    *string    `json:"format,omitempty"`
-	Styles    []string   `json:"styles,omitempty"`
+	Service       *string    `json:"service,omitempty"`
+	Version       *string    `json:"version,omitempty"`
+	Request       *string    `json:"request,omitempty"`
+	Coverages     []string   `json:"coverage,omitempty"`
+	CRS           *string    `json:"crs,omitempty"`
+	ReqCRS        *string    `json:"req_crs,omitempty"`
+	BBox          []float64  `json:"bbox,omitempty"`
+	Time          *time.Time `json:"time,omitempty"`
+	Height        *int       `json:"height,omitempty"`
+	Width         *int       `json:"width,omitempty"`
+	Format        *string    `json:"format,omitempty"`
+	Styles        []string   `json:"styles,omitempty"`
+	Interpolation *string    `json:"interpolation,omitempty"`
+	Composite     *string    `json:"composite,omitempty"`
+	ResX          *float64   `json:"resx,omitempty"`
+	ResY          *float64   `json:"resy,omitempty"`
+	Native        bool       `json:"native,omitempty"`
+	Clip          *string    `json:"clip,omitempty"`
+	ClipID        *string    `json:"clip_id,omitempty"`
 }
 
 // WCSRegexpMap maps WCS request parameters to
@@ -40,6 +47,9 @@ var WCSRegexpMap = map[string]string{"service": `^WCS$`,
 	"time":     `^\d{4}-(?:1[0-2]|0[1-9])-(?:3[01]|0[1-9]|[12][0-9])T[0-2]\d:[0-5]\d:[0-5]\d(\.\d+)?Z$`,
 	"width":    `^[-+]?[0-9]+$`,
 	"height":   `^[-+]?[0-9]+$`,
+	"resx":     `^[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`,
+	"resy":     `^[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?$`,
+	"clip_id":  `^[A-Za-z0-9_.-]+$`,
 	"format":   `^(?i)(GeoTIFF|NetCDF)$`}
 
 func CompileWCSRegexMap() map[string]*regexp.Regexp {
@@ -110,6 +120,22 @@ func WCSParamsChecker(params map[string][]string, compREMap map[string]*regexp.R
 		}
 	}
 
+	// The keyword 'native' in either resx or resy selects
+	// the finest resolution of the coverage
+	native := false
+	for _, resKey := range []string{"resx", "resy"} {
+		if res, resOK := params[resKey]; resOK {
+			if strings.EqualFold(strings.TrimSpace(res[0]), "native") {
+				native = true
+			} else if compREMap[resKey].MatchString(res[0]) {
+				jsonFields = append(jsonFields, fmt.Sprintf(`"%s":%s`, resKey, res[0]))
+			}
+		}
+	}
+	if native {
+		jsonFields = append(jsonFields, `"native":true`)
+	}
+
 	if time, timeOK := params["time"]; timeOK {
 		if compREMap["time"].MatchString(time[0]) {
 			jsonFields = append(jsonFields, fmt.Sprintf(`"time":"%s"`, time[0]))
@@ -128,6 +154,40 @@ func WCSParamsChecker(params map[string][]string, compREMap map[string]*regexp.R
 		}
 	}
 
+	if interpolation, interpolationOK := params["interpolation"]; interpolationOK {
+		resampling, err := ParseResampling(interpolation[0])
+		if err != nil {
+			return WCSParams{}, err
+		}
+		if len(resampling) > 0 {
+			jsonFields = append(jsonFields, fmt.Sprintf(`"interpolation":"%s"`, resampling))
+		}
+	}
+
+	if composite, compositeOK := params["composite"]; compositeOK {
+		reducer, err := ParseComposite(composite[0])
+		if err != nil {
+			return WCSParams{}, err
+		}
+		if len(reducer) > 0 {
+			jsonFields = append(jsonFields, fmt.Sprintf(`"composite":"%s"`, reducer))
+		}
+	}
+
+	if clip, clipOK := params["clip"]; clipOK {
+		clipJSON, err := json.Marshal(clip[0])
+		if err != nil {
+			return WCSParams{}, err
+		}
+		jsonFields = append(jsonFields, fmt.Sprintf(`"clip":%s`, clipJSON))
+	}
+
+	if clipID, clipIDOK := params["clip_id"]; clipIDOK {
+		if compREMap["clip_id"].MatchString(clipID[0]) {
+			jsonFields = append(jsonFields, fmt.Sprintf(`"clip_id":"%s"`, clipID[0]))
+		}
+	}
+
 	jsonParams := fmt.Sprintf("{%s}", strings.Join(jsonFields, ","))
 
 	var wcsParams WCSParams
@@ -16,19 +16,22 @@ const ISOZeroTime = "0001-01-01T00:00:00.000Z"
 // WMSParams contains the serialised version
 // of the parameters contained in a WMS request.
 type WMSParams struct {
-	Service *string    `json:"service,omitempty"`
-	Request *string    `json:"request,omitempty"`
-	CRS     *string    `json:"crs,omitempty"`
-	BBox    []float64  `json:"bbox,omitempty"`
-	Format  *string    `json:"format,omitempty"`
-	X       *int       `json:"x,omitempty"`
-	Y       *int       `json:"y,omitempty"`
-	Height  *int       `json:"height,omitempty"`
-	Width   *int       `json:"width,omitempty"`
-	Time    *time.Time `json:"time,omitempty"`
-	Layers  []string   `json:"layers,omitempty"`
-	Styles  []string   `json:"styles,omitempty"`
-	Version *string    `json:"version,omitempty"`
+	Service    *string    `json:"service,omitempty"`
+	Request    *string    `json:"request,omitempty"`
+	CRS        *string    `json:"crs,omitempty"`
+	BBox       []float64  `json:"bbox,omitempty"`
+	Format     *string    `json:"format,omitempty"`
+	X          *int       `json:"x,omitempty"`
+	Y          *int       `json:"y,omitempty"`
+	Height     *int       `json:"height,omitempty"`
+	Width      *int       `json:"width,omitempty"`
+	Time       *time.Time `json:"time,omitempty"`
+	EndTime    *time.Time `json:"end_time,omitempty"`
+	Layers     []string   `json:"layers,omitempty"`
+	Styles     []string   `json:"styles,omitempty"`
+	Version    *string    `json:"version,omitempty"`
+	Resampling *string    `json:"resampling,omitempty"`
+	Composite  *string    `json:"composite,omitempty"`
 }
 
 // WMSRegexpMap maps WMS request parameters to
@@ -46,7 +49,8 @@ var WMSRegexpMap = map[string]string{"service": `^WMS$`,
 	"y":       `^[0-9]+$`,
 	"width":   `^[0-9]+$`,
 	"height":  `^[0-9]+$`,
-	"time":    `^\d{4}-(?:1[0-2]|0[1-9])-(?:3[01]|0[1-9]|[12][0-9])T[0-2]\d:[0-5]\d:[0-5]\d\.\d+Z$`}
+	"time":    `^\d{4}-(?:1[0-2]|0[1-9])-(?:3[01]|0[1-9]|[12][0-9])T[0-2]\d:[0-5]\d:[0-5]\d\.\d+Z$`,
+	"format":  `^(?i)image/[a-z0-9.+-]+$`}
 
 // BBox2Geot return the geotransform from the
 // parameters received in a WMS GetMap request
@@ -154,12 +158,25 @@ func WMSParamsChecker(params map[string][]string, compREMap map[string]*regexp.R
 		}
 	}
 
+	// A start/end time range requests an animation
 	if time, timeOK := params["time"]; timeOK {
-		if compREMap["time"].MatchString(time[0]) {
+		timeRange := strings.Split(time[0], "/")
+		if len(timeRange) == 2 {
+			if compREMap["time"].MatchString(timeRange[0]) && compREMap["time"].MatchString(timeRange[1]) {
+				jsonFields = append(jsonFields, fmt.Sprintf(`"time":"%s"`, timeRange[0]))
+				jsonFields = append(jsonFields, fmt.Sprintf(`"end_time":"%s"`, timeRange[1]))
+			}
+		} else if compREMap["time"].MatchString(time[0]) {
 			jsonFields = append(jsonFields, fmt.Sprintf(`"time":"%s"`, time[0]))
 		}
 	}
 
+	if format, formatOK := params["format"]; formatOK {
+		if compREMap["format"].MatchString(format[0]) {
+			jsonFields = append(jsonFields, fmt.Sprintf(`"format":"%s"`, strings.ToLower(format[0])))
+		}
+	}
+
 	var layers []string
 	if _layers, layersOK := params["layers"]; layersOK {
 		layers = _layers
@@ -180,6 +197,26 @@ func WMSParamsChecker(params map[string][]string, compREMap map[string]*regexp.R
 		}
 	}
 
+	if resampling, resamplingOK := params["resampling"]; resamplingOK {
+		alg, err := ParseResampling(resampling[0])
+		if err != nil {
+			return WMSParams{}, err
+		}
+		if len(alg) > 0 {
+			jsonFields = append(jsonFields, fmt.Sprintf(`"resampling":"%s"`, alg))
+		}
+	}
+
+	if composite, compositeOK := params["composite"]; compositeOK {
+		reducer, err := ParseComposite(composite[0])
+		if err != nil {
+			return WMSParams{}, err
+		}
+		if len(reducer) > 0 {
+			jsonFields = append(jsonFields, fmt.Sprintf(`"composite":"%s"`, reducer))
+		}
+	}
+
 	jsonParams := fmt.Sprintf("{%s}", strings.Join(jsonFields, ","))
 
 //fmt.Println("------------AVS-5")
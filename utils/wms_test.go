@@ -24,3 +24,26 @@ func TestGetCurrentTimeStamp(t *testing.T) {
 		return
 	}
 }
+
+func TestWMSParamsCheckerTimeRange(t *testing.T) {
+	query := map[string][]string{
+		"time":   {"2018-01-01T00:00:00.000Z/2018-02-01T00:00:00.000Z"},
+		"format": {"image/GIF"},
+	}
+	params, err := WMSParamsChecker(query, CompileWMSRegexMap())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if params.Time == nil || params.EndTime == nil || !params.EndTime.After(*params.Time) {
+		t.Errorf("failed to parse the time range: %v", query["time"])
+	}
+	if params.Format == nil || *params.Format != "image/gif" {
+		t.Errorf("failed to parse the format: %v", query["format"])
+	}
+
+	query["time"] = []string{"2018-01-01T00:00:00.000Z"}
+	params, err = WMSParamsChecker(query, CompileWMSRegexMap())
+	if err != nil || params.Time == nil || params.EndTime != nil {
+		t.Errorf("failed to parse the time: %v", query["time"])
+	}
+}
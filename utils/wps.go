@@ -8,18 +8,23 @@ import "C"
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"math"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	geo "github.com/nci/geometry"
 )
 
 type Data struct {
 	ComplexData string
+	LiteralData string
 }
 
 type Input struct {
@@ -31,28 +36,58 @@ type DataInputs struct {
 	Input []Input
 }
 
+type OutputDefinition struct {
+	MimeType string `xml:"mimeType,attr"`
+}
+
+type ResponseDocument struct {
+	StoreExecuteResponse bool `xml:"storeExecuteResponse,attr"`
+	Status               bool `xml:"status,attr"`
+	Output               []OutputDefinition
+}
+
+type ResponseForm struct {
+	ResponseDocument ResponseDocument
+	RawDataOutput    *OutputDefinition
+}
+
 type Execute struct {
-	Version    string `xml:"version,attr"`
-	Service    string `xml:"service,attr"`
-	Identifier string
-	DataInputs DataInputs
+	Version      string `xml:"version,attr"`
+	Service      string `xml:"service,attr"`
+	Identifier   string
+	DataInputs   DataInputs
+	ResponseForm ResponseForm
 }
 
 func ParsePost(rc io.ReadCloser) (map[string][]string, error) {
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(rc)
 	rc.Close()
+
+	// JSON execute requests are told apart from the XML
+	// Execute document by their first character
+	if bytes.HasPrefix(bytes.TrimSpace(buf.Bytes()), []byte("{")) {
+		return ParseJSONExecute(buf.Bytes())
+	}
+
 	var exec Execute
 	err := xml.Unmarshal(buf.Bytes(), &exec)
 	if err != nil {
 		return map[string][]string{}, err
 	}
 
-	parsedBody := map[string][]string{"status": []string{"true"},
-		"service":    []string{exec.Service},
-		"request":    []string{"Execute"},
-		"version":    []string{exec.Version},
-		"identifier": []string{exec.Identifier}}
+	parsedBody := map[string][]string{"status": []string{fmt.Sprintf("%t", exec.ResponseForm.ResponseDocument.Status)},
+		"storeexecuteresponse": []string{fmt.Sprintf("%t", exec.ResponseForm.ResponseDocument.StoreExecuteResponse)},
+		"service":              []string{exec.Service},
+		"request":              []string{"Execute"},
+		"version":              []string{exec.Version},
+		"identifier":           []string{exec.Identifier}}
+
+	if exec.ResponseForm.RawDataOutput != nil {
+		parsedBody["rawdataoutput"] = []string{"Result@mimeType=" + exec.ResponseForm.RawDataOutput.MimeType}
+	} else if len(exec.ResponseForm.ResponseDocument.Output) > 0 {
+		parsedBody["responsedocument"] = []string{"Result@mimeType=" + exec.ResponseForm.ResponseDocument.Output[0].MimeType}
+	}
 
 	for _, input := range exec.DataInputs.Input {
 		inputID := strings.ToLower(strings.TrimSpace(input.Identifier))
@@ -62,6 +97,8 @@ func ParsePost(rc io.ReadCloser) (map[string][]string, error) {
 			parsedBody["end_datetime"] = []string{input.Data.ComplexData}
 		} else if inputID == "geometry" {
 			parsedBody["geometry"] = []string{fmt.Sprintf(`geometry=%s`, input.Data.ComplexData)}
+		} else if len(inputID) > 0 && !wpsRequestParams[inputID] {
+			parsedBody[inputID] = []string{input.Data.LiteralData}
 		}
 	}
 
@@ -78,8 +115,54 @@ type WPSParams struct {
 	EndDateTime   *string               `json:"end_datetime"`
 	Product       *string               `json:"product"`
 	FeatCol       geo.FeatureCollection `json:"feature_collection"`
+	StoreResponse bool                  `json:"store_execute_response"`
+	Status        bool                  `json:"status"`
+	JobID         *string               `json:"job_id"`
+	Statistics    []string              `json:"statistics"`
+	IDProperty    string                `json:"id_property"`
+	FeatureIDs    []string              `json:"feature_ids"`
+	MimeType      string                `json:"mime_type"`
+	RawOutput     bool                  `json:"raw_output"`
+	Spacing       float64               `json:"spacing"`
+	Period        string                `json:"period"`
+	Aggregate     string                `json:"aggregate"`
+	Anomaly       string                `json:"anomaly"`
+	BaselineStart string                `json:"baseline_start"`
+	BaselineEnd   string                `json:"baseline_end"`
+	Bands         []string              `json:"bands"`
+	BandStrides   int                   `json:"band_strides"`
+	Approx        *bool                 `json:"approx"`
+	Partial       bool                  `json:"partial"`
+	Exact         *bool                 `json:"exact"`
+	CRS           string                `json:"crs"`
+	Inputs        map[string]string     `json:"inputs"`
 }
 
+// Temporal aggregation periods, reducers and anomalies
+// of the time series of WPS drills
+const (
+	WPSPeriodMonthly  = "monthly"
+	WPSPeriodSeasonal = "seasonal"
+	WPSPeriodAnnual   = "annual"
+
+	WPSReducerMean = "mean"
+	WPSReducerSum  = "sum"
+	WPSReducerMin  = "min"
+	WPSReducerMax  = "max"
+
+	WPSAnomaly             = "anomaly"
+	WPSAnomalyStandardized = "standardized"
+)
+
+// MIME types of the structured WPS outputs. Outputs
+// are rendered with the templates of the processes
+// if none of them is requested.
+const (
+	WPSMimeCSV    = "text/csv"
+	WPSMimeJSON   = "application/json"
+	WPSMimeNetCDF = "application/x-netcdf"
+)
+
 // WPSRegexpMap maps WPS request parameters to
 // regular expressions for doing validation
 // when parsing.
@@ -88,8 +171,13 @@ type WPSParams struct {
 // --- cases. Error free JSON deserialisation into types
 // --- also validates correct values.
 var WPSRegexpMap = map[string]string{"service": `^WPS$`,
-	"request": `^GetCapabilities$|^DescribeProcess$|^Execute$`,
-	"time":    `^\d{4}-(?:1[0-2]|0[1-9])-(?:3[01]|0[1-9]|[12][0-9])T[0-2]\d:[0-5]\d$`}
+	"request":   `^GetCapabilities$|^DescribeProcess$|^Execute$|^GetStatus$`,
+	"time":      `^\d{4}-(?:1[0-2]|0[1-9])-(?:3[01]|0[1-9]|[12][0-9])T[0-2]\d:[0-5]\d$`,
+	"job_id":    `^[0-9a-f]{32}$`,
+	"mime_type": `^(text/csv|application/json|application/x-netcdf)$`,
+	"period":    `^(monthly|seasonal|annual)$`,
+	"aggregate": `^(mean|sum|min|max)$`,
+	"anomaly":   `^(anomaly|standardized)$`}
 
 func CompileWPSRegexMap() map[string]*regexp.Regexp {
 	REMap := make(map[string]*regexp.Regexp)
@@ -187,19 +275,181 @@ func WPSParamsChecker(params map[string][]string, compREMap map[string]*regexp.R
 		jsonFields = append(jsonFields, fmt.Sprintf(`"end_datetime":""`))
 	}
 
+	idProperty := ""
+	if idProp, idPropOK := params["id_property"]; idPropOK {
+		idProperty = strings.TrimSpace(idProp[0])
+		idPropJSON, _ := json.Marshal(idProperty)
+		jsonFields = append(jsonFields, fmt.Sprintf(`"id_property":%s`, string(idPropJSON)))
+	}
+
+	crsName := ""
 	if inputs, inputsOK := params["geometry"]; inputsOK {
 		rawInputs := strings.Split(inputs[0], ";")
+		var featCol []string
 		if len(rawInputs) > 1 {
 			prod := strings.Split(rawInputs[0], "=")
 			jsonFields = append(jsonFields, fmt.Sprintf(`"product":"%s"`, prod[1]))
-			featCol := strings.Split(rawInputs[1], "=")
+			featCol = strings.Split(rawInputs[1], "=")
 			jsonFields = append(jsonFields, fmt.Sprintf(`"feature_collection":%s`, featCol[1]))
 		} else {
-			featCol := strings.Split(rawInputs[0], "=")
+			featCol = strings.Split(rawInputs[0], "=")
 			jsonFields = append(jsonFields, fmt.Sprintf(`"feature_collection":%s`, featCol[1]))
 		}
 
+		featIDs, err := ExtractFeatureIDs(featCol[1], idProperty)
+		if err != nil {
+			return WPSParams{}, err
+		}
+		featIDsJSON, _ := json.Marshal(featIDs)
+		jsonFields = append(jsonFields, fmt.Sprintf(`"feature_ids":%s`, string(featIDsJSON)))
+
+		crsName, err = geoJSONCRSName(featCol[1])
+		if err != nil {
+			return WPSParams{}, err
+		}
+	}
+
+	// The crs input overrides the CRS named by the GeoJSON
+	if crs, crsOK := params["crs"]; crsOK && len(strings.TrimSpace(crs[0])) > 0 {
+		crsName = crs[0]
+	}
+	if len(crsName) > 0 {
+		crs, err := ParseWPSCRS(crsName)
+		if err != nil {
+			return WPSParams{}, err
+		}
+		jsonFields = append(jsonFields, fmt.Sprintf(`"crs":"%s"`, crs))
+	}
+
+	if store, storeOK := params["storeexecuteresponse"]; storeOK && strings.EqualFold(store[0], "true") {
+		jsonFields = append(jsonFields, `"store_execute_response":true`)
+	}
+
+	if status, statusOK := params["status"]; statusOK && strings.EqualFold(status[0], "true") {
+		jsonFields = append(jsonFields, `"status":true`)
+	}
+
+	if jobID, jobIDOK := params["jobid"]; jobIDOK {
+		if compREMap["job_id"].MatchString(jobID[0]) {
+			jsonFields = append(jsonFields, fmt.Sprintf(`"job_id":"%s"`, jobID[0]))
+		}
+	}
+
+	for _, form := range []string{"rawdataoutput", "responsedocument"} {
+		output, outputOK := params[form]
+		if !outputOK {
+			continue
+		}
+
+		mimeType := outputMimeType(output[0])
+		if len(mimeType) > 0 {
+			if !compREMap["mime_type"].MatchString(mimeType) {
+				return WPSParams{}, fmt.Errorf("Unsupported output mimeType: %s", mimeType)
+			}
+			jsonFields = append(jsonFields, fmt.Sprintf(`"mime_type":"%s"`, mimeType))
+		}
+
+		if form == "rawdataoutput" {
+			jsonFields = append(jsonFields, `"raw_output":true`)
+		}
+		break
+	}
+
+	if statistics, statisticsOK := params["statistics"]; statisticsOK {
+		stats, err := ParseZonalStatistics(statistics[0])
+		if err != nil {
+			return WPSParams{}, err
+		}
+		statsJSON, _ := json.Marshal(stats)
+		jsonFields = append(jsonFields, fmt.Sprintf(`"statistics":%s`, string(statsJSON)))
+	}
+
+	if spacing, spacingOK := params["spacing"]; spacingOK {
+		val, err := strconv.ParseFloat(strings.TrimSpace(spacing[0]), 64)
+		if err != nil || val <= 0 || math.IsInf(val, 0) {
+			return WPSParams{}, fmt.Errorf("Invalid profile spacing: %v", spacing[0])
+		}
+		jsonFields = append(jsonFields, fmt.Sprintf(`"spacing":%v`, val))
+	}
+
+	for _, name := range []string{"period", "aggregate", "anomaly"} {
+		if value, valueOK := params[name]; valueOK {
+			option := strings.ToLower(strings.TrimSpace(value[0]))
+			if len(option) == 0 {
+				continue
+			}
+			if !compREMap[name].MatchString(option) {
+				return WPSParams{}, fmt.Errorf("Invalid %s: %v", name, value[0])
+			}
+			jsonFields = append(jsonFields, fmt.Sprintf(`"%s":"%s"`, name, option))
+		}
+	}
+
+	for _, name := range []string{"baseline_start", "baseline_end"} {
+		if value, valueOK := params[name]; valueOK && len(strings.TrimSpace(value[0])) > 0 {
+			date, err := ParseWPSDate(value[0])
+			if err != nil {
+				return WPSParams{}, fmt.Errorf("Invalid %s: %v", name, value[0])
+			}
+			jsonFields = append(jsonFields, fmt.Sprintf(`"%s":"%s"`, name, date.Format(ISOFormat)))
+		}
+	}
+
+	if bands, bandsOK := params["bands"]; bandsOK {
+		var names []string
+		for _, name := range strings.Split(bands[0], ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			namesJSON, _ := json.Marshal(names)
+			jsonFields = append(jsonFields, fmt.Sprintf(`"bands":%s`, string(namesJSON)))
+		}
+	}
+
+	if strides, stridesOK := params["band_strides"]; stridesOK && len(strings.TrimSpace(strides[0])) > 0 {
+		val, err := strconv.Atoi(strings.TrimSpace(strides[0]))
+		if err != nil || val <= 0 {
+			return WPSParams{}, fmt.Errorf("Invalid band_strides: %v", strides[0])
+		}
+		jsonFields = append(jsonFields, fmt.Sprintf(`"band_strides":%d`, val))
+	}
+
+	if approx, approxOK := params["approx"]; approxOK && len(strings.TrimSpace(approx[0])) > 0 {
+		val, err := strconv.ParseBool(strings.TrimSpace(approx[0]))
+		if err != nil {
+			return WPSParams{}, fmt.Errorf("Invalid approx: %v", approx[0])
+		}
+		jsonFields = append(jsonFields, fmt.Sprintf(`"approx":%t`, val))
+	}
+
+	if partial, partialOK := params["partial"]; partialOK && len(strings.TrimSpace(partial[0])) > 0 {
+		val, err := strconv.ParseBool(strings.TrimSpace(partial[0]))
+		if err != nil {
+			return WPSParams{}, fmt.Errorf("Invalid partial: %v", partial[0])
+		}
+		jsonFields = append(jsonFields, fmt.Sprintf(`"partial":%t`, val))
+	}
+
+	if exact, exactOK := params["exact"]; exactOK && len(strings.TrimSpace(exact[0])) > 0 {
+		val, err := strconv.ParseBool(strings.TrimSpace(exact[0]))
+		if err != nil {
+			return WPSParams{}, fmt.Errorf("Invalid exact: %v", exact[0])
+		}
+		jsonFields = append(jsonFields, fmt.Sprintf(`"exact":%t`, val))
+	}
+
+	// The inputs are kept verbatim to be checked against
+	// the literal data declared by the requested process
+	inputs := make(map[string]string)
+	for key, val := range params {
+		if !wpsRequestParams[key] && len(val) > 0 {
+			inputs[key] = val[0]
+		}
 	}
+	inputsJSON, _ := json.Marshal(inputs)
+	jsonFields = append(jsonFields, fmt.Sprintf(`"inputs":%s`, string(inputsJSON)))
 
 	jsonParams := fmt.Sprintf("{%s}", strings.Join(jsonFields, ","))
 	var wpsParamms WPSParams
@@ -207,6 +457,96 @@ func WPSParamsChecker(params map[string][]string, compREMap map[string]*regexp.R
 	return wpsParamms, err
 }
 
+// ParseWPSDate parses a date given either in ISO format
+// or as a calendar date such as 1981-01-01
+func ParseWPSDate(text string) (time.Time, error) {
+	text = strings.TrimSpace(text)
+	if date, err := time.Parse(ISOFormat, text); err == nil {
+		return date, nil
+	}
+	if date, err := time.Parse(time.RFC3339, text); err == nil {
+		return date.UTC(), nil
+	}
+	return time.Parse("2006-01-02", text)
+}
+
+// outputMimeType returns the mimeType attribute of a
+// KVP output definition such as Result@mimeType=text/csv
+func outputMimeType(output string) string {
+	for _, attr := range strings.Split(output, "@")[1:] {
+		kv := strings.SplitN(attr, "=", 2)
+		if len(kv) == 2 && strings.EqualFold(strings.TrimSpace(kv[0]), "mimeType") {
+			return strings.TrimSpace(kv[1])
+		}
+	}
+	return ""
+}
+
+// EncodeWPSComplexData returns the wps:Output element of
+// an ExecuteResponse containing data of the given MIME
+// type. Binary data is base64 encoded.
+func EncodeWPSComplexData(mimeType string, data []byte) string {
+	var content, encoding string
+	if mimeType == WPSMimeNetCDF {
+		encoding = ` encoding="base64"`
+		content = base64.StdEncoding.EncodeToString(data)
+	} else {
+		content = "<![CDATA[" + strings.Replace(string(data), "]]>", "]]]]><![CDATA[>", -1) + "]]>"
+	}
+
+	return fmt.Sprintf(`<wps:Output>
+<ows:Identifier>Result</ows:Identifier>
+<ows:Title>Time Series Output</ows:Title>
+<wps:Data>
+<wps:ComplexData mimeType="%s"%s>%s</wps:ComplexData>
+</wps:Data>
+</wps:Output>`, mimeType, encoding, content)
+}
+
+// ExtractFeatureIDs returns the key of every feature of a
+// GeoJSON feature collection. The key is the value of the
+// idProperty property if given, otherwise the feature id or,
+// failing that, the position of the feature in the collection.
+func ExtractFeatureIDs(featColJSON string, idProperty string) ([]string, error) {
+	var featCol struct {
+		Features []struct {
+			ID         json.RawMessage            `json:"id"`
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"features"`
+	}
+
+	if err := json.Unmarshal([]byte(featColJSON), &featCol); err != nil {
+		return nil, fmt.Errorf("invalid feature collection: %v", err)
+	}
+
+	featIDs := make([]string, len(featCol.Features))
+	for i, feat := range featCol.Features {
+		if len(idProperty) > 0 {
+			prop, found := feat.Properties[idProperty]
+			if !found || string(prop) == "null" {
+				return nil, fmt.Errorf("feature %d has no '%s' property", i, idProperty)
+			}
+			featIDs[i] = jsonScalarString(prop)
+		} else if len(feat.ID) > 0 && string(feat.ID) != "null" {
+			featIDs[i] = jsonScalarString(feat.ID)
+		} else {
+			featIDs[i] = fmt.Sprintf("%d", i)
+		}
+	}
+
+	return featIDs, nil
+}
+
+// jsonScalarString returns the content of a JSON string
+// or the text of any other JSON value
+func jsonScalarString(value json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(value, &str); err == nil {
+		return str
+	}
+	return string(value)
+}
+
 const WGS84WKT = `GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.01745329251994328,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]]`
 
 func GetArea(wgs84Poly geo.Geometry) float64 {
@@ -0,0 +1,128 @@
+package utils
+
+// #include "gdal.h"
+// #include "ogr_api.h"
+// #include "ogr_srs_api.h"
+// #cgo pkg-config: gdal
+import "C"
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// WPSDefaultCRS is the CRS of GeoJSON geometries
+// which do not name their CRS
+const WPSDefaultCRS = "EPSG:4326"
+
+var crsEPSGRegexp = regexp.MustCompile(`(?i)^(?:EPSG:|urn:ogc:def:crs:EPSG:[0-9.]*:|https?://www\.opengis\.net/def/crs/EPSG/[0-9.]+/)([0-9]+)$`)
+
+var crsWGS84Regexp = regexp.MustCompile(`(?i)^(?:CRS84|urn:ogc:def:crs:OGC:[0-9.]*:CRS84|https?://www\.opengis\.net/def/crs/OGC/[0-9.]+/CRS84)$`)
+
+// ParseWPSCRS parses the name of the CRS of a WPS geometry
+// given as EPSG:nnnn, as an OGC URN or URL of an EPSG code or
+// as CRS84, and returns it as EPSG:nnnn.
+func ParseWPSCRS(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if crsWGS84Regexp.MatchString(name) {
+		return WPSDefaultCRS, nil
+	}
+
+	match := crsEPSGRegexp.FindStringSubmatch(name)
+	if match == nil {
+		return "", fmt.Errorf("unsupported CRS: %s", name)
+	}
+	code, err := strconv.Atoi(match[1])
+	if err != nil {
+		return "", fmt.Errorf("unsupported CRS: %s", name)
+	}
+	return fmt.Sprintf("EPSG:%d", code), nil
+}
+
+// geoJSONCRSName returns the name of the CRS of a GeoJSON
+// document given by its legacy crs member, if any
+func geoJSONCRSName(geoJSON string) (string, error) {
+	var doc struct {
+		CRS *struct {
+			Type       string `json:"type"`
+			Properties struct {
+				Name string `json:"name"`
+			} `json:"properties"`
+		} `json:"crs"`
+	}
+
+	if err := json.Unmarshal([]byte(geoJSON), &doc); err != nil {
+		return "", fmt.Errorf("invalid GeoJSON: %v", err)
+	}
+	if doc.CRS == nil {
+		return "", nil
+	}
+	if doc.CRS.Type != "name" {
+		return "", fmt.Errorf("unsupported GeoJSON crs type: %s", doc.CRS.Type)
+	}
+	return doc.CRS.Properties.Name, nil
+}
+
+// TransformGeometry reprojects a GeoJSON geometry from srcCRS
+// to dstCRS, both given as EPSG:nnnn
+func TransformGeometry(geomJSON []byte, srcCRS string, dstCRS string) ([]byte, error) {
+	geomC := C.CString(string(geomJSON))
+	defer C.free(unsafe.Pointer(geomC))
+	hGeom := C.OGR_G_CreateGeometryFromJson(geomC)
+	if hGeom == nil {
+		return nil, fmt.Errorf("invalid GeoJSON geometry")
+	}
+	defer C.OGR_G_DestroyGeometry(hGeom)
+
+	srcSRS, err := newSpatialReference(srcCRS)
+	if err != nil {
+		return nil, err
+	}
+	defer C.OSRRelease(srcSRS)
+
+	dstSRS, err := newSpatialReference(dstCRS)
+	if err != nil {
+		return nil, err
+	}
+	defer C.OSRRelease(dstSRS)
+
+	C.OGR_G_AssignSpatialReference(hGeom, srcSRS)
+	if C.OGR_G_TransformTo(hGeom, dstSRS) != C.OGRERR_NONE {
+		return nil, fmt.Errorf("failed to transform geometry from %s to %s", srcCRS, dstCRS)
+	}
+
+	jsonC := C.OGR_G_ExportToJson(hGeom)
+	if jsonC == nil {
+		return nil, fmt.Errorf("failed to export geometry")
+	}
+	defer C.free(unsafe.Pointer(jsonC))
+
+	return []byte(C.GoString(jsonC)), nil
+}
+
+// newSpatialReference returns the spatial reference of a CRS
+// given as EPSG:nnnn. EPSG:4326 is the longitude, latitude
+// order of GeoJSON.
+func newSpatialReference(crs string) (C.OGRSpatialReferenceH, error) {
+	if crs == WPSDefaultCRS {
+		wgs84C := C.CString(WGS84WKT)
+		defer C.free(unsafe.Pointer(wgs84C))
+		return C.OSRNewSpatialReference(wgs84C), nil
+	}
+
+	var epsg int
+	if _, err := fmt.Sscanf(crs, "EPSG:%d", &epsg); err != nil {
+		return nil, fmt.Errorf("invalid CRS: %s", crs)
+	}
+
+	hSRS := C.OSRNewSpatialReference(nil)
+	if C.OSRImportFromEPSG(hSRS, C.int(epsg)) != C.OGRERR_NONE {
+		C.OSRRelease(hSRS)
+		return nil, fmt.Errorf("unknown CRS: %s", crs)
+	}
+	return hSRS, nil
+}
@@ -0,0 +1,36 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestParseWPSCRS(t *testing.T) {
+	valid := map[string]string{
+		"EPSG:7855":                                  "EPSG:7855",
+		" epsg:4326 ":                                "EPSG:4326",
+		"urn:ogc:def:crs:EPSG::7844":                 "EPSG:7844",
+		"urn:ogc:def:crs:EPSG:6.6:28355":             "EPSG:28355",
+		"http://www.opengis.net/def/crs/EPSG/0/3577": "EPSG:3577",
+		"urn:ogc:def:crs:OGC:1.3:CRS84":              "EPSG:4326",
+	}
+	for name, expected := range valid {
+		crs, err := ParseWPSCRS(name)
+		if err != nil || crs != expected {
+			t.Errorf("%s: expected %s, got %s, %v", name, expected, crs, err)
+		}
+	}
+
+	for _, name := range []string{"", "EPSG:", "MGA55", "urn:ogc:def:crs:ESRI::102100"} {
+		if _, err := ParseWPSCRS(name); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	name, err := geoJSONCRSName(`{"type":"FeatureCollection","crs":{"type":"name","properties":{"name":"urn:ogc:def:crs:EPSG::7855"}},"features":[]}`)
+	if err != nil || name != "urn:ogc:def:crs:EPSG::7855" {
+		t.Errorf("unexpected GeoJSON CRS: %s, %v", name, err)
+	}
+	if name, err := geoJSONCRSName(`{"type":"FeatureCollection","features":[]}`); err != nil || len(name) != 0 {
+		t.Errorf("expected no GeoJSON CRS, got %s, %v", name, err)
+	}
+}
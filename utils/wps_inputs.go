@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// WPS exception codes reported for invalid inputs
+const (
+	WPSExceptionInvalidParameter = "InvalidParameterValue"
+	WPSExceptionMissingParameter = "MissingParameterValue"
+)
+
+// WPSException is the exception of a WPS ExceptionReport.
+// Locator names the faulty input.
+type WPSException struct {
+	Code    string
+	Locator string
+	Text    string
+}
+
+func (e *WPSException) Error() string {
+	if len(e.Locator) == 0 {
+		return e.Text
+	}
+	return fmt.Sprintf("%s: %s", e.Locator, e.Text)
+}
+
+// wpsRequestParams are the parameters of WPS requests
+// which are not inputs of the processes
+var wpsRequestParams = map[string]bool{
+	"service":              true,
+	"request":              true,
+	"version":              true,
+	"identifier":           true,
+	"language":             true,
+	"start_datetime":       true,
+	"end_datetime":         true,
+	"geometry":             true,
+	"storeexecuteresponse": true,
+	"status":               true,
+	"jobid":                true,
+	"rawdataoutput":        true,
+	"responsedocument":     true,
+}
+
+// wpsLiteralInputs lists the literal inputs that every
+// WPS process accepts whether they are declared or not
+var wpsLiteralInputs = map[string]bool{
+	"statistics":     true,
+	"id_property":    true,
+	"spacing":        true,
+	"period":         true,
+	"aggregate":      true,
+	"anomaly":        true,
+	"baseline_start": true,
+	"baseline_end":   true,
+	"bands":          true,
+	"band_strides":   true,
+	"approx":         true,
+	"partial":        true,
+	"exact":          true,
+	"crs":            true,
+}
+
+// ValidateWPSInputs checks the literal inputs of an Execute
+// request against the literal data declared by the process.
+// Declared inputs must match their data type and allowed
+// values and be present if their MinOccurs is positive. The
+// items of comma separated values are checked one by one
+// against the allowed values. Inputs that are neither
+// declared nor accepted by every process are rejected.
+func ValidateWPSInputs(inputs map[string]string, process Process) error {
+	declared := make(map[string]LitData, len(process.LiteralData))
+	for _, lit := range process.LiteralData {
+		id := strings.ToLower(strings.TrimSpace(lit.Identifier))
+		if wpsRequestParams[id] {
+			continue
+		}
+		declared[id] = lit
+
+		value, found := inputs[id]
+		if !found || len(strings.TrimSpace(value)) == 0 {
+			if lit.MinOccurs > 0 {
+				return &WPSException{WPSExceptionMissingParameter, lit.Identifier, "the input is required"}
+			}
+			continue
+		}
+
+		if err := validateLiteralValue(lit, value); err != nil {
+			return &WPSException{WPSExceptionInvalidParameter, lit.Identifier, err.Error()}
+		}
+	}
+
+	var ids []string
+	for id := range inputs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		if _, isDeclared := declared[id]; !isDeclared && !wpsLiteralInputs[id] {
+			return &WPSException{WPSExceptionInvalidParameter, id, fmt.Sprintf("process %s has no such input", process.Identifier)}
+		}
+	}
+
+	return nil
+}
+
+func validateLiteralValue(lit LitData, value string) error {
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+
+		switch strings.ToLower(lit.DataType) {
+		case "integer", "int", "long", "short", "nonnegativeinteger", "positiveinteger":
+			val, err := strconv.ParseInt(item, 10, 64)
+			if err != nil {
+				return fmt.Errorf("'%s' is not an integer", item)
+			}
+			if (strings.EqualFold(lit.DataType, "nonNegativeInteger") && val < 0) || (strings.EqualFold(lit.DataType, "positiveInteger") && val <= 0) {
+				return fmt.Errorf("'%s' is not a %s", item, lit.DataType)
+			}
+		case "double", "float", "decimal":
+			val, err := strconv.ParseFloat(item, 64)
+			if err != nil || math.IsNaN(val) || math.IsInf(val, 0) {
+				return fmt.Errorf("'%s' is not a number", item)
+			}
+		case "boolean", "bool":
+			if _, err := strconv.ParseBool(item); err != nil {
+				return fmt.Errorf("'%s' is not a boolean", item)
+			}
+		case "datetime", "date":
+			if _, err := ParseWPSDate(item); err != nil {
+				return fmt.Errorf("'%s' is not a date", item)
+			}
+		case "", "string", "anyuri":
+		default:
+			return fmt.Errorf("unsupported data type %s", lit.DataType)
+		}
+
+		if len(lit.AllowedValues) == 0 {
+			continue
+		}
+		allowed := false
+		for _, av := range lit.AllowedValues {
+			if strings.EqualFold(strings.TrimSpace(av), item) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("'%s' is not one of the allowed values: %s", item, strings.Join(lit.AllowedValues, ", "))
+		}
+	}
+	return nil
+}
+
+// SelectBandExpressions returns the band expressions of a
+// data source restricted to the named bands, in the order
+// of the names
+func SelectBandExpressions(bandExpr *BandExpressions, names []string) (*BandExpressions, error) {
+	var selected []string
+	for _, name := range names {
+		found := false
+		for ie, exprName := range bandExpr.ExprNames {
+			if exprName == name {
+				selected = append(selected, bandExpr.ExprText[ie])
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown band: %s", name)
+		}
+	}
+	return ParseBandExpressions(selected)
+}
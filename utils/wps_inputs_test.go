@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestValidateWPSInputs(t *testing.T) {
+	process := Process{Identifier: "geometryDrill", LiteralData: []LitData{
+		{Identifier: "product", DataType: "string", AllowedValues: []string{"ndvi", "evi"}, MinOccurs: 1},
+		{Identifier: "threshold", DataType: "double"},
+		{Identifier: "years", DataType: "integer", AllowedValues: []string{"2017", "2018"}},
+		{Identifier: "masked", DataType: "boolean"},
+	}}
+
+	valid := []map[string]string{
+		{"product": "ndvi"},
+		{"product": "EVI", "threshold": "0.5", "years": "2017, 2018", "masked": "true"},
+		{"product": "ndvi", "statistics": "mean,max", "bands": "red"},
+	}
+	for _, inputs := range valid {
+		if err := ValidateWPSInputs(inputs, process); err != nil {
+			t.Errorf("%v: unexpected error: %v", inputs, err)
+		}
+	}
+
+	invalid := []struct {
+		inputs  map[string]string
+		code    string
+		locator string
+	}{
+		{map[string]string{}, WPSExceptionMissingParameter, "product"},
+		{map[string]string{"product": "savi"}, WPSExceptionInvalidParameter, "product"},
+		{map[string]string{"product": "ndvi", "threshold": "high"}, WPSExceptionInvalidParameter, "threshold"},
+		{map[string]string{"product": "ndvi", "years": "2017,2019"}, WPSExceptionInvalidParameter, "years"},
+		{map[string]string{"product": "ndvi", "masked": "maybe"}, WPSExceptionInvalidParameter, "masked"},
+		{map[string]string{"product": "ndvi", "colour": "red"}, WPSExceptionInvalidParameter, "colour"},
+	}
+	for _, tc := range invalid {
+		err := ValidateWPSInputs(tc.inputs, process)
+		exc, ok := err.(*WPSException)
+		if !ok {
+			t.Errorf("%v: expected a WPS exception, got %v", tc.inputs, err)
+			continue
+		}
+		if exc.Code != tc.code || exc.Locator != tc.locator {
+			t.Errorf("%v: expected %s on %s, got %v", tc.inputs, tc.code, tc.locator, exc)
+		}
+	}
+}
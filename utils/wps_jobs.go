@@ -0,0 +1,140 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// WPS Execute status values as defined by
+// the WPS 1.0.0 specification
+const (
+	WPSProcessAccepted  = "ProcessAccepted"
+	WPSProcessStarted   = "ProcessStarted"
+	WPSProcessSucceeded = "ProcessSucceeded"
+	WPSProcessFailed    = "ProcessFailed"
+)
+
+const wpsJobFileExt = ".xml"
+
+var wpsJobIDRegexp = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// WPSExecuteResponse contains the values used to
+// render the WPS_Execute.tpl template for both
+// synchronous and stored responses.
+type WPSExecuteResponse struct {
+	StatusLocation  string
+	CreationTime    string
+	Status          string
+	PercentComplete int
+	Message         string
+	Outputs         string
+}
+
+// NewWPSExecuteResponse returns a response with the
+// given status whose creation time is the current time.
+func NewWPSExecuteResponse(statusLocation string, status string, message string) *WPSExecuteResponse {
+	return &WPSExecuteResponse{
+		StatusLocation: statusLocation,
+		CreationTime:   time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Status:         status,
+		Message:        message,
+	}
+}
+
+// NewWPSJobID generates a random identifier for
+// a stored WPS Execute response.
+func NewWPSJobID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// ValidWPSJobID reports whether jobID has the
+// format generated by NewWPSJobID.
+func ValidWPSJobID(jobID string) bool {
+	return wpsJobIDRegexp.MatchString(jobID)
+}
+
+// WriteWPSStatus renders the response with the template at
+// templatePath and stores it as the status document of jobID.
+// The document is replaced atomically so that concurrent
+// readers never observe a partially written file.
+func WriteWPSStatus(resultDir string, jobID string, resp *WPSExecuteResponse, templatePath string) error {
+	if !ValidWPSJobID(jobID) {
+		return fmt.Errorf("invalid WPS job id: %s", jobID)
+	}
+
+	var buf bytes.Buffer
+	if err := ExecuteWriteTemplateFile(&buf, resp, templatePath); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(resultDir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating WPS result directory: %v", err)
+	}
+
+	tmpFile, err := ioutil.TempFile(resultDir, jobID+"_")
+	if err != nil {
+		return fmt.Errorf("error creating WPS status file: %v", err)
+	}
+
+	_, err = tmpFile.Write(buf.Bytes())
+	if errClose := tmpFile.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return fmt.Errorf("error writing WPS status file: %v", err)
+	}
+
+	return os.Rename(tmpFile.Name(), filepath.Join(resultDir, jobID+wpsJobFileExt))
+}
+
+// ReadWPSStatus returns the status document stored for jobID.
+func ReadWPSStatus(resultDir string, jobID string) ([]byte, error) {
+	if !ValidWPSJobID(jobID) {
+		return nil, fmt.Errorf("invalid WPS job id: %s", jobID)
+	}
+
+	doc, err := ioutil.ReadFile(filepath.Join(resultDir, jobID+wpsJobFileExt))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("WPS job %s not found or expired", jobID)
+	}
+	return doc, err
+}
+
+// PurgeWPSJobs removes the stored status documents that
+// have not been updated within the retention period.
+func PurgeWPSJobs(resultDir string, retention time.Duration) error {
+	files, err := ioutil.ReadDir(resultDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	expiry := time.Now().Add(-retention)
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), wpsJobFileExt) {
+			continue
+		}
+
+		jobID := strings.TrimSuffix(f.Name(), wpsJobFileExt)
+		if ValidWPSJobID(jobID) && f.ModTime().Before(expiry) {
+			os.Remove(filepath.Join(resultDir, f.Name()))
+		}
+	}
+
+	return nil
+}
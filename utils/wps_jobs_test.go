@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWPSJobStatus(t *testing.T) {
+	resultDir, err := ioutil.TempDir("", "wps_jobs_test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(resultDir)
+
+	tplPath := filepath.Join(resultDir, "status.tpl")
+	err = ioutil.WriteFile(tplPath, []byte(`{{ .Status }} {{ .PercentComplete }}`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write template: %v", err)
+	}
+
+	jobID, err := NewWPSJobID()
+	if err != nil || !ValidWPSJobID(jobID) {
+		t.Fatalf("invalid job id: %v, %v", jobID, err)
+	}
+
+	resp := NewWPSExecuteResponse("", WPSProcessStarted, "")
+	resp.PercentComplete = 50
+	if err := WriteWPSStatus(resultDir, jobID, resp, tplPath); err != nil {
+		t.Fatalf("failed to write status: %v", err)
+	}
+
+	doc, err := ReadWPSStatus(resultDir, jobID)
+	if err != nil {
+		t.Fatalf("failed to read status: %v", err)
+	}
+	if strings.TrimSpace(string(doc)) != "ProcessStarted 50" {
+		t.Errorf("unexpected status document: %s", doc)
+	}
+
+	if _, err := ReadWPSStatus(resultDir, "../status"); err == nil {
+		t.Errorf("expected error for invalid job id")
+	}
+
+	if err := PurgeWPSJobs(resultDir, time.Hour); err != nil {
+		t.Fatalf("failed to purge jobs: %v", err)
+	}
+	if _, err := ReadWPSStatus(resultDir, jobID); err != nil {
+		t.Errorf("job purged before its retention period: %v", err)
+	}
+
+	expired := time.Now().Add(-2 * time.Hour)
+	os.Chtimes(filepath.Join(resultDir, jobID+wpsJobFileExt), expired, expired)
+	if err := PurgeWPSJobs(resultDir, time.Hour); err != nil {
+		t.Fatalf("failed to purge jobs: %v", err)
+	}
+	if _, err := ReadWPSStatus(resultDir, jobID); err == nil {
+		t.Errorf("expired job has not been purged")
+	}
+}
@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// JSONExecute is an execute request following the OGC API -
+// Processes JSON encoding. Inputs are keyed by identifier.
+type JSONExecute struct {
+	Process  string                     `json:"process"`
+	ID       string                     `json:"id"`
+	Inputs   map[string]json.RawMessage `json:"inputs"`
+	Outputs  map[string]JSONOutput      `json:"outputs"`
+	Response string                     `json:"response"`
+	Mode     string                     `json:"mode"`
+}
+
+// JSONOutput requests the format of a process output
+type JSONOutput struct {
+	Format struct {
+		MediaType string `json:"mediaType"`
+	} `json:"format"`
+	TransmissionMode string `json:"transmissionMode"`
+}
+
+// JSONFieldError reports an invalid field of a JSON
+// execute request by its path, such as inputs.geometry
+type JSONFieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *JSONFieldError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// ParseJSONExecute parses a JSON execute request into the
+// parameters that ParsePost returns for the XML Execute
+// document so that both are checked by WPSParamsChecker.
+// The geometry input is a GeoJSON FeatureCollection, Feature
+// or geometry and the datetime inputs are ISO dates.
+func ParseJSONExecute(body []byte) (map[string][]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+
+	var exec JSONExecute
+	if err := dec.Decode(&exec); err != nil {
+		return map[string][]string{}, fmt.Errorf("invalid JSON execute request: %v", err)
+	}
+
+	identifier := exec.Process
+	if len(identifier) == 0 {
+		identifier = exec.ID
+	}
+	if len(strings.TrimSpace(identifier)) == 0 {
+		return map[string][]string{}, &JSONFieldError{"process", "a process identifier is required"}
+	}
+
+	parsedBody := map[string][]string{"service": []string{"WPS"},
+		"request":    []string{"Execute"},
+		"version":    []string{"1.0.0"},
+		"identifier": []string{strings.TrimSpace(identifier)}}
+
+	switch exec.Mode {
+	case "", "sync", "auto":
+	case "async":
+		parsedBody["storeexecuteresponse"] = []string{"true"}
+		parsedBody["status"] = []string{"true"}
+	default:
+		return map[string][]string{}, &JSONFieldError{"mode", fmt.Sprintf("unsupported mode '%s', expected sync or async", exec.Mode)}
+	}
+
+	if len(exec.Outputs) > 1 {
+		return map[string][]string{}, &JSONFieldError{"outputs", "a single output can be requested"}
+	}
+	for name, output := range exec.Outputs {
+		mimeType := strings.TrimSpace(output.Format.MediaType)
+		if len(mimeType) == 0 {
+			continue
+		}
+		if strings.ContainsAny(mimeType, "@=") {
+			return map[string][]string{}, &JSONFieldError{"outputs." + name + ".format.mediaType", fmt.Sprintf("invalid media type '%s'", output.Format.MediaType)}
+		}
+		mimeType = "Result@mimeType=" + mimeType
+
+		switch exec.Response {
+		case "raw":
+			parsedBody["rawdataoutput"] = []string{mimeType}
+		case "", "document":
+			parsedBody["responsedocument"] = []string{mimeType}
+		}
+	}
+
+	switch exec.Response {
+	case "", "raw", "document":
+	default:
+		return map[string][]string{}, &JSONFieldError{"response", fmt.Sprintf("unsupported response '%s', expected raw or document", exec.Response)}
+	}
+
+	// Inputs are processed in a stable order so that the
+	// first error reported does not depend on the map order
+	var names []string
+	for name := range exec.Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value := jsonInputValue(exec.Inputs[name])
+		inputID := strings.ToLower(strings.TrimSpace(name))
+		field := "inputs." + name
+
+		switch {
+		case inputID == "geometry":
+			featCol, err := jsonFeatureCollection(value)
+			if err != nil {
+				return map[string][]string{}, &JSONFieldError{field, err.Error()}
+			}
+			parsedBody["geometry"] = []string{fmt.Sprintf(`geometry=%s`, featCol)}
+
+		case inputID == "start_datetime" || inputID == "end_datetime":
+			var text string
+			if err := json.Unmarshal(value, &text); err != nil {
+				return map[string][]string{}, &JSONFieldError{field, "expected an ISO date string"}
+			}
+			date, err := ParseWPSDate(text)
+			if err != nil {
+				return map[string][]string{}, &JSONFieldError{field, fmt.Sprintf("invalid date '%s'", text)}
+			}
+			timestamp, _ := json.Marshal(map[string]interface{}{"properties": map[string]interface{}{"timestamp": map[string]string{"date-time": date.Format("2006-01-02T15:04")}}})
+			parsedBody[inputID] = []string{string(timestamp)}
+
+		case wpsRequestParams[inputID] || len(inputID) == 0:
+			return map[string][]string{}, &JSONFieldError{field, "not a process input"}
+
+		default:
+			literal, err := jsonLiteralValue(value)
+			if err != nil {
+				return map[string][]string{}, &JSONFieldError{field, err.Error()}
+			}
+			parsedBody[inputID] = []string{literal}
+		}
+	}
+
+	return parsedBody, nil
+}
+
+// jsonInputValue unwraps the value of a qualified input
+// such as {"value": ..., "mediaType": ...}
+func jsonInputValue(input json.RawMessage) json.RawMessage {
+	var qualified map[string]json.RawMessage
+	if err := json.Unmarshal(input, &qualified); err != nil {
+		return input
+	}
+	if value, found := qualified["value"]; found {
+		if _, isGeoJSON := qualified["type"]; !isGeoJSON {
+			return value
+		}
+	}
+	return input
+}
+
+// jsonFeatureCollection returns the GeoJSON FeatureCollection
+// of a FeatureCollection, a Feature or a bare geometry. The crs
+// member of a Feature or geometry is moved to the collection.
+// The semicolons and equal signs separating the parameters of
+// a WPS geometry input cannot appear in the collection.
+func jsonFeatureCollection(value json.RawMessage) (string, error) {
+	var obj struct {
+		Type string          `json:"type"`
+		CRS  json.RawMessage `json:"crs"`
+	}
+	if err := json.Unmarshal(value, &obj); err != nil {
+		return "", fmt.Errorf("expected a GeoJSON object")
+	}
+
+	crs := ""
+	if len(obj.CRS) > 0 {
+		crs = fmt.Sprintf(`"crs":%s,`, obj.CRS)
+	}
+
+	var featCol []byte
+	switch obj.Type {
+	case "FeatureCollection":
+		featCol = value
+	case "Feature":
+		featCol = []byte(fmt.Sprintf(`{"type":"FeatureCollection",%s"features":[%s]}`, crs, value))
+	case "Point", "LineString", "Polygon", "MultiPolygon":
+		featCol = []byte(fmt.Sprintf(`{"type":"FeatureCollection",%s"features":[{"type":"Feature","properties":{},"geometry":%s}]}`, crs, value))
+	case "":
+		return "", fmt.Errorf("missing GeoJSON type")
+	default:
+		return "", fmt.Errorf("unsupported GeoJSON type '%s'", obj.Type)
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, featCol); err != nil {
+		return "", fmt.Errorf("invalid GeoJSON: %v", err)
+	}
+	if bytes.ContainsAny(compact.Bytes(), ";=") {
+		return "", fmt.Errorf("GeoJSON cannot contain ';' or '=' characters")
+	}
+	return compact.String(), nil
+}
+
+// jsonLiteralValue returns the text of a literal input given
+// as a string, a number, a boolean or an array of them, which
+// is joined with commas.
+func jsonLiteralValue(value json.RawMessage) (string, error) {
+	var raw interface{}
+	if err := json.Unmarshal(value, &raw); err != nil {
+		return "", fmt.Errorf("invalid value: %v", err)
+	}
+
+	scalar := func(v interface{}) (string, error) {
+		switch val := v.(type) {
+		case string:
+			return val, nil
+		case float64:
+			return strconv.FormatFloat(val, 'f', -1, 64), nil
+		case bool:
+			return strconv.FormatBool(val), nil
+		}
+		return "", fmt.Errorf("expected a string, a number or a boolean")
+	}
+
+	if values, isArray := raw.([]interface{}); isArray {
+		texts := make([]string, len(values))
+		for i, v := range values {
+			text, err := scalar(v)
+			if err != nil {
+				return "", fmt.Errorf("item %d: %v", i, err)
+			}
+			texts[i] = text
+		}
+		return strings.Join(texts, ","), nil
+	}
+
+	return scalar(raw)
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseJSONExecute(t *testing.T) {
+	body := `{
+		"process": "geometryDrill",
+		"inputs": {
+			"geometry": {"type": "Polygon", "coordinates": [[[149, -35], [149.1, -35], [149.1, -35.1], [149, -35]]]},
+			"start_datetime": "2018-01-01T00:00:00.000Z",
+			"end_datetime": {"value": "2018-12-31"},
+			"statistics": ["mean", "p90"],
+			"spacing": 250
+		},
+		"outputs": {"result": {"format": {"mediaType": "text/csv"}}},
+		"response": "raw",
+		"mode": "async"
+	}`
+
+	params, err := ParseJSONExecute([]byte(body))
+	if err != nil {
+		t.Fatalf("failed to parse JSON execute request: %v", err)
+	}
+
+	expected := map[string]string{
+		"identifier":           "geometryDrill",
+		"request":              "Execute",
+		"statistics":           "mean,p90",
+		"spacing":              "250",
+		"rawdataoutput":        "Result@mimeType=text/csv",
+		"storeexecuteresponse": "true",
+		"end_datetime":         `{"properties":{"timestamp":{"date-time":"2018-12-31T00:00"}}}`,
+	}
+	for key, val := range expected {
+		if len(params[key]) != 1 || params[key][0] != val {
+			t.Errorf("%s: expected %s, got %v", key, val, params[key])
+		}
+	}
+	if !strings.HasPrefix(params["geometry"][0], `geometry={"type":"FeatureCollection","features":[{"type":"Feature"`) {
+		t.Errorf("unexpected geometry: %v", params["geometry"])
+	}
+
+	invalid := map[string]string{
+		`{"inputs": {}}`: "process",
+		`{"process": "p", "inputs": {"geometry": {"type": "Circle"}}}`:              "inputs.geometry",
+		`{"process": "p", "inputs": {"start_datetime": "yesterday"}}`:               "inputs.start_datetime",
+		`{"process": "p", "inputs": {"statistics": {"stat": "mean"}}}`:              "inputs.statistics",
+		`{"process": "p", "inputs": {"service": "WMS"}}`:                            "inputs.service",
+		`{"process": "p", "mode": "later"}`:                                         "mode",
+		`{"process": "p", "outputs": {"result": {"format": {"mediaType": "a=b"}}}}`: "outputs.result.format.mediaType",
+	}
+	for body, field := range invalid {
+		_, err := ParseJSONExecute([]byte(body))
+		fieldErr, ok := err.(*JSONFieldError)
+		if !ok {
+			t.Errorf("%s: expected a field error, got %v", body, err)
+			continue
+		}
+		if fieldErr.Field != field {
+			t.Errorf("%s: expected an error on %s, got %v", body, field, fieldErr)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"testing"
+)
+
+func TestExtractFeatureIDs(t *testing.T) {
+	featCol := `{"type":"FeatureCollection","features":[
+		{"type":"Feature","id":"lga-1","properties":{"code":10050,"name":"Albury"},"geometry":null},
+		{"type":"Feature","id":7,"properties":{"code":10110,"name":"Armidale"},"geometry":null},
+		{"type":"Feature","properties":{"code":10130,"name":"Ballina"},"geometry":null}]}`
+
+	ids, err := ExtractFeatureIDs(featCol, "")
+	if err != nil {
+		t.Fatalf("failed to extract feature ids: %v", err)
+	}
+	expected := []string{"lga-1", "7", "2"}
+	for i := range expected {
+		if ids[i] != expected[i] {
+			t.Errorf("feature %d: expected id %s, got %s", i, expected[i], ids[i])
+		}
+	}
+
+	ids, err = ExtractFeatureIDs(featCol, "name")
+	if err != nil {
+		t.Fatalf("failed to extract feature ids: %v", err)
+	}
+	if ids[0] != "Albury" || ids[2] != "Ballina" {
+		t.Errorf("unexpected property ids: %v", ids)
+	}
+
+	ids, err = ExtractFeatureIDs(featCol, "code")
+	if err != nil || ids[1] != "10110" {
+		t.Errorf("unexpected numeric property ids: %v, %v", ids, err)
+	}
+
+	if _, err = ExtractFeatureIDs(featCol, "state"); err == nil {
+		t.Errorf("expected error for missing id property")
+	}
+}
@@ -0,0 +1,336 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultQuantileSketchSize is the maximum number of
+// centroids kept by the quantile sketch of a zone
+const DefaultQuantileSketchSize = 200
+
+// DefaultZonalStatistic is the statistic computed
+// by drill operations when none is requested
+const DefaultZonalStatistic = "mean"
+
+var percentileRegexp = regexp.MustCompile(`^p(100|[0-9]{1,2}(\.[0-9]+)?)$`)
+
+// ZonalStats contains mergeable summary statistics of the
+// valid pixels of a zone. Quantiles are estimated from a
+// sketch made of centroids sorted by value, each of them
+// summarising CentroidCounts pixels. Partial statistics
+// computed over different granules can be combined with
+// Merge without loss for the count, mean, variance and
+// extrema.
+type ZonalStats struct {
+	Count int64
+	// Weight is the sum of the weights of the pixels of
+	// weighted statistics and zero otherwise, in which case
+	// every pixel weighs one
+	Weight float64
+	Mean   float64
+	// M2 is the sum of squared deviations from the mean
+	M2             float64
+	Min            float64
+	Max            float64
+	Centroids      []float64
+	CentroidCounts []int64
+}
+
+// NewZonalStats computes the statistics of values. A quantile
+// sketch of at most sketchSize centroids is built if sketchSize
+// is greater than zero. values is sorted in place.
+func NewZonalStats(values []float64, sketchSize int) *ZonalStats {
+	zs := &ZonalStats{}
+	if len(values) == 0 {
+		return zs
+	}
+
+	sort.Float64s(values)
+
+	for _, val := range values {
+		zs.Count++
+		delta := val - zs.Mean
+		zs.Mean += delta / float64(zs.Count)
+		zs.M2 += delta * (val - zs.Mean)
+	}
+	zs.Min = values[0]
+	zs.Max = values[len(values)-1]
+
+	if sketchSize > 0 {
+		for i, val := range values {
+			if i > 0 && val == values[i-1] {
+				zs.CentroidCounts[len(zs.CentroidCounts)-1]++
+				continue
+			}
+			zs.Centroids = append(zs.Centroids, val)
+			zs.CentroidCounts = append(zs.CentroidCounts, 1)
+		}
+		zs.compress(sketchSize)
+	}
+
+	return zs
+}
+
+// NewWeightedZonalStats computes the statistics of values
+// weighted by the fraction of their pixel covered by the zone.
+// Values of zero weight are ignored. The extrema and quantile
+// sketch are computed from the values of positive weight
+// regardless of their weight. values and weights are left
+// unchanged.
+func NewWeightedZonalStats(values []float64, weights []float64, sketchSize int) *ZonalStats {
+	zs := &ZonalStats{}
+	var covered []float64
+	for i, val := range values {
+		w := weights[i]
+		if w <= 0 {
+			continue
+		}
+		covered = append(covered, val)
+
+		zs.Weight += w
+		delta := val - zs.Mean
+		zs.Mean += delta * w / zs.Weight
+		zs.M2 += w * delta * (val - zs.Mean)
+	}
+	if len(covered) == 0 {
+		return &ZonalStats{}
+	}
+
+	unweighted := NewZonalStats(covered, sketchSize)
+	zs.Count = unweighted.Count
+	zs.Min = unweighted.Min
+	zs.Max = unweighted.Max
+	zs.Centroids = unweighted.Centroids
+	zs.CentroidCounts = unweighted.CentroidCounts
+	return zs
+}
+
+// weight returns the total weight of the pixels
+func (zs *ZonalStats) weight() float64 {
+	if zs.Weight > 0 {
+		return zs.Weight
+	}
+	return float64(zs.Count)
+}
+
+// Merge combines the statistics of other into zs. The
+// merged quantile sketch is kept within sketchSize centroids.
+func (zs *ZonalStats) Merge(other *ZonalStats, sketchSize int) {
+	if other == nil || other.Count == 0 {
+		return
+	}
+
+	if zs.Count == 0 {
+		zs.Min = other.Min
+		zs.Max = other.Max
+	} else {
+		zs.Min = math.Min(zs.Min, other.Min)
+		zs.Max = math.Max(zs.Max, other.Max)
+	}
+
+	// Unweighted statistics merged with weighted ones
+	// weigh the number of their pixels
+	weighted := zs.Weight > 0 || other.Weight > 0
+	w, otherW := zs.weight(), other.weight()
+	total := w + otherW
+	delta := other.Mean - zs.Mean
+	zs.M2 += other.M2 + delta*delta*w*otherW/total
+	zs.Mean += delta * otherW / total
+	zs.Count += other.Count
+	if weighted {
+		zs.Weight = total
+	}
+
+	if len(other.Centroids) == 0 {
+		return
+	}
+
+	centroids := make([]float64, 0, len(zs.Centroids)+len(other.Centroids))
+	counts := make([]int64, 0, cap(centroids))
+	i, j := 0, 0
+	for i < len(zs.Centroids) || j < len(other.Centroids) {
+		if j >= len(other.Centroids) || (i < len(zs.Centroids) && zs.Centroids[i] <= other.Centroids[j]) {
+			centroids = append(centroids, zs.Centroids[i])
+			counts = append(counts, zs.CentroidCounts[i])
+			i++
+		} else {
+			centroids = append(centroids, other.Centroids[j])
+			counts = append(counts, other.CentroidCounts[j])
+			j++
+		}
+	}
+	zs.Centroids = centroids
+	zs.CentroidCounts = counts
+	zs.compress(sketchSize)
+}
+
+// compress merges neighbouring centroids so that each of
+// the resulting sketchSize centroids summarises a similar
+// number of pixels
+func (zs *ZonalStats) compress(sketchSize int) {
+	if sketchSize <= 0 || len(zs.Centroids) <= sketchSize {
+		return
+	}
+
+	total := int64(0)
+	for _, c := range zs.CentroidCounts {
+		total += c
+	}
+
+	centroids := []float64{}
+	counts := []int64{}
+	cum := int64(0)
+	lastBucket := -1
+	for i, c := range zs.CentroidCounts {
+		bucket := int((float64(cum) + float64(c)/2) * float64(sketchSize) / float64(total))
+		cum += c
+		if bucket != lastBucket {
+			centroids = append(centroids, zs.Centroids[i])
+			counts = append(counts, c)
+			lastBucket = bucket
+			continue
+		}
+
+		ic := len(centroids) - 1
+		newCount := counts[ic] + c
+		centroids[ic] += (zs.Centroids[i] - centroids[ic]) * float64(c) / float64(newCount)
+		counts[ic] = newCount
+	}
+
+	zs.Centroids = centroids
+	zs.CentroidCounts = counts
+}
+
+// StdDev returns the population standard deviation
+func (zs *ZonalStats) StdDev() float64 {
+	if zs.Count == 0 {
+		return math.NaN()
+	}
+	return math.Sqrt(zs.M2 / zs.weight())
+}
+
+// Quantile estimates the q quantile, 0 <= q <= 1, by linear
+// interpolation between the centres of the sketch centroids.
+// NaN is returned if the statistics contain no sketch.
+func (zs *ZonalStats) Quantile(q float64) float64 {
+	if zs.Count == 0 || len(zs.Centroids) == 0 {
+		return math.NaN()
+	}
+
+	total := int64(0)
+	for _, c := range zs.CentroidCounts {
+		total += c
+	}
+	target := q * float64(total)
+
+	prevPos, prevVal := 0.0, zs.Min
+	cum := int64(0)
+	for i, c := range zs.CentroidCounts {
+		pos := float64(cum) + float64(c)/2
+		if target <= pos {
+			if pos == prevPos {
+				return zs.Centroids[i]
+			}
+			return prevVal + (zs.Centroids[i]-prevVal)*(target-prevPos)/(pos-prevPos)
+		}
+		prevPos, prevVal = pos, zs.Centroids[i]
+		cum += c
+	}
+
+	if float64(total) == prevPos {
+		return zs.Max
+	}
+	return prevVal + (zs.Max-prevVal)*(target-prevPos)/(float64(total)-prevPos)
+}
+
+// Statistic returns the value of the named statistic as
+// accepted by ParseZonalStatistics. The boolean result is
+// false if the statistic cannot be computed.
+func (zs *ZonalStats) Statistic(name string) (float64, bool) {
+	if zs.Count == 0 {
+		return 0, false
+	}
+
+	var val float64
+	switch name {
+	case "mean":
+		val = zs.Mean
+	case "sum":
+		val = zs.Mean * zs.weight()
+	case "min":
+		val = zs.Min
+	case "max":
+		val = zs.Max
+	case "stddev":
+		val = zs.StdDev()
+	case "median":
+		val = zs.Quantile(0.5)
+	default:
+		pct, err := percentileValue(name)
+		if err != nil {
+			return 0, false
+		}
+		val = zs.Quantile(pct / 100)
+	}
+
+	return val, !math.IsNaN(val)
+}
+
+// ParseZonalStatistics parses a comma separated list of
+// statistic names. Valid names are mean, sum, min, max,
+// stddev, median and percentiles written as pNN, e.g. p10 or p97.5.
+// An empty list defaults to the mean.
+func ParseZonalStatistics(text string) ([]string, error) {
+	var stats []string
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(text, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 || seen[name] {
+			continue
+		}
+
+		switch name {
+		case "mean", "sum", "min", "max", "stddev", "median":
+		default:
+			if _, err := percentileValue(name); err != nil {
+				return nil, err
+			}
+		}
+
+		seen[name] = true
+		stats = append(stats, name)
+	}
+
+	if len(stats) == 0 {
+		stats = []string{DefaultZonalStatistic}
+	}
+	return stats, nil
+}
+
+// ZonalStatisticsNeedSketch reports whether any of the
+// statistics is a quantile
+func ZonalStatisticsNeedSketch(stats []string) bool {
+	for _, name := range stats {
+		if name == "median" || percentileRegexp.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func percentileValue(name string) (float64, error) {
+	if !percentileRegexp.MatchString(name) {
+		return 0, fmt.Errorf("unsupported statistic: %s", name)
+	}
+
+	pct, err := strconv.ParseFloat(name[1:], 64)
+	if err != nil || pct > 100 {
+		return 0, fmt.Errorf("invalid percentile: %s", name)
+	}
+	return pct, nil
+}
@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestZonalStatsMerge(t *testing.T) {
+	values := make([]float64, 1000)
+	for i := range values {
+		values[i] = float64(i + 1)
+	}
+
+	// Split the values unevenly to emulate partial
+	// results coming from different granules
+	merged := NewZonalStats(append([]float64{}, values[700:]...), 50)
+	merged.Merge(NewZonalStats(append([]float64{}, values[:300]...), 50), 50)
+	merged.Merge(NewZonalStats(append([]float64{}, values[300:700]...), 50), 50)
+	merged.Merge(&ZonalStats{}, 50)
+
+	if merged.Count != 1000 {
+		t.Fatalf("expected count 1000, got %d", merged.Count)
+	}
+	if len(merged.Centroids) > 50 {
+		t.Errorf("sketch exceeds its size: %d centroids", len(merged.Centroids))
+	}
+
+	exact := NewZonalStats(values, 0)
+	cases := map[string]float64{
+		"mean":   exact.Mean,
+		"min":    1,
+		"max":    1000,
+		"stddev": exact.StdDev(),
+		"median": 500.5,
+		"p10":    100.5,
+		"p97.5":  975.5,
+	}
+
+	for name, expected := range cases {
+		val, ok := merged.Statistic(name)
+		if !ok {
+			t.Errorf("statistic %s not available", name)
+			continue
+		}
+		if math.Abs(val-expected) > 0.01*1000 {
+			t.Errorf("statistic %s: expected %f, got %f", name, expected, val)
+		}
+	}
+
+	if _, ok := exact.Statistic("median"); ok {
+		t.Errorf("expected median to be unavailable without a sketch")
+	}
+}
+
+func TestZonalStatsExactQuantiles(t *testing.T) {
+	zs := NewZonalStats([]float64{4, 1, 3, 2}, DefaultQuantileSketchSize)
+	if median, _ := zs.Statistic("median"); median != 2.5 {
+		t.Errorf("expected median 2.5, got %f", median)
+	}
+	if p0, _ := zs.Statistic("p0"); p0 != 1 {
+		t.Errorf("expected p0 1, got %f", p0)
+	}
+	if p100, _ := zs.Statistic("p100"); p100 != 4 {
+		t.Errorf("expected p100 4, got %f", p100)
+	}
+}
+
+func TestWeightedZonalStats(t *testing.T) {
+	// A zone covering a quarter of the first pixel, all of
+	// the second one and missing the third one
+	values := []float64{8, 2, 100}
+	weights := []float64{0.25, 1, 0}
+
+	zs := NewWeightedZonalStats(values, weights, 0)
+	if zs.Count != 2 || zs.Weight != 1.25 {
+		t.Fatalf("expected 2 pixels weighing 1.25, got %d, %f", zs.Count, zs.Weight)
+	}
+	if mean, _ := zs.Statistic("mean"); math.Abs(mean-3.2) > 1e-9 {
+		t.Errorf("expected mean 3.2, got %f", mean)
+	}
+	if sum, _ := zs.Statistic("sum"); math.Abs(sum-4) > 1e-9 {
+		t.Errorf("expected sum 4, got %f", sum)
+	}
+	if stddev, _ := zs.Statistic("stddev"); math.Abs(stddev-2.4) > 1e-9 {
+		t.Errorf("expected stddev 2.4, got %f", stddev)
+	}
+	if zs.Max != 8 {
+		t.Errorf("expected max 8, got %f", zs.Max)
+	}
+
+	// Merging the halves of the zone gives the same statistics
+	merged := NewWeightedZonalStats(values[:1], weights[:1], 0)
+	merged.Merge(NewWeightedZonalStats(values[1:], weights[1:], 0), 0)
+	if math.Abs(merged.Mean-zs.Mean) > 1e-9 || math.Abs(merged.M2-zs.M2) > 1e-9 || merged.Weight != zs.Weight {
+		t.Errorf("unexpected merged statistics: %+v", merged)
+	}
+
+	unweighted := NewZonalStats([]float64{1, 2, 3}, 0)
+	if sum, _ := unweighted.Statistic("sum"); sum != 6 {
+		t.Errorf("expected sum 6, got %f", sum)
+	}
+}
+
+func TestParseZonalStatistics(t *testing.T) {
+	stats, err := ParseZonalStatistics(" Mean, max,p90,max ")
+	if err != nil {
+		t.Fatalf("failed to parse statistics: %v", err)
+	}
+	if len(stats) != 3 || stats[0] != "mean" || stats[1] != "max" || stats[2] != "p90" {
+		t.Errorf("unexpected statistics: %v", stats)
+	}
+
+	stats, err = ParseZonalStatistics("")
+	if err != nil || len(stats) != 1 || stats[0] != DefaultZonalStatistic {
+		t.Errorf("expected default statistic, got %v, %v", stats, err)
+	}
+
+	for _, invalid := range []string{"mode", "p101", "p-1"} {
+		if _, err := ParseZonalStatistics(invalid); err == nil {
+			t.Errorf("expected error for %s", invalid)
+		}
+	}
+}
@@ -15,18 +15,26 @@ import (
 	"image"
 	"log"
 	"math"
+	"sort"
 	"unsafe"
 
 	"encoding/json"
 
 	geo "github.com/nci/geometry"
+	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
 )
 
+// DrillFileDescriptor describes the window of a dataset
+// covering a geometry. The pixels of the window touched by
+// the geometry are set in Mask. Coverage holds the fraction
+// of the area of each pixel covered by the geometry if the
+// exact coverage has been requested and is nil otherwise.
 type DrillFileDescriptor struct {
 	OffX, OffY     int32
 	CountX, CountY int32
 	Mask           *image.Gray
+	Coverage       []float64
 }
 
 var cWGS84WKT = C.CString(`GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],TOWGS84[0,0,0,0,0,0,0],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9108"]],AUTHORITY["EPSG","4326"]]","proj4":"+proj=longlat +ellps=WGS84 +towgs84=0,0,0,0,0,0,0 +no_defs `)
@@ -70,18 +78,226 @@ func DrillDataset(in *pb.GeoRPCGranule) *pb.Result {
 	}
 	defer C.OGR_G_DestroyGeometry(geom)
 
+	// Geometries are in WGS84 unless the request names
+	// another CRS
 	selSRS := C.OSRNewSpatialReference(cWGS84WKT)
+	if in.EPSG > 0 && in.EPSG != 4326 {
+		C.OSRDestroySpatialReference(selSRS)
+		selSRS = C.OSRNewSpatialReference(nil)
+		if C.OSRImportFromEPSG(selSRS, C.int(in.EPSG)) != C.OGRERR_NONE {
+			C.OSRDestroySpatialReference(selSRS)
+			msg := fmt.Sprintf("Unknown geometry CRS: EPSG:%d", in.EPSG)
+			log.Println(msg)
+			return &pb.Result{Error: msg}
+		}
+	}
 	defer C.OSRDestroySpatialReference(selSRS)
 
 	C.OGR_G_AssignSpatialReference(geom, selSRS)
 
-	return readData(ds, in.Bands, geom, int(in.BandStrides))
+	if in.Histogram {
+		return readHistogram(ds, in.Bands, geom)
+	}
+
+	if in.ProfileSpacing > 0 {
+		return readProfile(ds, in.Bands, geomGeoJSON, in.ProfileSpacing)
+	}
+
+	return readData(ds, in.Bands, geom, int(in.BandStrides), int(in.SketchSize), in.Exact)
+}
+
+// readHistogram counts the pixels of each class inside the
+// geometry for every band. The area of the pixels of each
+// class is returned in square kilometres.
+func readHistogram(ds C.GDALDatasetH, bands []int32, geom C.OGRGeometryH) *pb.Result {
+	dsDscr := getDrillFileDescriptor(ds, geom, false)
+
+	bandH := C.GDALGetRasterBand(ds, C.int(1))
+	var hasNoData C.int
+	nodata := float64(C.GDALGetRasterNoDataValue(bandH, &hasNoData))
+
+	rowAreas, err := pixelRowAreas(ds, dsDscr.OffY, dsDscr.CountY)
+	if err != nil {
+		return &pb.Result{Error: err.Error()}
+	}
+
+	histograms := make([]*pb.TimeSeries, len(bands))
+	bandSize := int(dsDscr.CountX * dsDscr.CountY)
+	dataBuf := make([]float64, bandSize)
+	for ib, band := range bands {
+		hBand := C.GDALGetRasterBand(ds, C.int(band))
+		if hBand == nil {
+			return &pb.Result{Error: fmt.Sprintf("invalid band: %d", band)}
+		}
+
+		gerr := C.GDALRasterIO(hBand, C.GF_Read, C.int(dsDscr.OffX), C.int(dsDscr.OffY), C.int(dsDscr.CountX), C.int(dsDscr.CountY), unsafe.Pointer(&dataBuf[0]), C.int(dsDscr.CountX), C.int(dsDscr.CountY), C.GDT_Float64, 0, 0)
+		if gerr != 0 {
+			return &pb.Result{Error: fmt.Sprintf("failed to read band %d", band)}
+		}
+
+		classes := make(map[float64]*pb.ClassCount)
+		var classValues []float64
+		total := int32(0)
+		for i := 0; i < bandSize; i++ {
+			val := dataBuf[i]
+			if dsDscr.Mask.Pix[i] != 255 || (hasNoData != 0 && val == nodata) || math.IsNaN(val) {
+				continue
+			}
+
+			class, found := classes[val]
+			if !found {
+				class = &pb.ClassCount{Value: val}
+				classes[val] = class
+				classValues = append(classValues, val)
+			}
+			class.Count++
+			class.Area += rowAreas[i/int(dsDscr.CountX)]
+			total++
+		}
+
+		sort.Float64s(classValues)
+		histograms[ib] = &pb.TimeSeries{Count: total}
+		for _, val := range classValues {
+			histograms[ib].ClassCounts = append(histograms[ib].ClassCounts, classes[val])
+		}
+	}
+
+	return &pb.Result{TimeSeries: histograms, Error: "OK"}
+}
+
+// readProfile samples every band at points spaced along a
+// LineString. The values of the samples falling outside the
+// dataset or on nodata pixels are NaN.
+func readProfile(ds C.GDALDatasetH, bands []int32, geomGeoJSON []byte, spacing float64) *pb.Result {
+	coords, err := utils.LineStringCoordinates(geomGeoJSON)
+	if err != nil {
+		return &pb.Result{Error: err.Error()}
+	}
+	samples, err := utils.SampleLineString(coords, spacing)
+	if err != nil {
+		return &pb.Result{Error: err.Error()}
+	}
+
+	xs := make([]float64, len(samples))
+	ys := make([]float64, len(samples))
+	for i, s := range samples {
+		xs[i], ys[i] = s.Lon, s.Lat
+	}
+
+	if C.GoString(C.GDALGetProjectionRef(ds)) != "" {
+		desSRS := C.OSRNewSpatialReference(C.GDALGetProjectionRef(ds))
+		defer C.OSRDestroySpatialReference(desSRS)
+		srcSRS := C.OSRNewSpatialReference(cWGS84WKT)
+		defer C.OSRDestroySpatialReference(srcSRS)
+		trans := C.OCTNewCoordinateTransformation(srcSRS, desSRS)
+		if trans == nil {
+			return &pb.Result{Error: "failed to create the coordinate transformation of the profile"}
+		}
+		defer C.OCTDestroyCoordinateTransformation(trans)
+		if C.OCTTransform(trans, C.int(len(xs)), (*C.double)(&xs[0]), (*C.double)(&ys[0]), nil) == 0 {
+			return &pb.Result{Error: "failed to transform the profile into the dataset projection"}
+		}
+	}
+
+	geot := make([]float64, 6)
+	C.GDALGetGeoTransform(ds, (*C.double)(&geot[0]))
+	invGeot := make([]float64, 6)
+	C.GDALInvGeoTransform((*C.double)(&geot[0]), (*C.double)(&invGeot[0]))
+
+	xSize := int(C.GDALGetRasterXSize(ds))
+	ySize := int(C.GDALGetRasterYSize(ds))
+
+	bandH := C.GDALGetRasterBand(ds, C.int(1))
+	var hasNoData C.int
+	nodata := float64(C.GDALGetRasterNoDataValue(bandH, &hasNoData))
+
+	profiles := make([]*pb.TimeSeries, len(bands))
+	for ib := range profiles {
+		profiles[ib] = &pb.TimeSeries{Samples: make([]float64, len(samples))}
+		for is := range samples {
+			profiles[ib].Samples[is] = math.NaN()
+		}
+	}
+
+	pixel := make([]float64, len(bands))
+	for is := range samples {
+		var px, py C.double
+		C.GDALApplyGeoTransform((*C.double)(&invGeot[0]), C.double(xs[is]), C.double(ys[is]), &px, &py)
+		ix := int(math.Floor(float64(px)))
+		iy := int(math.Floor(float64(py)))
+		if ix < 0 || iy < 0 || ix >= xSize || iy >= ySize {
+			continue
+		}
+
+		gerr := C.GDALDatasetRasterIO(ds, C.GF_Read, C.int(ix), C.int(iy), 1, 1, unsafe.Pointer(&pixel[0]), 1, 1, C.GDT_Float64, C.int(len(bands)), (*C.int)(unsafe.Pointer(&bands[0])), 0, 0, 0)
+		if gerr != 0 {
+			return &pb.Result{Error: fmt.Sprintf("failed to read the pixel of sample %d", is)}
+		}
+
+		for ib, val := range pixel {
+			if (hasNoData != 0 && val == nodata) || math.IsNaN(val) {
+				continue
+			}
+			profiles[ib].Samples[is] = val
+			profiles[ib].Count++
+		}
+	}
+
+	return &pb.Result{TimeSeries: profiles, Error: "OK"}
 }
 
-func readData(ds C.GDALDatasetH, bands []int32, geom C.OGRGeometryH, bandStrides int) *pb.Result {
+// pixelRowAreas returns the area in square kilometres of a
+// pixel in each of the countY rows starting at offY. The area
+// of pixels in geographic coordinates depends on their latitude.
+func pixelRowAreas(ds C.GDALDatasetH, offY int32, countY int32) ([]float64, error) {
+	geot := make([]float64, 6)
+	if C.GDALGetGeoTransform(ds, (*C.double)(&geot[0])) != C.CE_None {
+		return nil, fmt.Errorf("failed to get the geotransform of the dataset")
+	}
+
+	projRef := C.GDALGetProjectionRef(ds)
+	if C.GoString(projRef) == "" {
+		projRef = cWGS84WKT
+	}
+	hSRS := C.OSRNewSpatialReference(projRef)
+	if hSRS == nil {
+		return nil, fmt.Errorf("invalid dataset projection")
+	}
+	defer C.OSRRelease(hSRS)
+
+	rowAreas := make([]float64, countY)
+	if C.OSRIsGeographic(hSRS) != 0 {
+		// Area of a cell of a sphere with the mean radius of the Earth
+		const earthRadius = 6371.0088
+		dLon := math.Abs(geot[1]) * math.Pi / 180
+		for iy := range rowAreas {
+			latTop := (geot[3] + float64(int(offY)+iy)*geot[5]) * math.Pi / 180
+			latBottom := latTop + geot[5]*math.Pi/180
+			rowAreas[iy] = earthRadius * earthRadius * dLon * math.Abs(math.Sin(latTop)-math.Sin(latBottom))
+		}
+		return rowAreas, nil
+	}
+
+	linearUnits := float64(C.OSRGetLinearUnits(hSRS, nil))
+	pixelArea := math.Abs(geot[1]*geot[5]-geot[2]*geot[4]) * linearUnits * linearUnits / 1e6
+	for iy := range rowAreas {
+		rowAreas[iy] = pixelArea
+	}
+	return rowAreas, nil
+}
+
+// readData computes the statistics of the pixels inside the
+// geometry for every band. The statistics of the exact mode
+// are weighted by the fraction of each pixel covered by the
+// geometry whereas the pixels touched by the geometry weigh
+// one otherwise.
+func readData(ds C.GDALDatasetH, bands []int32, geom C.OGRGeometryH, bandStrides int, sketchSize int, exact bool) *pb.Result {
 	avgs := []*pb.TimeSeries{}
 
-	dsDscr := getDrillFileDescriptor(ds, geom)
+	dsDscr := getDrillFileDescriptor(ds, geom, exact)
+	if exact && dsDscr.Coverage == nil {
+		return &pb.Result{Error: "Failed to compute the pixel coverage of the geometry"}
+	}
 
 	// it is safe to assume all data bands have same data type and nodata value
 	bandH := C.GDALGetRasterBand(ds, C.int(1))
@@ -128,31 +344,31 @@ func readData(ds C.GDALDatasetH, bands []int32, geom C.OGRGeometryH, bandStrides
 		for iBand := 0; iBand < effectiveNBands; iBand++ {
 			bandOffset := iBand * bandSize
 
-			sum := float32(0)
-			total := int32(0)
-
+			values := make([]float64, 0, bandSize)
+			var weights []float64
 			for i := 0; i < bandSize; i++ {
 				if dsDscr.Mask.Pix[i] == 255 && dataBuf[i+bandOffset] != nodata {
-					sum += dataBuf[i+bandOffset]
-					total++
+					values = append(values, float64(dataBuf[i+bandOffset]))
+					if exact {
+						weights = append(weights, dsDscr.Coverage[i])
+					}
 				}
 			}
 
-			if total > 0 {
-				boundAvgs[iBand] = &pb.TimeSeries{Value: float64(sum / float32(total)), Count: total}
+			if exact {
+				boundAvgs[iBand] = zonalStatsToTimeSeries(utils.NewWeightedZonalStats(values, weights, sketchSize))
 			} else {
-				boundAvgs[iBand] = &pb.TimeSeries{Value: 0, Count: 0}
+				boundAvgs[iBand] = zonalStatsToTimeSeries(utils.NewZonalStats(values, sketchSize))
 			}
 		}
 
 		avgs = append(avgs, boundAvgs[0])
 
 		if bandStrides > 2 {
-			beta := (boundAvgs[1].Value - boundAvgs[0].Value) / float64(bandStrides-1)
 			count := math.Round(float64(boundAvgs[0].Count+boundAvgs[1].Count) / float64(2))
 			for ip := 1; ip < bandStrides-1; ip++ {
-				val := boundAvgs[0].Value + float64(ip)*beta
-				avgs = append(avgs, &pb.TimeSeries{Value: val, Count: int32(count)})
+				alpha := float64(ip) / float64(bandStrides-1)
+				avgs = append(avgs, interpolateTimeSeries(boundAvgs[0], boundAvgs[1], alpha, int32(count)))
 			}
 		}
 
@@ -165,6 +381,59 @@ func readData(ds C.GDALDatasetH, bands []int32, geom C.OGRGeometryH, bandStrides
 	return &pb.Result{TimeSeries: avgs, Error: "OK"}
 }
 
+func zonalStatsToTimeSeries(zs *utils.ZonalStats) *pb.TimeSeries {
+	if zs.Count == 0 {
+		return &pb.TimeSeries{Value: 0, Count: 0}
+	}
+
+	ts := &pb.TimeSeries{Value: zs.Mean, Count: int32(zs.Count), Weight: zs.Weight, Min: zs.Min, Max: zs.Max, M2: zs.M2, Centroids: zs.Centroids}
+	ts.CentroidCounts = make([]int32, len(zs.CentroidCounts))
+	for i, c := range zs.CentroidCounts {
+		ts.CentroidCounts[i] = int32(c)
+	}
+	return ts
+}
+
+// interpolateTimeSeries linearly interpolates the statistics
+// between two bands. The quantile sketch of the closest band
+// is shifted by the difference between the means.
+func interpolateTimeSeries(lower, upper *pb.TimeSeries, alpha float64, count int32) *pb.TimeSeries {
+	lerp := func(a, b float64) float64 { return a + alpha*(b-a) }
+
+	ts := &pb.TimeSeries{Value: lerp(lower.Value, upper.Value), Count: count}
+	if lower.Count == 0 || upper.Count == 0 || count == 0 {
+		return ts
+	}
+
+	// The pixels of weighted statistics weigh their coverage
+	weight := func(ts *pb.TimeSeries) float64 {
+		if ts.Weight > 0 {
+			return ts.Weight
+		}
+		return float64(ts.Count)
+	}
+
+	variance := lerp(lower.M2/weight(lower), upper.M2/weight(upper))
+	ts.Min = lerp(lower.Min, upper.Min)
+	ts.Max = lerp(lower.Max, upper.Max)
+	ts.M2 = variance * float64(count)
+	if lower.Weight > 0 && upper.Weight > 0 {
+		ts.Weight = lerp(lower.Weight, upper.Weight)
+		ts.M2 = variance * ts.Weight
+	}
+
+	closest := lower
+	if alpha > 0.5 {
+		closest = upper
+	}
+	ts.Centroids = make([]float64, len(closest.Centroids))
+	for i, c := range closest.Centroids {
+		ts.Centroids[i] = c + ts.Value - closest.Value
+	}
+	ts.CentroidCounts = closest.CentroidCounts
+	return ts
+}
+
 func createMask(ds C.GDALDatasetH, g C.OGRGeometryH, offsetX, offsetY, countX, countY int32) (*image.Gray, error) {
 	canvas := make([]uint8, int(C.GDALGetRasterXSize(ds)*C.GDALGetRasterYSize(ds)))
 	hDstDS := C.GDALOpen(C.CString(fmt.Sprintf("MEM:::DATAPOINTER=%d,PIXELS=%d,LINES=%d,DATATYPE=Byte", unsafe.Pointer(&canvas[0]), C.GDALGetRasterXSize(ds), C.GDALGetRasterYSize(ds))), C.GA_Update)
@@ -247,16 +516,19 @@ func envelopePolygon(hDS C.GDALDatasetH) C.OGRGeometryH {
 	return hGeom
 }
 
-func getDrillFileDescriptor(ds C.GDALDatasetH, g C.OGRGeometryH) DrillFileDescriptor {
+// getDrillFileDescriptor returns the window of the dataset
+// covering the geometry and its mask. The window of the exact
+// mode includes the pixels partially covered on its right and
+// bottom edges and comes with the coverage of its pixels.
+func getDrillFileDescriptor(ds C.GDALDatasetH, g C.OGRGeometryH, exact bool) DrillFileDescriptor {
 	gCopy := C.OGR_G_Clone(g)
+	defer C.OGR_G_DestroyGeometry(gCopy)
 
+	// The geometry is reprojected from its own CRS
 	if C.GoString(C.GDALGetProjectionRef(ds)) != "" {
 		desSRS := C.OSRNewSpatialReference(C.GDALGetProjectionRef(ds))
 		defer C.OSRDestroySpatialReference(desSRS)
-		srcSRS := C.OSRNewSpatialReference(cWGS84WKT)
-		defer C.OSRDestroySpatialReference(srcSRS)
-		trans := C.OCTNewCoordinateTransformation(srcSRS, desSRS)
-		C.OGR_G_Transform(gCopy, trans)
+		C.OGR_G_TransformTo(gCopy, desSRS)
 	}
 
 	fileEnv := envelopePolygon(ds)
@@ -283,6 +555,10 @@ func getDrillFileDescriptor(ds C.GDALDatasetH, g C.OGRGeometryH) DrillFileDescri
 	offsetY := int32(math.Min(float64(offMinY), float64(offMaxY)))
 	countX := int32(math.Max(float64(offMinX), float64(offMaxX))) - offsetX
 	countY := int32(math.Max(float64(offMinY), float64(offMaxY))) - offsetY
+	if exact {
+		countX = int32(math.Ceil(math.Max(float64(offMinX), float64(offMaxX)))) - offsetX
+		countY = int32(math.Ceil(math.Max(float64(offMinY), float64(offMaxY)))) - offsetY
+	}
 	if countX == 0 {
 		countX++
 	}
@@ -296,7 +572,89 @@ func getDrillFileDescriptor(ds C.GDALDatasetH, g C.OGRGeometryH) DrillFileDescri
 		offsetY = 0
 	}
 
+	if exact {
+		if maxX := int32(C.GDALGetRasterXSize(ds)); offsetX+countX > maxX {
+			countX = maxX - offsetX
+		}
+		if maxY := int32(C.GDALGetRasterYSize(ds)); offsetY+countY > maxY {
+			countY = maxY - offsetY
+		}
+	}
+
 	mask, _ := createMask(ds, gCopy, offsetX, offsetY, countX, countY)
 
-	return DrillFileDescriptor{offsetX, offsetY, countX, countY, mask}
+	var coverage []float64
+	if exact && mask != nil {
+		var err error
+		coverage, err = pixelCoverage(ds, gCopy, mask, offsetX, offsetY, countX, countY)
+		if err != nil {
+			log.Printf("Failed to compute the pixel coverage: %v", err)
+		}
+	}
+
+	return DrillFileDescriptor{offsetX, offsetY, countX, countY, mask, coverage}
+}
+
+// pixelCoverage returns the fraction of the area of each pixel
+// of the window covered by the geometry, which is in the CRS of
+// the dataset. Only the pixels crossed by the boundary of the
+// geometry are intersected with it, the other pixels of the
+// mask being fully covered.
+func pixelCoverage(ds C.GDALDatasetH, g C.OGRGeometryH, mask *image.Gray, offsetX, offsetY, countX, countY int32) ([]float64, error) {
+	boundary := C.OGR_G_Boundary(g)
+	if boundary == nil {
+		return nil, fmt.Errorf("Couldn't compute the boundary of the geometry")
+	}
+	defer C.OGR_G_DestroyGeometry(boundary)
+
+	edges, err := createMask(ds, boundary, offsetX, offsetY, countX, countY)
+	if err != nil {
+		return nil, err
+	}
+
+	geot := make([]float64, 6)
+	C.GDALGetGeoTransform(ds, (*C.double)(&geot[0]))
+
+	coverage := make([]float64, countX*countY)
+	for iy := 0; iy < int(countY); iy++ {
+		for ix := 0; ix < int(countX); ix++ {
+			i := iy*int(countX) + ix
+			if mask.Pix[i] != 255 {
+				continue
+			}
+			if edges.Pix[i] != 255 {
+				coverage[i] = 1
+				continue
+			}
+			coverage[i] = pixelFraction(g, geot, ix+int(offsetX), iy+int(offsetY))
+		}
+	}
+	return coverage, nil
+}
+
+// pixelFraction returns the fraction of the area of the pixel
+// at column x and row y covered by the geometry
+func pixelFraction(g C.OGRGeometryH, geot []float64, x, y int) float64 {
+	ring := C.OGR_G_CreateGeometry(C.wkbLinearRing)
+	for _, corner := range [][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}, {0, 0}} {
+		var px, py C.double
+		C.GDALApplyGeoTransform((*C.double)(&geot[0]), C.double(x+corner[0]), C.double(y+corner[1]), &px, &py)
+		C.OGR_G_AddPoint_2D(ring, px, py)
+	}
+	pixel := C.OGR_G_CreateGeometry(C.wkbPolygon)
+	C.OGR_G_AddGeometryDirectly(pixel, ring)
+	defer C.OGR_G_DestroyGeometry(pixel)
+
+	pixelArea := float64(C.OGR_G_Area(pixel))
+	if pixelArea <= 0 {
+		return 0
+	}
+
+	inters := C.OGR_G_Intersection(g, pixel)
+	if inters == nil {
+		return 0
+	}
+	defer C.OGR_G_DestroyGeometry(inters)
+
+	return math.Min(1, float64(C.OGR_G_Area(inters))/pixelArea)
 }
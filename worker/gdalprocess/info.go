@@ -190,7 +190,7 @@ func getDataSetInfo(filename string, dsName *C.char, driverName string) (*pb.Geo
 	proj4 := C.GoString(cProj4)
 	C.free(unsafe.Pointer(cProj4))
 
-	return &pb.GeoMetaData{DatasetName: datasetName, NameSpace: nspace, Type: GDALTypes[C.GDALGetRasterDataType(hBand)],
+	return &pb.GeoMetaData{DatasetName: datasetName, NameSpace: nspace, Type: rasterType(hBand),
 		RasterCount: int32(C.GDALGetRasterCount(hSubdataset)), TimeStamps: times, Height: ncLevels,
 		XSize: int32(C.GDALGetRasterXSize(hSubdataset)), YSize: int32(C.GDALGetRasterYSize(hSubdataset)),
 		Polygon: polyWkt, ProjWKT: projWkt, Proj4: proj4, GeoTransform: geot, Overviews: ovrs}, nil
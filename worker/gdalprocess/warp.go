@@ -6,13 +6,112 @@ package gdalprocess
 // #include "ogr_api.h"
 // #include "ogr_srs_api.h"
 // #include "cpl_string.h"
+// #include <math.h>
 // #cgo pkg-config: gdal
+// void
+// transform_cutline(OGRGeometryH hGeom, void *hTransformArg)
+// {
+//        int i, nGeoms, nPoints, success;
+//        double x, y, z;
+//
+//        nGeoms = OGR_G_GetGeometryCount(hGeom);
+//        for(i = 0; i < nGeoms; i++) {
+//            transform_cutline(OGR_G_GetGeometryRef(hGeom, i), hTransformArg);
+//        }
+//
+//        nPoints = OGR_G_GetPointCount(hGeom);
+//        for(i = 0; i < nPoints; i++) {
+//            OGR_G_GetPoint(hGeom, i, &x, &y, &z);
+//            success = FALSE;
+//            GDALGenImgProjTransform(hTransformArg, TRUE, 1, &x, &y, &z, &success);
+//            OGR_G_SetPoint_2D(hGeom, i, x, y);
+//        }
+// }
+//
+// const char *
+// src_proj_ref(GDALDatasetH hSrcDS)
+// {
+//        const char *srcProjRef;
+//
+//        srcProjRef = GDALGetProjectionRef(hSrcDS);
+//        if(strlen(srcProjRef) == 0) {
+//            srcProjRef = "GEOGCS[\"WGS 84\",DATUM[\"WGS_1984\",SPHEROID[\"WGS 84\",6378137,298.257223563,AUTHORITY[\"EPSG\",\"7030\"]],TOWGS84[0,0,0,0,0,0,0],AUTHORITY[\"EPSG\",\"6326\"]],PRIMEM[\"Greenwich\",0,AUTHORITY[\"EPSG\",\"8901\"]],UNIT[\"degree\",0.0174532925199433,AUTHORITY[\"EPSG\",\"9108\"]],AUTHORITY[\"EPSG\",\"4326\"]]\",\"proj4\":\"+proj=longlat +ellps=WGS84 +towgs84=0,0,0,0,0,0,0 +no_defs \"";
+//        }
+//        return srcProjRef;
+// }
+//
+// // overview_level returns the coarsest overview of a band
+// // whose pixels are not larger than the destination pixels,
+// // or -1 to read the full resolution
 // int
-// warp_operation(GDALDatasetH hSrcDS, GDALDatasetH hDstDS, int band)
+// overview_level(GDALDatasetH hSrcDS, GDALDatasetH hDstDS, int band)
+// {
+//        GDALRasterBandH hBand, hOvr;
+//        void *hTransformArg;
+//        double x[3], y[3], z[3], step, ratio, factor, bestFactor;
+//        int success[3], i, j, k, level, nDstX, nDstY;
+//
+//        hBand = GDALGetRasterBand(hSrcDS, band);
+//        if(hBand == NULL || GDALGetOverviewCount(hBand) == 0) {
+//            return -1;
+//        }
+//
+//        hTransformArg = GDALCreateGenImgProjTransformer(hSrcDS, src_proj_ref(hSrcDS), hDstDS, GDALGetProjectionRef(hDstDS), FALSE, 0, 1);
+//        if(hTransformArg == NULL) {
+//            return -1;
+//        }
+//
+//        // The number of source pixels spanned by a destination
+//        // pixel is sampled at the corners and the centre
+//        nDstX = GDALGetRasterXSize(hDstDS);
+//        nDstY = GDALGetRasterYSize(hDstDS);
+//        ratio = -1;
+//        for(i = 0; i <= 2; i++) {
+//            for(j = 0; j <= 2; j++) {
+//                x[0] = i * (nDstX - 1) / 2.0;
+//                y[0] = j * (nDstY - 1) / 2.0;
+//                x[1] = x[0] + 1;
+//                y[1] = y[0];
+//                x[2] = x[0];
+//                y[2] = y[0] + 1;
+//                z[0] = z[1] = z[2] = 0;
+//                if(!GDALGenImgProjTransform(hTransformArg, TRUE, 3, x, y, z, success) || !success[0] || !success[1] || !success[2]) {
+//                    continue;
+//                }
+//                for(k = 1; k <= 2; k++) {
+//                    step = sqrt((x[k] - x[0]) * (x[k] - x[0]) + (y[k] - y[0]) * (y[k] - y[0]));
+//                    if(ratio < 0 || step < ratio) {
+//                        ratio = step;
+//                    }
+//                }
+//            }
+//        }
+//        GDALDestroyGenImgProjTransformer(hTransformArg);
+//
+//        level = -1;
+//        bestFactor = 1;
+//        for(k = 0; k < GDALGetOverviewCount(hBand); k++) {
+//            hOvr = GDALGetOverview(hBand, k);
+//            if(hOvr == NULL || GDALGetRasterBandXSize(hOvr) == 0) {
+//                continue;
+//            }
+//            factor = (double)GDALGetRasterBandXSize(hBand) / GDALGetRasterBandXSize(hOvr);
+//            if(factor <= ratio && factor > bestFactor) {
+//                bestFactor = factor;
+//                level = k;
+//            }
+//        }
+//        return level;
+// }
+//
+// int
+// warp_operation(GDALDatasetH hSrcDS, GDALDatasetH hDstDS, int band, GDALResampleAlg resampleAlg, char *cutlineWKT)
 // {
 //        const char *srcProjRef;
 //        int err;
 //        GDALWarpOptions *psWOptions;
+//        OGRGeometryH hCutline;
+//        void *hTransformArg;
 //
 //        psWOptions = GDALCreateWarpOptions();
 //        psWOptions->nBandCount = 1;
@@ -21,12 +120,30 @@ package gdalprocess
 //        psWOptions->panDstBands = (int *) CPLMalloc(sizeof(int) * 1);
 //        psWOptions->panDstBands[0] = 1;
 //
-//        srcProjRef = GDALGetProjectionRef(hSrcDS);
-//        if(strlen(srcProjRef) == 0) {
-//            srcProjRef = "GEOGCS[\"WGS 84\",DATUM[\"WGS_1984\",SPHEROID[\"WGS 84\",6378137,298.257223563,AUTHORITY[\"EPSG\",\"7030\"]],TOWGS84[0,0,0,0,0,0,0],AUTHORITY[\"EPSG\",\"6326\"]],PRIMEM[\"Greenwich\",0,AUTHORITY[\"EPSG\",\"8901\"]],UNIT[\"degree\",0.0174532925199433,AUTHORITY[\"EPSG\",\"9108\"]],AUTHORITY[\"EPSG\",\"4326\"]]\",\"proj4\":\"+proj=longlat +ellps=WGS84 +towgs84=0,0,0,0,0,0,0 +no_defs \"";
+//        srcProjRef = src_proj_ref(hSrcDS);
+//
+//        // The cutline arrives in the destination CRS but GDAL
+//        // expects it in source pixel/line coordinates
+//        if(cutlineWKT != NULL && strlen(cutlineWKT) > 0) {
+//            hCutline = NULL;
+//            if(OGR_G_CreateFromWkt(&cutlineWKT, NULL, &hCutline) != OGRERR_NONE || hCutline == NULL) {
+//                GDALDestroyWarpOptions(psWOptions);
+//                return 1;
+//            }
+//
+//            hTransformArg = GDALCreateGenImgProjTransformer(hSrcDS, srcProjRef, NULL, GDALGetProjectionRef(hDstDS), FALSE, 0, 1);
+//            if(hTransformArg == NULL) {
+//                OGR_G_DestroyGeometry(hCutline);
+//                GDALDestroyWarpOptions(psWOptions);
+//                return 1;
+//            }
+//            transform_cutline(hCutline, hTransformArg);
+//            GDALDestroyGenImgProjTransformer(hTransformArg);
+//
+//            psWOptions->hCutline = hCutline;
 //        }
 //
-//        err = GDALReprojectImage(hSrcDS, srcProjRef, hDstDS, GDALGetProjectionRef(hDstDS), GRA_NearestNeighbour, 0.0, 0.0, NULL, NULL, psWOptions);
+//        err = GDALReprojectImage(hSrcDS, srcProjRef, hDstDS, GDALGetProjectionRef(hDstDS), resampleAlg, 0.0, 0.0, NULL, NULL, psWOptions);
 //        GDALDestroyWarpOptions(psWOptions);
 //
 //        return err;
@@ -41,6 +158,7 @@ import (
 
 	"reflect"
 
+	"github.com/nci/gsky/utils"
 	pb "github.com/nci/gsky/worker/gdalservice"
 )
 
@@ -53,51 +171,49 @@ var GDALTypes = map[C.GDALDataType]string{0: "Unkown", 1: "Byte", 2: "UInt16", 3
 	8: "CInt16", 9: "CInt32", 10: "CFloat32", 11: "CFloat64",
 	12: "TypeCount"}
 
-func initNoDataSlice(rType string, noDataValue float64, ssize int32) []uint8 {
-	size := int(ssize)
-	switch rType {
-	case "Byte":
-		out := make([]uint8, size)
-		fill := uint8(noDataValue)
-		for i := 0; i < size; i++ {
-			out[i] = fill
-		}
-		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
-		return *(*[]uint8)(unsafe.Pointer(&headr))
-	case "Int16":
-		out := make([]int16, size)
-		fill := int16(noDataValue)
-		for i := 0; i < size; i++ {
-			out[i] = fill
-		}
-		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
-		headr.Len *= SizeofInt16
-		headr.Cap *= SizeofInt16
-		return *(*[]uint8)(unsafe.Pointer(&headr))
-	case "UInt16":
-		out := make([]uint16, size)
-		fill := uint16(noDataValue)
-		for i := 0; i < size; i++ {
-			out[i] = fill
-		}
-		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
-		headr.Len *= SizeofUint16
-		headr.Cap *= SizeofUint16
-		return *(*[]uint8)(unsafe.Pointer(&headr))
-	case "Float32":
-		out := make([]float32, size)
-		fill := float32(noDataValue)
-		for i := 0; i < size; i++ {
-			out[i] = fill
-		}
-		headr := *(*reflect.SliceHeader)(unsafe.Pointer(&out))
-		headr.Len *= SizeofFloat32
-		headr.Cap *= SizeofFloat32
-		return *(*[]uint8)(unsafe.Pointer(&headr))
-	default:
-		return []uint8{}
+// ResampleAlgs maps the resampling names accepted in
+// GeoRPCGranule.Resampling to their GDAL warp kernels.
+var ResampleAlgs = map[string]C.GDALResampleAlg{
+	"near":     C.GRA_NearestNeighbour,
+	"bilinear": C.GRA_Bilinear,
+	"cubic":    C.GRA_Cubic,
+	"average":  C.GRA_Average,
+	"mode":     C.GRA_Mode,
+	"min":      C.GRA_Min,
+	"max":      C.GRA_Max,
+	"median":   C.GRA_Med,
+}
+
+// getResampleAlg returns the GDAL warp kernel for a resampling name.
+// An empty name selects nearest neighbour, which is the historical
+// behaviour of the warper.
+func getResampleAlg(name string) (C.GDALResampleAlg, error) {
+	if len(name) == 0 {
+		return C.GRA_NearestNeighbour, nil
 	}
+	alg, found := ResampleAlgs[name]
+	if !found {
+		return C.GRA_NearestNeighbour, fmt.Errorf("unsupported resampling algorithm: %s", name)
+	}
+	return alg, nil
+}
 
+// rasterType returns the raster type of a band. GDAL reads
+// signed bytes as Byte with PIXELTYPE=SIGNEDBYTE.
+func rasterType(hBand C.GDALRasterBandH) string {
+	dType := C.GDALGetRasterDataType(hBand)
+	if dType == C.GDT_Byte {
+		itemC := C.CString("PIXELTYPE")
+		defer C.free(unsafe.Pointer(itemC))
+		domainC := C.CString("IMAGE_STRUCTURE")
+		defer C.free(unsafe.Pointer(domainC))
+
+		pixelType := C.GDALGetMetadataItem(C.GDALMajorObjectH(hBand), itemC, domainC)
+		if pixelType != nil && C.GoString(pixelType) == "SIGNEDBYTE" {
+			return "Int8"
+		}
+	}
+	return GDALTypes[dType]
 }
 
 func ComputeReprojectExtent(in *pb.GeoRPCGranule) *pb.Result {
@@ -164,22 +280,25 @@ func WarpRaster(in *pb.GeoRPCGranule, debug bool) *pb.Result {
 	filePathCStr := C.CString(in.Path)
 	defer C.free(unsafe.Pointer(filePathCStr))
 
+	ovrLevel := -1
 	dump := func(msg interface{}) string {
 		log.Println(
 			"warp", in.Path,
 			"band", in.Bands[0],
+			"overview", ovrLevel,
 			"width", in.Width,
 			"height", in.Height,
 			"geotransform", in.Geot,
+			"resampling", in.Resampling,
 			"error", msg,
 		)
 		return fmt.Sprintf("%v", msg)
 	}
 
-	// TODO pass overview level in Granule
-	//ovrSel := C.CString("OVERVIEW_LEVEL=0")
-	//defer C.free(unsafe.Pointer(ovrSel))
-	//hSrcDS := C.GDALOpenEx(filePathCStr, C.GA_ReadOnly, nil, &ovrSel, nil)
+	resampleAlg, err := getResampleAlg(in.Resampling)
+	if err != nil {
+		return &pb.Result{Error: dump(err)}
+	}
 
 	hSrcDS := C.GDALOpen(filePathCStr, C.GA_ReadOnly)
 	if hSrcDS == nil {
@@ -206,7 +325,11 @@ func WarpRaster(in *pb.GeoRPCGranule, debug bool) *pb.Result {
 	canvas := make([]uint8, in.Width*in.Height*dSize)
 	*/
 
-	canvas := initNoDataSlice(GDALTypes[dType], nodata, in.Width*in.Height)
+	rType := rasterType(bandH)
+	canvas, err := utils.NewNoDataBuffer(rType, nodata, int(in.Width*in.Height))
+	if err != nil {
+		return &pb.Result{Error: dump(err)}
+	}
 	memStr := C.CString(fmt.Sprintf("MEM:::DATAPOINTER=%d,PIXELS=%d,LINES=%d,DATATYPE=%s", unsafe.Pointer(&canvas[0]), C.int(in.Width), C.int(in.Height), GDALTypes[dType]))
 	defer C.free(unsafe.Pointer(memStr))
 	hDstDS := C.GDALOpen(memStr, C.GA_Update)
@@ -221,7 +344,27 @@ func WarpRaster(in *pb.GeoRPCGranule, debug bool) *pb.Result {
 
 	C.GDALSetProjection(hDstDS, projWKT)
 	C.GDALSetGeoTransform(hDstDS, (*C.double)(&in.Geot[0]))
-	cErr := C.warp_operation(hSrcDS, hDstDS, C.int(in.Bands[0]))
+	cutlineCStr := C.CString(in.Cutline)
+	defer C.free(unsafe.Pointer(cutlineCStr))
+
+	// Zoomed out requests read the coarsest overview which
+	// still resolves the destination pixels
+	hWarpDS := hSrcDS
+	ovrLevel = int(C.overview_level(hSrcDS, hDstDS, C.int(in.Bands[0])))
+	if ovrLevel >= 0 {
+		ovrSel := C.CString(fmt.Sprintf("OVERVIEW_LEVEL=%d", ovrLevel))
+		defer C.free(unsafe.Pointer(ovrSel))
+		openOptions := []*C.char{ovrSel, nil}
+		hOvrDS := C.GDALOpenEx(filePathCStr, C.GDAL_OF_RASTER|C.GDAL_OF_READONLY, nil, &openOptions[0], nil)
+		if hOvrDS != nil {
+			defer C.GDALClose(hOvrDS)
+			hWarpDS = hOvrDS
+		} else {
+			ovrLevel = -1
+		}
+	}
+
+	cErr := C.warp_operation(hWarpDS, hDstDS, C.int(in.Bands[0]), resampleAlg, cutlineCStr)
 	if cErr != 0 {
 		return &pb.Result{Error: dump("warp_operation() fail")}
 	}
@@ -230,5 +373,5 @@ func WarpRaster(in *pb.GeoRPCGranule, debug bool) *pb.Result {
 		dump("debug")
 	}
 
-	return &pb.Result{Raster: &pb.Raster{Data: canvas, NoData: nodata, RasterType: GDALTypes[dType]}, Error: "OK"}
+	return &pb.Result{Raster: &pb.Raster{Data: canvas, NoData: nodata, RasterType: rType}, Error: "OK"}
 }
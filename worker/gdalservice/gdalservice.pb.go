@@ -11,6 +11,7 @@ It has these top-level messages:
 	GeoRPCGranule
 	Raster
 	TimeSeries
+	ClassCount
 	Overview
 	GeoMetaData
 	GeoFile
@@ -40,14 +41,20 @@ var _ = math.Inf
 const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type GeoRPCGranule struct {
-	Path        string    `protobuf:"bytes,1,opt,name=path" json:"path,omitempty"`
-	Geometry    string    `protobuf:"bytes,2,opt,name=geometry" json:"geometry,omitempty"`
-	Bands       []int32   `protobuf:"varint,3,rep,packed,name=bands" json:"bands,omitempty"`
-	Height      int32     `protobuf:"varint,4,opt,name=height" json:"height,omitempty"`
-	Width       int32     `protobuf:"varint,5,opt,name=width" json:"width,omitempty"`
-	EPSG        int32     `protobuf:"varint,6,opt,name=ePSG" json:"ePSG,omitempty"`
-	Geot        []float64 `protobuf:"fixed64,7,rep,packed,name=geot" json:"geot,omitempty"`
-	BandStrides int32     `protobuf:"varint,8,opt,name=bandStrides" json:"bandStrides,omitempty"`
+	Path           string    `protobuf:"bytes,1,opt,name=path" json:"path,omitempty"`
+	Geometry       string    `protobuf:"bytes,2,opt,name=geometry" json:"geometry,omitempty"`
+	Bands          []int32   `protobuf:"varint,3,rep,packed,name=bands" json:"bands,omitempty"`
+	Height         int32     `protobuf:"varint,4,opt,name=height" json:"height,omitempty"`
+	Width          int32     `protobuf:"varint,5,opt,name=width" json:"width,omitempty"`
+	EPSG           int32     `protobuf:"varint,6,opt,name=ePSG" json:"ePSG,omitempty"`
+	Geot           []float64 `protobuf:"fixed64,7,rep,packed,name=geot" json:"geot,omitempty"`
+	BandStrides    int32     `protobuf:"varint,8,opt,name=bandStrides" json:"bandStrides,omitempty"`
+	Resampling     string    `protobuf:"bytes,9,opt,name=resampling" json:"resampling,omitempty"`
+	Cutline        string    `protobuf:"bytes,10,opt,name=cutline" json:"cutline,omitempty"`
+	SketchSize     int32     `protobuf:"varint,11,opt,name=sketchSize" json:"sketchSize,omitempty"`
+	Histogram      bool      `protobuf:"varint,12,opt,name=histogram" json:"histogram,omitempty"`
+	ProfileSpacing float64   `protobuf:"fixed64,13,opt,name=profileSpacing" json:"profileSpacing,omitempty"`
+	Exact          bool      `protobuf:"varint,14,opt,name=exact" json:"exact,omitempty"`
 }
 
 func (m *GeoRPCGranule) Reset()                    { *m = GeoRPCGranule{} }
@@ -111,6 +118,48 @@ func (m *GeoRPCGranule) GetBandStrides() int32 {
 	return 0
 }
 
+func (m *GeoRPCGranule) GetResampling() string {
+	if m != nil {
+		return m.Resampling
+	}
+	return ""
+}
+
+func (m *GeoRPCGranule) GetCutline() string {
+	if m != nil {
+		return m.Cutline
+	}
+	return ""
+}
+
+func (m *GeoRPCGranule) GetSketchSize() int32 {
+	if m != nil {
+		return m.SketchSize
+	}
+	return 0
+}
+
+func (m *GeoRPCGranule) GetHistogram() bool {
+	if m != nil {
+		return m.Histogram
+	}
+	return false
+}
+
+func (m *GeoRPCGranule) GetProfileSpacing() float64 {
+	if m != nil {
+		return m.ProfileSpacing
+	}
+	return 0
+}
+
+func (m *GeoRPCGranule) GetExact() bool {
+	if m != nil {
+		return m.Exact
+	}
+	return false
+}
+
 type Raster struct {
 	Data       []byte  `protobuf:"bytes,1,opt,name=data,proto3" json:"data,omitempty"`
 	NoData     float64 `protobuf:"fixed64,2,opt,name=noData" json:"noData,omitempty"`
@@ -144,8 +193,16 @@ func (m *Raster) GetRasterType() string {
 }
 
 type TimeSeries struct {
-	Value float64 `protobuf:"fixed64,1,opt,name=value" json:"value,omitempty"`
-	Count int32   `protobuf:"varint,2,opt,name=count" json:"count,omitempty"`
+	Value          float64       `protobuf:"fixed64,1,opt,name=value" json:"value,omitempty"`
+	Count          int32         `protobuf:"varint,2,opt,name=count" json:"count,omitempty"`
+	Min            float64       `protobuf:"fixed64,3,opt,name=min" json:"min,omitempty"`
+	Max            float64       `protobuf:"fixed64,4,opt,name=max" json:"max,omitempty"`
+	M2             float64       `protobuf:"fixed64,5,opt,name=m2" json:"m2,omitempty"`
+	Centroids      []float64     `protobuf:"fixed64,6,rep,packed,name=centroids" json:"centroids,omitempty"`
+	CentroidCounts []int32       `protobuf:"varint,7,rep,packed,name=centroidCounts" json:"centroidCounts,omitempty"`
+	ClassCounts    []*ClassCount `protobuf:"bytes,8,rep,name=classCounts" json:"classCounts,omitempty"`
+	Samples        []float64     `protobuf:"fixed64,9,rep,packed,name=samples" json:"samples,omitempty"`
+	Weight         float64       `protobuf:"fixed64,10,opt,name=weight" json:"weight,omitempty"`
 }
 
 func (m *TimeSeries) Reset()                    { *m = TimeSeries{} }
@@ -167,6 +224,94 @@ func (m *TimeSeries) GetCount() int32 {
 	return 0
 }
 
+func (m *TimeSeries) GetMin() float64 {
+	if m != nil {
+		return m.Min
+	}
+	return 0
+}
+
+func (m *TimeSeries) GetMax() float64 {
+	if m != nil {
+		return m.Max
+	}
+	return 0
+}
+
+func (m *TimeSeries) GetM2() float64 {
+	if m != nil {
+		return m.M2
+	}
+	return 0
+}
+
+func (m *TimeSeries) GetCentroids() []float64 {
+	if m != nil {
+		return m.Centroids
+	}
+	return nil
+}
+
+func (m *TimeSeries) GetCentroidCounts() []int32 {
+	if m != nil {
+		return m.CentroidCounts
+	}
+	return nil
+}
+
+func (m *TimeSeries) GetClassCounts() []*ClassCount {
+	if m != nil {
+		return m.ClassCounts
+	}
+	return nil
+}
+
+func (m *TimeSeries) GetSamples() []float64 {
+	if m != nil {
+		return m.Samples
+	}
+	return nil
+}
+
+func (m *TimeSeries) GetWeight() float64 {
+	if m != nil {
+		return m.Weight
+	}
+	return 0
+}
+
+type ClassCount struct {
+	Value float64 `protobuf:"fixed64,1,opt,name=value" json:"value,omitempty"`
+	Count int32   `protobuf:"varint,2,opt,name=count" json:"count,omitempty"`
+	Area  float64 `protobuf:"fixed64,3,opt,name=area" json:"area,omitempty"`
+}
+
+func (m *ClassCount) Reset()                    { *m = ClassCount{} }
+func (m *ClassCount) String() string            { return proto.CompactTextString(m) }
+func (*ClassCount) ProtoMessage()               {}
+func (*ClassCount) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+func (m *ClassCount) GetValue() float64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func (m *ClassCount) GetCount() int32 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *ClassCount) GetArea() float64 {
+	if m != nil {
+		return m.Area
+	}
+	return 0
+}
+
 type Overview struct {
 	XSize int32 `protobuf:"varint,1,opt,name=xSize" json:"xSize,omitempty"`
 	YSize int32 `protobuf:"varint,2,opt,name=ySize" json:"ySize,omitempty"`
@@ -175,7 +320,7 @@ type Overview struct {
 func (m *Overview) Reset()                    { *m = Overview{} }
 func (m *Overview) String() string            { return proto.CompactTextString(m) }
 func (*Overview) ProtoMessage()               {}
-func (*Overview) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+func (*Overview) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
 
 func (m *Overview) GetXSize() int32 {
 	if m != nil {
@@ -210,7 +355,7 @@ type GeoMetaData struct {
 func (m *GeoMetaData) Reset()                    { *m = GeoMetaData{} }
 func (m *GeoMetaData) String() string            { return proto.CompactTextString(m) }
 func (*GeoMetaData) ProtoMessage()               {}
-func (*GeoMetaData) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+func (*GeoMetaData) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
 
 func (m *GeoMetaData) GetDatasetName() string {
 	if m != nil {
@@ -312,7 +457,7 @@ type GeoFile struct {
 func (m *GeoFile) Reset()                    { *m = GeoFile{} }
 func (m *GeoFile) String() string            { return proto.CompactTextString(m) }
 func (*GeoFile) ProtoMessage()               {}
-func (*GeoFile) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+func (*GeoFile) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
 
 func (m *GeoFile) GetFileName() string {
 	if m != nil {
@@ -345,7 +490,7 @@ type Result struct {
 func (m *Result) Reset()                    { *m = Result{} }
 func (m *Result) String() string            { return proto.CompactTextString(m) }
 func (*Result) ProtoMessage()               {}
-func (*Result) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{6} }
+func (*Result) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
 
 func (m *Result) GetTimeSeries() []*TimeSeries {
 	if m != nil {
@@ -379,6 +524,7 @@ func init() {
 	proto.RegisterType((*GeoRPCGranule)(nil), "gdalservice.GeoRPCGranule")
 	proto.RegisterType((*Raster)(nil), "gdalservice.Raster")
 	proto.RegisterType((*TimeSeries)(nil), "gdalservice.TimeSeries")
+	proto.RegisterType((*ClassCount)(nil), "gdalservice.ClassCount")
 	proto.RegisterType((*Overview)(nil), "gdalservice.Overview")
 	proto.RegisterType((*GeoMetaData)(nil), "gdalservice.GeoMetaData")
 	proto.RegisterType((*GeoFile)(nil), "gdalservice.GeoFile")
@@ -460,45 +606,57 @@ var _GDAL_serviceDesc = grpc.ServiceDesc{
 func init() { proto.RegisterFile("gdalservice.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 636 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x6c, 0x54, 0x4d, 0x6f, 0xdb, 0x38,
-	0x10, 0x85, 0xd6, 0x96, 0x6c, 0x8f, 0x92, 0xc3, 0x72, 0xb3, 0x2d, 0x61, 0x14, 0xad, 0xa0, 0x93,
-	0x81, 0x02, 0x0e, 0xe0, 0x04, 0x6d, 0x91, 0x5b, 0x9b, 0xa0, 0x3e, 0xf4, 0x2b, 0xa0, 0x0c, 0xf4,
-	0xcc, 0xd8, 0x63, 0x59, 0x85, 0x24, 0x1a, 0x24, 0xed, 0xd4, 0xfd, 0x41, 0x3d, 0xf6, 0x9f, 0xf4,
-	0x3f, 0x15, 0x1c, 0xca, 0xb1, 0x1c, 0xf4, 0x36, 0xef, 0x69, 0x38, 0x9c, 0x99, 0xf7, 0x28, 0xf8,
-	0x37, 0x5f, 0xc8, 0xd2, 0xa0, 0xde, 0x16, 0x73, 0x1c, 0xaf, 0xb5, 0xb2, 0x8a, 0xc5, 0x2d, 0x6a,
-	0xf8, 0x22, 0x57, 0x2a, 0x2f, 0xf1, 0x9c, 0x3e, 0xdd, 0x6d, 0x96, 0xe7, 0xb6, 0xa8, 0xd0, 0x58,
-	0x59, 0xad, 0x7d, 0x76, 0xfa, 0x3b, 0x80, 0xd3, 0x29, 0x2a, 0x71, 0x7b, 0x3d, 0xd5, 0xb2, 0xde,
-	0x94, 0xc8, 0x18, 0x74, 0xd7, 0xd2, 0xae, 0x78, 0x90, 0x04, 0xa3, 0x81, 0xa0, 0x98, 0x0d, 0xa1,
-	0x9f, 0xa3, 0xaa, 0xd0, 0xea, 0x1d, 0xff, 0x87, 0xf8, 0x07, 0xcc, 0xce, 0x20, 0xbc, 0x93, 0xf5,
-	0xc2, 0xf0, 0x4e, 0xd2, 0x19, 0x85, 0xc2, 0x03, 0xf6, 0x04, 0xa2, 0x15, 0x16, 0xf9, 0xca, 0xf2,
-	0x6e, 0x12, 0x8c, 0x42, 0xd1, 0x20, 0x97, 0x7d, 0x5f, 0x2c, 0xec, 0x8a, 0x87, 0x44, 0x7b, 0xe0,
-	0xee, 0xc4, 0xdb, 0x6c, 0xca, 0x23, 0x22, 0x29, 0x76, 0x5c, 0x8e, 0xca, 0xf2, 0x5e, 0xd2, 0x19,
-	0x05, 0x82, 0x62, 0x96, 0x40, 0xec, 0xca, 0x67, 0x56, 0x17, 0x0b, 0x34, 0xbc, 0x4f, 0xe9, 0x6d,
-	0x2a, 0x9d, 0x41, 0x24, 0xa4, 0xb1, 0xa8, 0xdd, 0xf9, 0x85, 0xb4, 0x92, 0xe6, 0x38, 0x11, 0x14,
-	0xbb, 0xae, 0x6a, 0x75, 0xe3, 0x58, 0x37, 0x45, 0x20, 0x1a, 0xc4, 0x9e, 0x03, 0x68, 0x3a, 0x35,
-	0xdb, 0xad, 0x91, 0x77, 0x68, 0xc2, 0x16, 0x93, 0xbe, 0x01, 0x98, 0x15, 0x15, 0x66, 0xa8, 0x0b,
-	0x34, 0x6e, 0x86, 0xad, 0x2c, 0x37, 0x48, 0xa5, 0x03, 0xe1, 0x81, 0x63, 0xe7, 0x6a, 0x53, 0x5b,
-	0x2a, 0x1d, 0x0a, 0x0f, 0xd2, 0x57, 0xd0, 0xff, 0xb2, 0x75, 0x62, 0xe0, 0xbd, 0xcb, 0xf8, 0x9e,
-	0x15, 0x3f, 0xfc, 0xb9, 0x50, 0x78, 0xe0, 0xd8, 0x1d, 0xb1, 0xcd, 0x39, 0x02, 0xe9, 0xcf, 0x0e,
-	0xc4, 0x53, 0x54, 0x9f, 0xd0, 0x4a, 0xea, 0x30, 0x81, 0xd8, 0x4d, 0x60, 0xd0, 0x7e, 0x96, 0x15,
-	0x36, 0xe2, 0xb4, 0x29, 0xf6, 0x0c, 0x06, 0xb5, 0xac, 0x30, 0x5b, 0xcb, 0x39, 0x36, 0x22, 0x1d,
-	0x08, 0xb7, 0x0d, 0x7b, 0x98, 0x8d, 0x62, 0x57, 0xd3, 0xcf, 0x78, 0x4d, 0x7d, 0x7b, 0xa1, 0xda,
-	0x14, 0xbb, 0x02, 0x70, 0x86, 0xc9, 0x9c, 0x61, 0x0c, 0x0f, 0x93, 0xce, 0x28, 0x9e, 0x0c, 0xc7,
-	0xde, 0x53, 0xe3, 0xbd, 0xa7, 0xc6, 0xb3, 0xbd, 0xa7, 0x44, 0x2b, 0xbb, 0xe5, 0x80, 0x88, 0x14,
-	0xdc, 0x3b, 0xe0, 0x02, 0x06, 0xaa, 0xd9, 0x88, 0x21, 0x71, 0xe3, 0xc9, 0xff, 0xe3, 0xb6, 0x8d,
-	0xf7, 0xfb, 0x12, 0x87, 0xbc, 0xc3, 0xea, 0xfa, 0x7f, 0x5d, 0xdd, 0xa0, 0xb5, 0x3a, 0x96, 0xc2,
-	0x49, 0x8e, 0x6a, 0xa6, 0x65, 0x6d, 0x96, 0x4a, 0x57, 0x1c, 0xe8, 0xfa, 0x23, 0x8e, 0x71, 0xe8,
-	0xad, 0x55, 0xb9, 0xcb, 0x55, 0xcd, 0x63, 0xda, 0xc8, 0x1e, 0xd2, 0x17, 0xad, 0xbe, 0x7d, 0xfd,
-	0x30, 0xe3, 0x27, 0xcd, 0x17, 0x0f, 0xdd, 0x6d, 0x2e, 0xbc, 0xe4, 0xa7, 0xc4, 0x7b, 0x90, 0x1a,
-	0xe8, 0x4d, 0x51, 0xbd, 0x2f, 0x4a, 0x74, 0xaf, 0x64, 0x59, 0x94, 0xd8, 0x12, 0xe8, 0x01, 0xbb,
-	0x6d, 0x2c, 0x74, 0xb1, 0x45, 0xdd, 0x48, 0xd3, 0x20, 0x76, 0x09, 0x7d, 0x27, 0x62, 0x86, 0xd6,
-	0x3f, 0xa0, 0x78, 0xc2, 0x8f, 0x96, 0xd1, 0xf2, 0x80, 0x78, 0xc8, 0x4c, 0x7f, 0x05, 0x10, 0x09,
-	0x34, 0x9b, 0xd2, 0xb2, 0xd7, 0x8d, 0x44, 0x64, 0x4d, 0x1e, 0x50, 0x89, 0xa7, 0x47, 0x25, 0x0e,
-	0xce, 0x15, 0xad, 0x54, 0xf6, 0x12, 0x22, 0x2f, 0x35, 0x75, 0x14, 0x4f, 0xfe, 0x3b, 0x3a, 0xe4,
-	0x1f, 0x91, 0x68, 0x52, 0xd8, 0x08, 0xba, 0x45, 0xbd, 0x54, 0x64, 0x9f, 0x78, 0x72, 0xf6, 0xb8,
-	0x45, 0x37, 0xbe, 0xa0, 0x0c, 0xb7, 0x25, 0xd4, 0x5a, 0x69, 0xb2, 0xd3, 0x40, 0x78, 0x30, 0x79,
-	0x07, 0xdd, 0xe9, 0xcd, 0xdb, 0x8f, 0xec, 0x0a, 0x7a, 0xb7, 0x5a, 0xcd, 0xd1, 0x18, 0x36, 0x7c,
-	0x5c, 0xe4, 0xf0, 0x0f, 0x1a, 0x3e, 0xea, 0x85, 0x26, 0xbd, 0x8b, 0xc8, 0x70, 0x17, 0x7f, 0x02,
-	0x00, 0x00, 0xff, 0xff, 0xa6, 0x6e, 0xbf, 0x84, 0xf4, 0x04, 0x00, 0x00,
+	// 826 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x55, 0x4f, 0x8f, 0xe3, 0xc4,
+	0x13, 0x95, 0xf3, 0x6f, 0xe2, 0xf2, 0xec, 0xe8, 0xf7, 0x6b, 0x16, 0x68, 0x45, 0x08, 0x2c, 0x1f,
+	0x50, 0x24, 0xa4, 0xac, 0x94, 0x5d, 0x81, 0xd8, 0x1b, 0xcc, 0x8a, 0x1c, 0x58, 0x60, 0xd4, 0x89,
+	0xc4, 0xb9, 0xc7, 0xa9, 0x71, 0x0c, 0xb6, 0x3b, 0xea, 0xee, 0xcc, 0x1f, 0xae, 0x7c, 0x10, 0x6e,
+	0x1c, 0xf9, 0x8c, 0xa8, 0xaa, 0x9d, 0xd8, 0x13, 0xed, 0x85, 0x5b, 0xbf, 0xe7, 0xea, 0xea, 0xee,
+	0xf7, 0xaa, 0xca, 0xf0, 0xff, 0x62, 0xab, 0x2b, 0x87, 0xf6, 0xbe, 0xcc, 0x71, 0xb1, 0xb7, 0xc6,
+	0x1b, 0x91, 0xf4, 0xa8, 0xd9, 0x17, 0x85, 0x31, 0x45, 0x85, 0xaf, 0xf8, 0xd3, 0xed, 0xe1, 0xee,
+	0x95, 0x2f, 0x6b, 0x74, 0x5e, 0xd7, 0xfb, 0x10, 0x9d, 0xfd, 0x39, 0x84, 0x17, 0x2b, 0x34, 0xea,
+	0xe6, 0x7a, 0x65, 0x75, 0x73, 0xa8, 0x50, 0x08, 0x18, 0xed, 0xb5, 0xdf, 0xc9, 0x28, 0x8d, 0xe6,
+	0xb1, 0xe2, 0xb5, 0x98, 0xc1, 0xb4, 0x40, 0x53, 0xa3, 0xb7, 0x4f, 0x72, 0xc0, 0xfc, 0x09, 0x8b,
+	0x97, 0x30, 0xbe, 0xd5, 0xcd, 0xd6, 0xc9, 0x61, 0x3a, 0x9c, 0x8f, 0x55, 0x00, 0xe2, 0x13, 0x98,
+	0xec, 0xb0, 0x2c, 0x76, 0x5e, 0x8e, 0xd2, 0x68, 0x3e, 0x56, 0x2d, 0xa2, 0xe8, 0x87, 0x72, 0xeb,
+	0x77, 0x72, 0xcc, 0x74, 0x00, 0x74, 0x26, 0xde, 0xac, 0x57, 0x72, 0xc2, 0x24, 0xaf, 0x89, 0x2b,
+	0xd0, 0x78, 0x79, 0x91, 0x0e, 0xe7, 0x91, 0xe2, 0xb5, 0x48, 0x21, 0xa1, 0xf4, 0x6b, 0x6f, 0xcb,
+	0x2d, 0x3a, 0x39, 0xe5, 0xf0, 0x3e, 0x25, 0x3e, 0x07, 0xb0, 0xe8, 0x74, 0xbd, 0xaf, 0xca, 0xa6,
+	0x90, 0x31, 0xdf, 0xb5, 0xc7, 0x08, 0x09, 0x17, 0xf9, 0xc1, 0x57, 0x65, 0x83, 0x12, 0xf8, 0xe3,
+	0x11, 0xd2, 0x4e, 0xf7, 0x3b, 0xfa, 0x7c, 0xb7, 0x2e, 0xff, 0x40, 0x99, 0x70, 0xea, 0x1e, 0x23,
+	0x3e, 0x83, 0x78, 0x57, 0x3a, 0x6f, 0x0a, 0xab, 0x6b, 0x79, 0x99, 0x46, 0xf3, 0xa9, 0xea, 0x08,
+	0xf1, 0x25, 0x5c, 0xed, 0xad, 0xb9, 0x2b, 0x2b, 0x5c, 0xef, 0x75, 0x4e, 0x67, 0xbf, 0x48, 0xa3,
+	0x79, 0xa4, 0xce, 0x58, 0x7a, 0x3f, 0x3e, 0xea, 0xdc, 0xcb, 0x2b, 0xce, 0x10, 0x40, 0xb6, 0x81,
+	0x89, 0xd2, 0xce, 0xa3, 0xa5, 0x57, 0x6f, 0xb5, 0xd7, 0xac, 0xfe, 0xa5, 0xe2, 0x35, 0x69, 0xd9,
+	0x98, 0x77, 0xc4, 0x0e, 0x38, 0x67, 0x8b, 0xf8, 0xad, 0xbc, 0x6b, 0xf3, 0xb4, 0x47, 0x39, 0x6c,
+	0xdf, 0x7a, 0x62, 0xb2, 0xbf, 0x06, 0x00, 0x9b, 0xb2, 0xc6, 0x35, 0xda, 0x12, 0x1d, 0x1d, 0x7d,
+	0xaf, 0xab, 0x03, 0x72, 0xee, 0x48, 0x05, 0x40, 0x6c, 0x6e, 0x0e, 0x8d, 0xe7, 0xdc, 0x63, 0x15,
+	0x80, 0xf8, 0x1f, 0x0c, 0xeb, 0xb2, 0xe1, 0x9c, 0x91, 0xa2, 0x25, 0x33, 0xfa, 0x51, 0x8e, 0x5a,
+	0x46, 0x3f, 0x8a, 0x2b, 0x18, 0xd4, 0x4b, 0xf6, 0x31, 0x52, 0x83, 0x7a, 0x49, 0x02, 0xe5, 0xd8,
+	0x78, 0x6b, 0xca, 0xad, 0x93, 0x13, 0x76, 0xad, 0x23, 0x48, 0xa0, 0x23, 0xb8, 0xa6, 0x23, 0x1c,
+	0x1b, 0x3b, 0x56, 0x67, 0xac, 0xf8, 0x16, 0x92, 0xbc, 0xd2, 0xce, 0xb5, 0x41, 0xd3, 0x74, 0x38,
+	0x4f, 0x96, 0x9f, 0x2e, 0xfa, 0x75, 0x7e, 0x7d, 0xfa, 0xae, 0xfa, 0xb1, 0xe4, 0x2d, 0xfb, 0x8c,
+	0x4e, 0xc6, 0x7c, 0xfc, 0x11, 0x92, 0x82, 0x0f, 0xa1, 0x1a, 0x21, 0x28, 0x18, 0x50, 0xf6, 0x1e,
+	0xa0, 0x4b, 0xf6, 0x9f, 0x04, 0x12, 0x30, 0xd2, 0x16, 0x75, 0xab, 0x10, 0xaf, 0xb3, 0xaf, 0x61,
+	0xfa, 0xcb, 0x3d, 0xdd, 0x11, 0x1f, 0x68, 0xd7, 0x23, 0x17, 0x52, 0x14, 0x76, 0x31, 0x20, 0xf6,
+	0x89, 0xd9, 0x36, 0x17, 0x83, 0xec, 0xef, 0x21, 0x24, 0x2b, 0x34, 0x3f, 0xa1, 0xd7, 0xec, 0x6b,
+	0x0a, 0x09, 0xf9, 0xee, 0xd0, 0xff, 0xac, 0x6b, 0x6c, 0x1b, 0xb1, 0x4f, 0x91, 0xd4, 0x8d, 0xae,
+	0xb9, 0xa8, 0xb0, 0x6d, 0xc8, 0x8e, 0xa0, 0xbb, 0xf9, 0xae, 0x22, 0x78, 0x4d, 0x39, 0x43, 0x65,
+	0xf0, 0x53, 0xdb, 0xa6, 0xec, 0x53, 0xe2, 0x2d, 0x00, 0x0d, 0x87, 0x35, 0x0d, 0x07, 0x27, 0xc7,
+	0xac, 0xfb, 0x6c, 0x11, 0xe6, 0xc7, 0xe2, 0x38, 0x3f, 0x16, 0x9b, 0xe3, 0xfc, 0x50, 0xbd, 0xe8,
+	0x5e, 0xb7, 0x07, 0xdf, 0x5b, 0x24, 0x5e, 0x43, 0x6c, 0x5a, 0x45, 0x82, 0xdf, 0xc9, 0xf2, 0xe3,
+	0x67, 0x56, 0x1e, 0xf5, 0x52, 0x5d, 0x5c, 0x27, 0xdd, 0xf4, 0x83, 0xd2, 0xc5, 0x3d, 0xe9, 0x44,
+	0x06, 0x97, 0x05, 0x9a, 0x8d, 0xd5, 0x8d, 0xbb, 0x33, 0xb6, 0x96, 0xc0, 0xc7, 0x3f, 0xe3, 0xa8,
+	0x2c, 0xf6, 0xa6, 0x7a, 0x2a, 0x4c, 0xc3, 0x5d, 0x1d, 0xab, 0x23, 0xe4, 0x2f, 0xd6, 0xfc, 0xf6,
+	0xeb, 0x8f, 0x1b, 0x79, 0xd9, 0x7e, 0x09, 0x90, 0x4e, 0xa3, 0xe5, 0x1b, 0xee, 0xe2, 0x58, 0x05,
+	0x90, 0x39, 0xb8, 0x58, 0xa1, 0xf9, 0xa1, 0xac, 0x90, 0x26, 0x22, 0xb5, 0x75, 0xcf, 0xa0, 0x13,
+	0x26, 0x35, 0xb6, 0xb6, 0xbc, 0x47, 0xdb, 0x5a, 0xd3, 0x22, 0xf1, 0x06, 0xa6, 0x64, 0xe2, 0x1a,
+	0x7d, 0x18, 0x96, 0xc9, 0x52, 0x3e, 0x13, 0xa3, 0x57, 0x03, 0xea, 0x14, 0x99, 0xfd, 0x13, 0xc1,
+	0x44, 0xa1, 0x3b, 0x54, 0x5e, 0x7c, 0xd3, 0x5a, 0xc4, 0xfd, 0x2c, 0xa3, 0x0f, 0xb4, 0x46, 0xd7,
+	0xee, 0xaa, 0x17, 0x2a, 0xbe, 0x82, 0x49, 0xb0, 0x9a, 0x6f, 0x94, 0x2c, 0x3f, 0x7a, 0xb6, 0x29,
+	0x8c, 0x1e, 0xd5, 0x86, 0x88, 0x39, 0x8c, 0xca, 0xe6, 0xce, 0x70, 0xf9, 0x24, 0xcb, 0x97, 0xe7,
+	0x57, 0xa4, 0xe7, 0x2b, 0x8e, 0xe0, 0x61, 0x66, 0xad, 0xb1, 0x5c, 0x4e, 0xb1, 0x0a, 0x60, 0xf9,
+	0x3d, 0x8c, 0x56, 0xef, 0xbe, 0x7b, 0x2f, 0xde, 0xc2, 0xc5, 0x8d, 0x35, 0x39, 0x3a, 0x27, 0x66,
+	0xe7, 0x49, 0xba, 0xff, 0xcd, 0xec, 0xec, 0x2e, 0xfc, 0xd2, 0xdb, 0x09, 0x17, 0xdc, 0xeb, 0x7f,
+	0x07, 0x00, 0x83, 0xe3, 0x27, 0xce, 0xe0, 0x06, 0x00, 0x00,
 }